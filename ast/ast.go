@@ -148,6 +148,16 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// -------------------------------------------浮点数字面量-----------------------------------------
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 // ------------------------------------------------前缀运算符的AST-----------------------------------------
 // <前缀运算符><表达式>
 type PrefixExpression struct {
@@ -224,7 +234,8 @@ func (ie *IfExpression) String() string {
 
 	//写入中间选项的条件和结果
 	for _, alternative := range ie.Alternatives {
-		alternative.String()
+		out.WriteString(" ")
+		out.WriteString(alternative.String())
 	}
 
 	//最后选项
@@ -303,6 +314,33 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// -------------------------------------------宏字面量-----------------------------------
+// 宏在求值前展开，只能通过顶层let定义
+type MacroLiteral struct {
+	Token      token.Token     //macro词法单元
+	Parameters []*Identifier   //形参列表
+	Body       *BlockStatement //宏体
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ","))
+	out.WriteString(")")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
 // -----------------------------------------------表达式（函数）调用-----------------------------
 type CallExpression struct {
 	Token     token.Token  //(词法单元
@@ -322,7 +360,7 @@ func (ce *CallExpression) String() string {
 
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
-	out.WriteString(strings.Join(args, ","))
+	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
 
 	return out.String()
@@ -355,7 +393,7 @@ func (al *ArrayLiteral) String() string {
 	}
 
 	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ","))
+	out.WriteString(strings.Join(elements, ", "))
 	out.WriteString("]")
 
 	return out.String()
@@ -382,10 +420,57 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// 切片 a[start:end:step]，三部分均可省略
+type SliceExpression struct {
+	Token token.Token //[词法单元
+	Left  Expression  //被切片的对象
+	Start Expression  //起始位置，可为nil
+	End   Expression  //结束位置（不含），可为nil
+	Step  Expression  //步长，可为nil
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// 属性访问 obj.name
+type PropertyExpression struct {
+	Token    token.Token //.词法单元
+	Object   Expression  //被访问的对象
+	Property *Identifier //属性名
+}
+
+func (pe *PropertyExpression) expressionNode()      {}
+func (pe *PropertyExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PropertyExpression) String() string {
+	return "(" + pe.Object.String() + "." + pe.Property.String() + ")"
+}
+
 // 哈希表
 type HashLiteral struct {
 	Token token.Token               //"{"
 	Pairs map[Expression]Expression //键值对
+	Keys  []Expression              //键在源码中的顺序
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -394,8 +479,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
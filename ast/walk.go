@@ -0,0 +1,485 @@
+package ast
+
+//遍历、改写与复制语法树 用法与go/ast相同，新增节点类型时只需修改这里的Walk、Modify和Copy
+
+import "fmt"
+
+// 遍历时对每个节点调用Visit，返回值w不为nil时用w继续遍历该节点的子节点，最后调用w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// 按源码顺序深度优先遍历语法树，nil子节点(如省略的切片部分、缺少的else)被跳过
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	case *LetStatement:
+		walkIdentifier(v, n.Name)
+		walkExpression(v, n.Value)
+
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Consequence)
+		for _, alt := range n.Alternatives {
+			if alt != nil {
+				Walk(v, alt)
+			}
+		}
+		walkBlock(v, n.LastAlternative)
+
+	case *ElIfExpression:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Consequence)
+
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			walkIdentifier(v, p)
+		}
+		walkBlock(v, n.Body)
+
+	case *MacroLiteral:
+		for _, p := range n.Parameters {
+			walkIdentifier(v, p)
+		}
+		walkBlock(v, n.Body)
+
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+
+	case *SliceExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Start)
+		walkExpression(v, n.End)
+		walkExpression(v, n.Step)
+
+	case *PropertyExpression:
+		walkExpression(v, n.Object)
+		walkIdentifier(v, n.Property)
+
+	case *HashLiteral:
+		//按源码中的顺序依次访问键和值
+		for _, key := range n.Keys {
+			walkExpression(v, key)
+			walkExpression(v, n.Pairs[key])
+		}
+
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean:
+		//没有子节点
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// 接口中的nil指针(如*BlockStatement(nil))同样跳过
+func walkExpression(v Visitor, e Expression) {
+	if e != nil && !isNilNode(e) {
+		Walk(v, e)
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, e := range list {
+		walkExpression(v, e)
+	}
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		if s != nil && !isNilNode(s) {
+			Walk(v, s)
+		}
+	}
+}
+
+func walkBlock(v Visitor, b *BlockStatement) {
+	if b != nil {
+		Walk(v, b)
+	}
+}
+
+func walkIdentifier(v Visitor, i *Identifier) {
+	if i != nil {
+		Walk(v, i)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// 深度优先遍历语法树，对每个节点调用f，f返回false时不再遍历该节点的子节点
+// 处理完一个节点的子节点后调用f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// 改写函数 参数为子节点已经改写过的节点，返回替换它的节点(返回原节点表示不替换)
+type ModifierFunc func(Node) Node
+
+// 自底向上改写语法树：先改写子节点并放回原位置，再对节点本身调用modifier，返回替换后的根节点
+// 原树的节点被就地修改；哈希表的Pairs按改写后的键重建
+// let的变量名、函数形参和属性名只能替换为*Identifier，返回其他节点时保持不变
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		modifyStatements(n.Statements, modifier)
+
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+
+	case *ReturnStatement:
+		n.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+
+	case *BlockStatement:
+		if n == nil {
+			return n
+		}
+		modifyStatements(n.Statements, modifier)
+
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		for i, alt := range n.Alternatives {
+			if alt != nil {
+				n.Alternatives[i] = replace[*ElIfExpression](Modify(alt, modifier), alt)
+			}
+		}
+		n.LastAlternative = modifyBlock(n.LastAlternative, modifier)
+
+	case *ElIfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+
+	case *MacroLiteral:
+		for i, p := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		modifyExpressions(n.Arguments, modifier)
+
+	case *ArrayLiteral:
+		modifyExpressions(n.Elements, modifier)
+
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+
+	case *SliceExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Start = modifyExpression(n.Start, modifier)
+		n.End = modifyExpression(n.End, modifier)
+		n.Step = modifyExpression(n.Step, modifier)
+
+	case *PropertyExpression:
+		n.Object = modifyExpression(n.Object, modifier)
+		n.Property = modifyIdentifier(n.Property, modifier)
+
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(n.Pairs))
+		for i, key := range n.Keys {
+			value := n.Pairs[key]
+			n.Keys[i] = modifyExpression(key, modifier)
+			pairs[n.Keys[i]] = modifyExpression(value, modifier)
+		}
+		n.Pairs = pairs
+	}
+
+	return modifier(node)
+}
+
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if e == nil || isNilNode(e) {
+		return e
+	}
+	return replace[Expression](Modify(e, modifier), e)
+}
+
+func modifyExpressions(list []Expression, modifier ModifierFunc) {
+	for i, e := range list {
+		list[i] = modifyExpression(e, modifier)
+	}
+}
+
+func modifyStatements(list []Statement, modifier ModifierFunc) {
+	for i, s := range list {
+		if s != nil && !isNilNode(s) {
+			list[i] = replace[Statement](Modify(s, modifier), s)
+		}
+	}
+}
+
+func modifyBlock(b *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	return replace[*BlockStatement](Modify(b, modifier), b)
+}
+
+func modifyIdentifier(i *Identifier, modifier ModifierFunc) *Identifier {
+	if i == nil {
+		return nil
+	}
+	if ident, ok := Modify(i, modifier).(*Identifier); ok {
+		return ident
+	}
+	return i
+}
+
+// 改写结果放回原位置，类型不符时说明modifier有误
+func replace[T Node](modified Node, original T) T {
+	if modified == nil {
+		var zero T
+		return zero
+	}
+	result, ok := modified.(T)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: cannot replace %T with %T", original, modified))
+	}
+	return result
+}
+
+// 深度复制语法树，改写副本不影响原树
+func Copy(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		c := *n
+		c.Statements = copyStatements(n.Statements)
+		return &c
+	case *LetStatement:
+		c := *n
+		c.Name = copyIdentifier(n.Name)
+		c.Value = copyExpression(n.Value)
+		return &c
+	case *ReturnStatement:
+		c := *n
+		c.ReturnValue = copyExpression(n.ReturnValue)
+		return &c
+	case *ExpressionStatement:
+		c := *n
+		c.Expression = copyExpression(n.Expression)
+		return &c
+	case *BlockStatement:
+		if n == nil {
+			return n
+		}
+		c := *n
+		c.Statements = copyStatements(n.Statements)
+		return &c
+	case *PrefixExpression:
+		c := *n
+		c.Right = copyExpression(n.Right)
+		return &c
+	case *InfixExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Right = copyExpression(n.Right)
+		return &c
+	case *IfExpression:
+		c := *n
+		c.Condition = copyExpression(n.Condition)
+		c.Consequence = copyBlock(n.Consequence)
+		if n.Alternatives != nil {
+			c.Alternatives = make([]*ElIfExpression, len(n.Alternatives))
+			for i, alt := range n.Alternatives {
+				if alt != nil {
+					c.Alternatives[i] = Copy(alt).(*ElIfExpression)
+				}
+			}
+		}
+		c.LastAlternative = copyBlock(n.LastAlternative)
+		return &c
+	case *ElIfExpression:
+		c := *n
+		c.Condition = copyExpression(n.Condition)
+		c.Consequence = copyBlock(n.Consequence)
+		return &c
+	case *FunctionLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = copyBlock(n.Body)
+		return &c
+	case *MacroLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = copyBlock(n.Body)
+		return &c
+	case *CallExpression:
+		c := *n
+		c.Function = copyExpression(n.Function)
+		c.Arguments = copyExpressions(n.Arguments)
+		return &c
+	case *ArrayLiteral:
+		c := *n
+		c.Elements = copyExpressions(n.Elements)
+		return &c
+	case *IndexExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Index = copyExpression(n.Index)
+		return &c
+	case *SliceExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Start = copyExpression(n.Start)
+		c.End = copyExpression(n.End)
+		c.Step = copyExpression(n.Step)
+		return &c
+	case *PropertyExpression:
+		c := *n
+		c.Object = copyExpression(n.Object)
+		c.Property = copyIdentifier(n.Property)
+		return &c
+	case *HashLiteral:
+		c := *n
+		c.Keys = make([]Expression, len(n.Keys))
+		c.Pairs = make(map[Expression]Expression, len(n.Pairs))
+		for i, key := range n.Keys {
+			c.Keys[i] = copyExpression(key)
+			c.Pairs[c.Keys[i]] = copyExpression(n.Pairs[key])
+		}
+		return &c
+	case *Identifier:
+		return copyIdentifier(n)
+	case *IntegerLiteral:
+		c := *n
+		return &c
+	case *FloatLiteral:
+		c := *n
+		return &c
+	case *StringLiteral:
+		c := *n
+		return &c
+	case *Boolean:
+		c := *n
+		return &c
+	case nil:
+		return nil
+	}
+	panic(fmt.Sprintf("ast.Copy: unexpected node type %T", node))
+}
+
+func copyExpression(e Expression) Expression {
+	if e == nil || isNilNode(e) {
+		return e
+	}
+	return Copy(e).(Expression)
+}
+
+func copyExpressions(list []Expression) []Expression {
+	if list == nil {
+		return nil
+	}
+	c := make([]Expression, len(list))
+	for i, e := range list {
+		c[i] = copyExpression(e)
+	}
+	return c
+}
+
+func copyStatements(list []Statement) []Statement {
+	if list == nil {
+		return nil
+	}
+	c := make([]Statement, len(list))
+	for i, s := range list {
+		if s != nil && !isNilNode(s) {
+			c[i] = Copy(s).(Statement)
+		}
+	}
+	return c
+}
+
+func copyBlock(b *BlockStatement) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	return Copy(b).(*BlockStatement)
+}
+
+func copyIdentifier(i *Identifier) *Identifier {
+	if i == nil {
+		return nil
+	}
+	c := *i
+	return &c
+}
+
+func copyIdentifiers(list []*Identifier) []*Identifier {
+	if list == nil {
+		return nil
+	}
+	c := make([]*Identifier, len(list))
+	for i, ident := range list {
+		c[i] = copyIdentifier(ident)
+	}
+	return c
+}
+
+// 接口值不为nil但其中的指针为nil
+func isNilNode(node Node) bool {
+	switch n := node.(type) {
+	case *BlockStatement:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	}
+	return false
+}
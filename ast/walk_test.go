@@ -0,0 +1,207 @@
+package ast
+
+import (
+	"fmt"
+	"monkey_Interpreter/token"
+	"strings"
+	"testing"
+)
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func integer(v int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(v)}, Value: v}
+}
+
+func block(exps ...Expression) *BlockStatement {
+	b := &BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}}
+	for _, e := range exps {
+		b.Statements = append(b.Statements, &ExpressionStatement{Expression: e})
+	}
+	return b
+}
+
+func infix(left Expression, op string, right Expression) *InfixExpression {
+	return &InfixExpression{Token: token.Token{Literal: op}, Left: left, Operator: op, Right: right}
+}
+
+// let f = fn(x) { if (x > 1) { -x } elif (x == 1) { 2 } else { [3][0:] } };
+// return {4: f(5), "k": m.n};
+func testTree() *Program {
+	key := integer(4)
+	str := &StringLiteral{Token: token.Token{Type: token.STRING, Literal: "k"}, Value: "k"}
+	return &Program{Statements: []Statement{
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  ident("f"),
+			Value: &FunctionLiteral{
+				Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+				Parameters: []*Identifier{ident("x")},
+				Body: block(&IfExpression{
+					Token:       token.Token{Type: token.IF, Literal: "if"},
+					Condition:   infix(ident("x"), ">", integer(1)),
+					Consequence: block(&PrefixExpression{Token: token.Token{Literal: "-"}, Operator: "-", Right: ident("x")}),
+					Alternatives: []*ElIfExpression{{
+						Token:       token.Token{Literal: "elif"},
+						Condition:   infix(ident("x"), "==", integer(1)),
+						Consequence: block(integer(2)),
+					}},
+					LastAlternative: block(&SliceExpression{
+						Left:  &ArrayLiteral{Elements: []Expression{integer(3)}},
+						Start: integer(0),
+					}),
+				}),
+			},
+		},
+		&ReturnStatement{
+			Token: token.Token{Type: token.RETURN, Literal: "return"},
+			ReturnValue: &HashLiteral{
+				Keys: []Expression{key, str},
+				Pairs: map[Expression]Expression{
+					key: &CallExpression{Function: ident("f"), Arguments: []Expression{integer(5)}},
+					str: &PropertyExpression{Object: ident("m"), Property: ident("n")},
+				},
+			},
+		},
+	}}
+}
+
+func TestInspect(t *testing.T) {
+	var visited []string
+	Inspect(testTree(), func(node Node) bool {
+		if node != nil {
+			visited = append(visited, strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+		}
+		return true
+	})
+
+	expected := []string{
+		"Program", "LetStatement", "Identifier", "FunctionLiteral", "Identifier", "BlockStatement",
+		"ExpressionStatement", "IfExpression", "InfixExpression", "Identifier", "IntegerLiteral",
+		"BlockStatement", "ExpressionStatement", "PrefixExpression", "Identifier",
+		"ElIfExpression", "InfixExpression", "Identifier", "IntegerLiteral", "BlockStatement", "ExpressionStatement", "IntegerLiteral",
+		"BlockStatement", "ExpressionStatement", "SliceExpression", "ArrayLiteral", "IntegerLiteral", "IntegerLiteral",
+		"ReturnStatement", "HashLiteral", "IntegerLiteral", "CallExpression", "Identifier", "IntegerLiteral",
+		"StringLiteral", "PropertyExpression", "Identifier", "Identifier",
+	}
+	if strings.Join(visited, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong traversal.\ngot= %v\nwant=%v", visited, expected)
+	}
+
+	//返回false时跳过子节点
+	var names []string
+	Inspect(testTree(), func(node Node) bool {
+		if i, ok := node.(*Identifier); ok {
+			names = append(names, i.Value)
+		}
+		_, isFunction := node.(*FunctionLiteral)
+		return !isFunction
+	})
+	if got := strings.Join(names, ","); got != "f,f,m,n" {
+		t.Errorf("function body not skipped. got=%s", got)
+	}
+}
+
+type depthVisitor struct {
+	depth, max *int
+}
+
+func (v depthVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		*v.depth--
+		return nil
+	}
+	*v.depth++
+	if *v.depth > *v.max {
+		*v.max = *v.depth
+	}
+	return v
+}
+
+func TestWalk(t *testing.T) {
+	depth, max := 0, 0
+	Walk(depthVisitor{&depth, &max}, testTree())
+	if depth != 0 {
+		t.Errorf("Visit(nil) not called once per node. depth=%d", depth)
+	}
+	//Program Let Fn Block ExprStmt If Block ExprStmt Slice Array IntegerLiteral
+	if max != 11 {
+		t.Errorf("wrong max depth. got=%d, want=11", max)
+	}
+}
+
+func TestModify(t *testing.T) {
+	double := func(node Node) Node {
+		if i, ok := node.(*IntegerLiteral); ok {
+			return integer(i.Value * 2)
+		}
+		if i, ok := node.(*Identifier); ok && i.Value == "x" {
+			return ident("y")
+		}
+		return node
+	}
+
+	program := Modify(testTree(), double).(*Program)
+	expected := "let f=fn(y)if (y > 2) (-y) elif (y == 2) 4 else ([6][0:]);return {8:f(10),k:(m.n)};"
+	if program.String() != expected {
+		t.Errorf("wrong result.\ngot= %s\nwant=%s", program.String(), expected)
+	}
+
+	hash := program.Statements[1].(*ReturnStatement).ReturnValue.(*HashLiteral)
+	for _, key := range hash.Keys {
+		if _, ok := hash.Pairs[key]; !ok {
+			t.Errorf("pairs not rebuilt for key %s", key)
+		}
+	}
+	if len(hash.Pairs) != 2 {
+		t.Errorf("wrong number of pairs. got=%d", len(hash.Pairs))
+	}
+
+	//名称位置只能替换为标识符
+	renamed := Modify(testTree(), func(node Node) Node {
+		if i, ok := node.(*Identifier); ok && i.Value == "f" {
+			return integer(0)
+		}
+		return node
+	})
+	if got := renamed.String(); !strings.HasPrefix(got, "let f=") || !strings.Contains(got, "0(5)") {
+		t.Errorf("wrong result for renamed identifiers. got=%s", got)
+	}
+}
+
+func TestModifyWrongType(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "cannot replace *ast.BlockStatement with *ast.IntegerLiteral") {
+			t.Errorf("expected a panic for a wrong replacement. got=%v", r)
+		}
+	}()
+	Modify(testTree(), func(node Node) Node {
+		if _, ok := node.(*BlockStatement); ok {
+			return integer(0)
+		}
+		return node
+	})
+}
+
+func TestCopy(t *testing.T) {
+	original := testTree()
+	copied := Copy(original).(*Program)
+	if copied.String() != original.String() {
+		t.Fatalf("copy differs.\ngot= %s\nwant=%s", copied.String(), original.String())
+	}
+
+	Modify(copied, func(node Node) Node {
+		if i, ok := node.(*IntegerLiteral); ok {
+			i.Value, i.Token.Literal = 0, "0"
+		}
+		if i, ok := node.(*Identifier); ok {
+			i.Value = "z"
+		}
+		return node
+	})
+	if original.String() != testTree().String() {
+		t.Errorf("modifying the copy changed the original. got=%s", original.String())
+	}
+}
@@ -0,0 +1,238 @@
+package code
+
+//字节码 每条指令由一个字节的操作码和若干个大端序的操作数组成
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// 指令序列
+type Instructions []byte
+
+// 反汇编，每行一条指令，行首为指令的偏移量
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			return out.String()
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), len(def.OperandWidths))
+	}
+
+	out := def.Name
+	for _, operand := range operands {
+		out += fmt.Sprintf(" %d", operand)
+	}
+	return out
+}
+
+// 操作码
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota //将常量池中的常量压栈
+	OpPop                    //弹出栈顶，表达式语句结束时使用
+
+	//中缀运算
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpAnd
+	OpOr
+
+	//前缀运算
+	OpMinus
+	OpBang
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	//跳转 操作数为目标偏移量
+	OpJump
+	OpJumpNotTruthy
+
+	//变量
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetOuter //外层函数的局部变量 操作数为向外的层数和下标
+	OpGetName  //编译时无法确定的名称，运行时按名称查找全局变量、内置函数与模块
+
+	OpArray    //操作数为元素个数
+	OpHash     //操作数为键和值的总个数
+	OpIndex    //a[i]
+	OpSlice    //a[start:end:step] 操作数标记给出了哪几部分
+	OpProperty //obj.name 操作数为属性名在常量池中的下标
+
+	OpCall        //操作数为实参个数
+	OpReturnValue //返回栈顶的值
+	OpReturn      //函数体为空等情况，返回null
+	OpClosure     //操作数为编译后函数在常量池中的下标
+)
+
+// 切片指令操作数中的标记
+const (
+	SliceStart = 1 << iota
+	SliceEnd
+	SliceStep
+)
+
+// 操作码的定义：名称及各操作数占用的字节数
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:         {"OpAdd", []int{}},
+	OpSub:         {"OpSub", []int{}},
+	OpMul:         {"OpMul", []int{}},
+	OpDiv:         {"OpDiv", []int{}},
+	OpEqual:       {"OpEqual", []int{}},
+	OpNotEqual:    {"OpNotEqual", []int{}},
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+	OpLessThan:    {"OpLessThan", []int{}},
+	OpAnd:         {"OpAnd", []int{}},
+	OpOr:          {"OpOr", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpGetLocal:  {"OpGetLocal", []int{1}},
+	OpSetLocal:  {"OpSetLocal", []int{1}},
+	OpGetOuter:  {"OpGetOuter", []int{1, 1}},
+	OpGetName:   {"OpGetName", []int{2}},
+
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSlice:    {"OpSlice", []int{1}},
+	OpProperty: {"OpProperty", []int{2}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2}},
+}
+
+// 查找操作码的定义
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// 生成一条指令
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// 解码指令的操作数，返回操作数及其占用的字节数
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// 行号表的一项 从指令偏移量Offset开始的指令来自源码第Line行
+type LineEntry struct {
+	Offset int
+	Line   int
+}
+
+// 行号表 按Offset递增排列
+type LineTable []LineEntry
+
+// 偏移量处的指令所在的行，没有记录时为0
+func (t LineTable) Line(offset int) int {
+	line := 0
+	for _, e := range t {
+		if e.Offset > offset {
+			break
+		}
+		line = e.Line
+	}
+	return line
+}
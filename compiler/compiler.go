@@ -0,0 +1,554 @@
+package compiler
+
+//编译器 把语法树编译为字节码
+//变量在所在作用域(顶层或函数)开头统一定义，块不产生新的作用域，与求值器的环境一致；
+//尚未赋值的变量在运行时按名称向外查找，因此闭包可以引用之后才定义的变量
+
+import (
+	"fmt"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/code"
+	"monkey_Interpreter/object"
+)
+
+// 编译结果
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Globals      []string       //按下标排列的全局变量名
+	Lines        code.LineTable //顶层代码的行号表
+}
+
+// 编译中的函数
+type CompilationScope struct {
+	instructions code.Instructions
+	lines        code.LineTable
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	names       map[string]int //已加入常量池的名称，避免重复
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// 沿用之前的符号表和常量池，REPL中逐段编译时使用
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		names:       map[string]int{},
+		scopes:      []CompilationScope{{}},
+	}
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		c.hoist(node)
+		for _, s := range node.Statements {
+			if err := c.compileStatement(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.BlockStatement:
+		return c.compileBlock(node)
+
+	case *ast.IntegerLiteral:
+		return c.emitConstant(&object.Integer{Value: node.Value})
+
+	case *ast.FloatLiteral:
+		return c.emitConstant(&object.Float{Value: node.Value})
+
+	case *ast.StringLiteral:
+		return c.emitConstant(&object.String{Value: node.Value})
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator: %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator: %s", node.Operator)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.IfExpression:
+		return c.compileIf(node)
+
+	case *ast.Identifier:
+		return c.loadSymbol(node.Value)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node, "")
+
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macro literals can only be defined by a top-level let")
+
+	case *ast.CallExpression:
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return fmt.Errorf("quote outside of a macro is only supported by the eval backend")
+		}
+		if len(node.Arguments) > 255 {
+			return fmt.Errorf("too many arguments: %d", len(node.Arguments))
+		}
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			if err := c.Compile(e); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, k := range node.Keys { //按源码顺序
+			if err := c.Compile(k); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Keys)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		flags := 0
+		for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+			if exp == nil {
+				continue
+			}
+			if err := c.Compile(exp); err != nil {
+				return err
+			}
+			flags |= 1 << i
+		}
+		c.emit(code.OpSlice, flags)
+
+	case *ast.PropertyExpression:
+		if err := c.Compile(node.Object); err != nil {
+			return err
+		}
+		index, err := c.nameConstant(node.Property.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpProperty, index)
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	"&&": code.OpAnd,
+	"||": code.OpOr,
+}
+
+// 顶层语句 表达式的值弹出后即为程序的结果
+func (c *Compiler) compileStatement(s ast.Statement) error {
+	c.markLine(s)
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		if err := c.compileExpression(s.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.LetStatement:
+		return c.compileLet(s)
+	case *ast.ReturnStatement:
+		return c.compileReturn(s)
+	default:
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	return nil
+}
+
+// 语法错误时表达式可能为nil，此时结果为null
+func (c *Compiler) compileExpression(exp ast.Expression) error {
+	if exp == nil {
+		c.emit(code.OpNull)
+		return nil
+	}
+	return c.Compile(exp)
+}
+
+// 编译块，结果为最后一条表达式语句的值，为空或以let结尾时为null
+func (c *Compiler) compileBlock(block *ast.BlockStatement) error {
+	if block == nil || len(block.Statements) == 0 {
+		c.emit(code.OpNull)
+		return nil
+	}
+
+	for i, s := range block.Statements {
+		last := i == len(block.Statements)-1
+		c.markLine(s)
+		switch s := s.(type) {
+		case *ast.ExpressionStatement:
+			if err := c.compileExpression(s.Expression); err != nil {
+				return err
+			}
+			if !last {
+				c.emit(code.OpPop)
+			}
+		case *ast.LetStatement:
+			if err := c.compileLet(s); err != nil {
+				return err
+			}
+			if last {
+				c.emit(code.OpNull)
+			}
+		case *ast.ReturnStatement:
+			if err := c.compileReturn(s); err != nil {
+				return err
+			}
+		default:
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+			if !last {
+				c.emit(code.OpPop)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileLet(s *ast.LetStatement) error {
+	var err error
+	if fn, ok := s.Value.(*ast.FunctionLiteral); ok {
+		err = c.compileFunction(fn, s.Name.Value)
+	} else {
+		err = c.compileExpression(s.Value)
+	}
+	if err != nil {
+		return err
+	}
+
+	symbol := c.symbolTable.Define(s.Name.Value)
+	if symbol.Scope == GlobalScope {
+		if symbol.Index > 65535 {
+			return fmt.Errorf("too many global variables")
+		}
+		c.emit(code.OpSetGlobal, symbol.Index)
+	} else {
+		if symbol.Index > 255 {
+			return fmt.Errorf("too many local variables")
+		}
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+	return nil
+}
+
+// 函数中返回到调用处，顶层则结束程序
+func (c *Compiler) compileReturn(s *ast.ReturnStatement) error {
+	if err := c.compileExpression(s.ReturnValue); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+// if按顺序检查各个条件，每个分支执行后跳到末尾
+func (c *Compiler) compileIf(node *ast.IfExpression) error {
+	var jumpsToEnd []int
+
+	branch := func(condition ast.Expression, consequence *ast.BlockStatement) error {
+		if err := c.Compile(condition); err != nil {
+			return err
+		}
+		jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+		if err := c.compileBlock(consequence); err != nil {
+			return err
+		}
+		jumpsToEnd = append(jumpsToEnd, c.emit(code.OpJump, 9999))
+		return c.patchJump(jumpNotTruthy)
+	}
+
+	if err := branch(node.Condition, node.Consequence); err != nil {
+		return err
+	}
+	for _, alternative := range node.Alternatives {
+		if err := branch(alternative.Condition, alternative.Consequence); err != nil {
+			return err
+		}
+	}
+
+	if node.LastAlternative != nil {
+		if err := c.compileBlock(node.LastAlternative); err != nil {
+			return err
+		}
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	for _, pos := range jumpsToEnd {
+		if err := c.patchJump(pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 把pos处跳转指令的目标改为当前位置
+func (c *Compiler) patchJump(pos int) error {
+	target := len(c.currentInstructions())
+	if target > 65535 {
+		return fmt.Errorf("function too large: jump target %d out of range", target)
+	}
+	c.changeOperand(pos, target)
+	return nil
+}
+
+// 编译函数字面量 name为let绑定的名称
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+
+	for _, p := range node.Parameters {
+		c.symbolTable.DefineParameter(p.Value)
+	}
+	c.hoist(node.Body)
+
+	if err := c.compileBlock(node.Body); err != nil {
+		c.leaveScope()
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	numLocals := c.symbolTable.NumDefinitions()
+	localNames := c.symbolTable.Names()
+	lines := c.scopes[c.scopeIndex].lines
+	instructions := c.leaveScope()
+	if numLocals > 256 {
+		return fmt.Errorf("too many local variables in function")
+	}
+
+	fn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		LocalNames:    localNames,
+		Name:          name,
+		Body:          node.Body.String(),
+		Lines:         lines,
+	}
+	index, err := c.addConstant(fn)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpClosure, index)
+	return nil
+}
+
+// 读取变量 编译时找不到的名称在运行时查找
+func (c *Compiler) loadSymbol(name string) error {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		index, err := c.nameConstant(name)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpGetName, index)
+		return nil
+	}
+
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, symbol.Index)
+	case OuterScope:
+		if symbol.Depth > 255 {
+			return fmt.Errorf("functions nested too deeply")
+		}
+		c.emit(code.OpGetOuter, symbol.Depth, symbol.Index)
+	}
+	return nil
+}
+
+// 在作用域开头定义其中所有let绑定的变量，不进入函数字面量
+func (c *Compiler) hoist(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.LetStatement:
+			c.symbolTable.Define(n.Name.Value)
+		}
+		return true
+	})
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Globals:      c.globalTable().Names(),
+		Lines:        c.scopes[c.scopeIndex].lines,
+	}
+}
+
+func (c *Compiler) globalTable() *SymbolTable {
+	table := c.symbolTable
+	for table.Outer != nil {
+		table = table.Outer
+	}
+	return table
+}
+
+func (c *Compiler) addConstant(obj object.Object) (int, error) {
+	if len(c.constants) > 65535 {
+		return 0, fmt.Errorf("too many constants")
+	}
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1, nil
+}
+
+func (c *Compiler) emitConstant(obj object.Object) error {
+	index, err := c.addConstant(obj)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpConstant, index)
+	return nil
+}
+
+// 名称以字符串常量保存，同一个名称只保存一次
+func (c *Compiler) nameConstant(name string) (int, error) {
+	if index, ok := c.names[name]; ok {
+		return index, nil
+	}
+	index, err := c.addConstant(&object.String{Value: name})
+	if err != nil {
+		return 0, err
+	}
+	c.names[name] = index
+	return index, nil
+}
+
+// 记录接下来的指令来自语句所在的行
+func (c *Compiler) markLine(s ast.Statement) {
+	var line int
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		line = s.Token.Line
+	case *ast.ReturnStatement:
+		line = s.Token.Line
+	case *ast.ExpressionStatement:
+		line = s.Token.Line
+	case *ast.BlockStatement:
+		line = s.Token.Line
+	}
+	if line == 0 {
+		return
+	}
+
+	scope := &c.scopes[c.scopeIndex]
+	offset := len(scope.instructions)
+	if n := len(scope.lines); n > 0 {
+		last := &scope.lines[n-1]
+		if last.Line == line {
+			return
+		}
+		if last.Offset == offset { //上一行没有生成指令
+			last.Line = line
+			return
+		}
+	}
+	scope.lines = append(scope.lines, code.LineEntry{Offset: offset, Line: line})
+}
+
+// 生成指令，返回指令的位置
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return pos
+}
+
+func (c *Compiler) changeOperand(pos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[pos])
+	copy(c.currentInstructions()[pos:], code.Make(op, operand))
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return instructions
+}
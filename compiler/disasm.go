@@ -0,0 +1,50 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"monkey_Interpreter/code"
+	"monkey_Interpreter/object"
+	"strings"
+)
+
+// 反汇编 依次列出顶层代码和常量池中的函数，每条指令前标出源码行号
+func (b *Bytecode) Disassemble() string {
+	var out bytes.Buffer
+
+	out.WriteString("main:\n")
+	disassemble(&out, b.Instructions, b.Lines)
+
+	for i, c := range b.Constants {
+		fn, ok := c.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		name := fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		params := fn.LocalNames[:fn.NumParameters]
+		fmt.Fprintf(&out, "\nconstant %d: fn %s(%s) locals=%d\n", i, name, strings.Join(params, ", "), fn.NumLocals)
+		disassemble(&out, fn.Instructions, fn.Lines)
+	}
+	return out.String()
+}
+
+// 行号只在变化时标出
+func disassemble(out *bytes.Buffer, ins code.Instructions, lines code.LineTable) {
+	last := -1
+	for _, text := range strings.SplitAfter(ins.String(), "\n") {
+		if text == "" {
+			continue
+		}
+		var offset int
+		fmt.Sscanf(text, "%d", &offset)
+		if line := lines.Line(offset); line != last {
+			fmt.Fprintf(out, "%4d  %s", line, text)
+			last = line
+		} else {
+			fmt.Fprintf(out, "      %s", text)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package compiler
+
+//编译结果的二进制格式(.mkc) 加载时跳过词法分析和语法分析
+//
+//	文件头  "MKC\x00"，格式版本(2字节，大端序)
+//	正文    全局变量名、顶层代码及其行号表、常量池
+//	校验和  正文的CRC32(4字节，大端序)
+//
+//整数使用变长编码，字符串为长度加UTF-8字节；
+//常量以一个字节的标记开头，函数原型包括名称、源码、形参个数、局部变量名、指令和行号表
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"monkey_Interpreter/code"
+	"monkey_Interpreter/object"
+)
+
+// 格式版本 操作码或编码方式改变时递增，旧版本的文件需要重新编译
+const FormatVersion = 1
+
+var mkcMagic = []byte("MKC\x00")
+
+// 常量的类型标记
+const (
+	tagInteger byte = iota + 1
+	tagFloat
+	tagString
+	tagFunction
+)
+
+// 判断数据是否为编译后的字节码
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, mkcMagic)
+}
+
+// 写入.mkc格式的字节码
+func (b *Bytecode) WriteTo(w io.Writer) (int64, error) {
+	var body encoder
+	body.strings(b.Globals)
+	body.instructions(b.Instructions, b.Lines)
+	body.uvarint(uint64(len(b.Constants)))
+	for _, c := range b.Constants {
+		if err := body.constant(c); err != nil {
+			return 0, err
+		}
+	}
+
+	var out bytes.Buffer
+	out.Write(mkcMagic)
+	binary.Write(&out, binary.BigEndian, uint16(FormatVersion))
+	out.Write(body.Bytes())
+	binary.Write(&out, binary.BigEndian, crc32.ChecksumIEEE(body.Bytes()))
+
+	n, err := w.Write(out.Bytes())
+	return int64(n), err
+}
+
+// 读取.mkc格式的字节码，并检查指令是否合法
+func ReadBytecode(r io.Reader) (*Bytecode, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !IsBytecode(data) {
+		return nil, errors.New("mkc: not a compiled monkey file")
+	}
+	header := len(mkcMagic) + 2
+	if len(data) < header+4 {
+		return nil, errors.New("mkc: file truncated")
+	}
+	if version := binary.BigEndian.Uint16(data[len(mkcMagic):]); version != FormatVersion {
+		return nil, fmt.Errorf("mkc: unsupported format version %d (want %d), recompile the source", version, FormatVersion)
+	}
+	body := data[header : len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(data)-4:]) {
+		return nil, errors.New("mkc: checksum mismatch, file is corrupted")
+	}
+
+	d := &decoder{data: body}
+	b := &Bytecode{}
+	b.Globals = d.strings()
+	b.Instructions, b.Lines = d.instructions()
+	n := d.count()
+	b.Constants = make([]object.Object, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		b.Constants = append(b.Constants, d.constant())
+	}
+	if d.err == nil && d.pos != len(d.data) {
+		d.fail("unexpected data after constants")
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if err := verify(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (e *encoder) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.Write(buf[:binary.PutVarint(buf[:], v)])
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.WriteString(s)
+}
+
+func (e *encoder) strings(list []string) {
+	e.uvarint(uint64(len(list)))
+	for _, s := range list {
+		e.string(s)
+	}
+}
+
+// 指令及行号表，行号表的偏移量和行号都记录与上一项的差
+func (e *encoder) instructions(ins code.Instructions, lines code.LineTable) {
+	e.uvarint(uint64(len(ins)))
+	e.Write(ins)
+	e.uvarint(uint64(len(lines)))
+	prev := code.LineEntry{}
+	for _, l := range lines {
+		e.uvarint(uint64(l.Offset - prev.Offset))
+		e.varint(int64(l.Line - prev.Line))
+		prev = l
+	}
+}
+
+func (e *encoder) constant(obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		e.WriteByte(tagInteger)
+		e.varint(obj.Value)
+	case *object.Float:
+		e.WriteByte(tagFloat)
+		binary.Write(e, binary.BigEndian, math.Float64bits(obj.Value))
+	case *object.String:
+		e.WriteByte(tagString)
+		e.string(obj.Value)
+	case *object.CompiledFunction:
+		e.WriteByte(tagFunction)
+		e.string(obj.Name)
+		e.string(obj.Body)
+		e.uvarint(uint64(obj.NumParameters))
+		e.strings(obj.LocalNames)
+		e.instructions(obj.Instructions, obj.Lines)
+	default:
+		return fmt.Errorf("mkc: cannot encode constant of type %s", obj.Type())
+	}
+	return nil
+}
+
+// 解码时遇到的第一个错误记录在err中，之后的读取都返回零值
+type decoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("mkc: "+format, a...)
+	}
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("malformed integer at byte %d", d.pos)
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("malformed integer at byte %d", d.pos)
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+// 长度或个数 不能超过剩余的字节数，避免损坏的文件导致分配过多内存
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)-d.pos) {
+		d.fail("length %d exceeds remaining data", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.data)-d.pos {
+		d.fail("file truncated")
+		return nil
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.count()))
+}
+
+func (d *decoder) strings() []string {
+	n := d.count()
+	list := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		list = append(list, d.string())
+	}
+	return list
+}
+
+func (d *decoder) instructions() (code.Instructions, code.LineTable) {
+	ins := code.Instructions(append([]byte{}, d.bytes(d.count())...))
+	n := d.count()
+	lines := make(code.LineTable, 0, n)
+	prev := code.LineEntry{}
+	for i := 0; i < n && d.err == nil; i++ {
+		prev = code.LineEntry{
+			Offset: prev.Offset + int(d.uvarint()),
+			Line:   prev.Line + int(d.varint()),
+		}
+		lines = append(lines, prev)
+	}
+	return ins, lines
+}
+
+func (d *decoder) constant() object.Object {
+	switch tag := d.byte(); tag {
+	case tagInteger:
+		return &object.Integer{Value: d.varint()}
+	case tagFloat:
+		b := d.bytes(8)
+		if b == nil {
+			return nil
+		}
+		return &object.Float{Value: math.Float64frombits(binary.BigEndian.Uint64(b))}
+	case tagString:
+		return &object.String{Value: d.string()}
+	case tagFunction:
+		fn := &object.CompiledFunction{Name: d.string(), Body: d.string()}
+		fn.NumParameters = int(d.uvarint())
+		fn.LocalNames = d.strings()
+		fn.NumLocals = len(fn.LocalNames)
+		fn.Instructions, fn.Lines = d.instructions()
+		if fn.NumParameters > fn.NumLocals {
+			d.fail("function %q has more parameters than locals", fn.Name)
+		}
+		return fn
+	default:
+		d.fail("unknown constant tag %d", tag)
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package compiler
+
+import (
+	"bytes"
+	"monkey_Interpreter/code"
+	"monkey_Interpreter/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	input := `let pi = 3.14;
+let greet = fn(name) {
+  let prefix = "hello, ";
+  fn() { prefix + name }
+};
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+put(greet("monkey")(), fib(-10), [1, 2][0:1], {"a": pi}["a"]);`
+
+	c := New()
+	if err := c.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	original := c.Bytecode()
+	if err := verify(original); err != nil {
+		t.Fatalf("compiled bytecode rejected: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := original.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %s", err)
+	}
+	if !IsBytecode(buf.Bytes()) {
+		t.Fatalf("output not recognized as bytecode")
+	}
+	loaded, err := ReadBytecode(&buf)
+	if err != nil {
+		t.Fatalf("ReadBytecode failed: %s", err)
+	}
+
+	if !reflect.DeepEqual(loaded, original) {
+		t.Errorf("bytecode changed after round trip.\nwant=%#v\ngot=%#v", original, loaded)
+	}
+	if line := loaded.Lines.Line(len(loaded.Instructions) - 1); line != 7 {
+		t.Errorf("wrong line for the last instruction. got=%d, want=7", line)
+	}
+}
+
+func TestReadBytecodeErrors(t *testing.T) {
+	valid := encode(t, &Bytecode{
+		Instructions: concatInstructions([]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpPop),
+		}),
+		Constants: []object.Object{&object.Integer{Value: 1}},
+	})
+
+	version := append([]byte{}, valid...)
+	version[5] = 99
+	corrupted := append([]byte{}, valid...)
+	corrupted[7] ^= 0xff
+
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{[]byte("let x = 1;"), "mkc: not a compiled monkey file"},
+		{valid[:6], "mkc: file truncated"},
+		{version, "mkc: unsupported format version 99 (want 1), recompile the source"},
+		{corrupted, "mkc: checksum mismatch, file is corrupted"},
+		{encode(t, &Bytecode{
+			Instructions: code.Make(code.OpConstant, 1),
+			Constants:    []object.Object{&object.Integer{Value: 1}},
+		}), "mkc: main: OpConstant at 0: constant 1 out of range"},
+		{encode(t, &Bytecode{Instructions: code.Make(code.OpPop)}), "mkc: main: stack underflow"},
+		{encode(t, &Bytecode{Instructions: code.Make(code.OpJump, 1)}), "mkc: main: jump to 1 is not an instruction"},
+		{encode(t, &Bytecode{Instructions: code.Make(code.OpGetGlobal, 0)}), "mkc: main: OpGetGlobal at 0: global 0 out of range"},
+		{encode(t, &Bytecode{
+			Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpPop),
+			}),
+			Constants: []object.Object{&object.CompiledFunction{
+				Name:         "f",
+				Instructions: code.Make(code.OpTrue),
+			}},
+		}), "mkc: function f: "},
+		{encode(t, &Bytecode{
+			Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpPop),
+			}),
+			Constants: []object.Object{&object.CompiledFunction{
+				Name: "f",
+				Instructions: concatInstructions([]code.Instructions{
+					code.Make(code.OpGetOuter, 1, 0),
+					code.Make(code.OpReturnValue),
+				}),
+			}},
+		}), "mkc: function f: invalid outer variable reference 1 0"},
+	}
+
+	for _, tt := range tests {
+		_, err := ReadBytecode(bytes.NewReader(tt.data))
+		if err == nil {
+			t.Errorf("expected error %q, got none", tt.expected)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("wrong error. got=%q, want prefix %q", err, tt.expected)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	c := New()
+	if err := c.Compile(parse("let add = fn(a, b) {\n  a + b\n};\nadd(1, 2)")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := `main:
+   1  0000 OpClosure 0
+      0003 OpSetGlobal 0
+   4  0006 OpGetGlobal 0
+      0009 OpConstant 1
+      0012 OpConstant 2
+      0015 OpCall 2
+      0017 OpPop
+
+constant 0: fn add(a, b) locals=2
+   2  0000 OpGetLocal 0
+      0002 OpGetLocal 1
+      0004 OpAdd
+      0005 OpReturnValue
+`
+	if got := c.Bytecode().Disassemble(); got != expected {
+		t.Errorf("wrong disassembly.\ngot=\n%s\nwant=\n%s", got, expected)
+	}
+}
+
+func encode(t *testing.T, b *Bytecode) []byte {
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %s", err)
+	}
+	return buf.Bytes()
+}
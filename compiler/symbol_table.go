@@ -0,0 +1,88 @@
+package compiler
+
+//符号表 编译时把变量名解析为全局变量、局部变量或外层函数的局部变量的下标
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL" //顶层定义的变量
+	LocalScope  SymbolScope = "LOCAL"  //当前函数的形参和局部变量
+	OuterScope  SymbolScope = "OUTER"  //外层函数的局部变量，闭包用
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Depth int //OuterScope时向外的函数层数
+}
+
+// 每个函数一张符号表，顶层为全局符号表
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+	names []string //按下标排列的变量名
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+// 函数的符号表
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// 定义变量，已定义过时返回原来的符号，与求值器中重复let覆盖同一个绑定一致
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+	return s.define(name)
+}
+
+// 定义形参 每个形参占用一个位置，同名时后面的形参生效
+func (s *SymbolTable) DefineParameter(name string) Symbol {
+	return s.define(name)
+}
+
+func (s *SymbolTable) define(name string) Symbol {
+	symbol := Symbol{Name: name, Index: len(s.names), Scope: LocalScope}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	}
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// 由内向外查找变量
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	depth := 0
+	for table := s; table != nil; table = table.Outer {
+		if symbol, ok := table.store[name]; ok {
+			if symbol.Scope == LocalScope && depth > 0 {
+				symbol.Scope = OuterScope
+				symbol.Depth = depth
+			}
+			return symbol, true
+		}
+		depth++
+	}
+	return Symbol{}, false
+}
+
+// 已定义的变量个数
+func (s *SymbolTable) NumDefinitions() int {
+	return len(s.names)
+}
+
+// 按下标排列的变量名
+func (s *SymbolTable) Names() []string {
+	names := make([]string, len(s.names))
+	copy(names, s.names)
+	return names
+}
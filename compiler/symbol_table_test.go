@@ -0,0 +1,59 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	global := NewSymbolTable()
+	if a := global.Define("a"); a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("a wrong. got=%+v", a)
+	}
+	if b := global.Define("b"); b != (Symbol{Name: "b", Scope: GlobalScope, Index: 1}) {
+		t.Errorf("b wrong. got=%+v", b)
+	}
+	if a := global.Define("a"); a.Index != 0 {
+		t.Errorf("redefined a should keep its index. got=%+v", a)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	if x := local.DefineParameter("x"); x != (Symbol{Name: "x", Scope: LocalScope, Index: 0}) {
+		t.Errorf("x wrong. got=%+v", x)
+	}
+	if x := local.DefineParameter("x"); x.Index != 1 {
+		t.Errorf("repeated parameter should get a new index. got=%+v", x)
+	}
+	if n := local.NumDefinitions(); n != 2 {
+		t.Errorf("NumDefinitions wrong. got=%d", n)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+	first.Define("c")
+	second := NewEnclosedSymbolTable(first)
+	second.Define("c")
+	second.Define("d")
+
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: OuterScope, Index: 0, Depth: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+	for name, want := range expected {
+		got, ok := second.Resolve(name)
+		if !ok {
+			t.Errorf("name %s not resolvable", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", name, want, got)
+		}
+	}
+
+	if _, ok := second.Resolve("e"); ok {
+		t.Errorf("e should not be resolvable")
+	}
+}
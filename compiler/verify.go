@@ -0,0 +1,262 @@
+package compiler
+
+//检查从文件加载的字节码，保证虚拟机执行时不会越界：
+//操作码和操作数完整，常量和变量的下标有效，跳转目标是某条指令的开头，
+//各条执行路径上栈的深度一致且不会弹出空栈，函数以返回指令结束
+
+import (
+	"fmt"
+	"monkey_Interpreter/code"
+	"monkey_Interpreter/object"
+)
+
+type verifier struct {
+	bytecode *Bytecode
+	main     *object.CompiledFunction
+	parents  map[*object.CompiledFunction][]*object.CompiledFunction //创建闭包的函数
+	outers   map[*object.CompiledFunction][][2]int                   //OpGetOuter的层数和下标
+}
+
+func verify(b *Bytecode) error {
+	v := &verifier{
+		bytecode: b,
+		main:     &object.CompiledFunction{Instructions: b.Instructions},
+		parents:  map[*object.CompiledFunction][]*object.CompiledFunction{},
+		outers:   map[*object.CompiledFunction][][2]int{},
+	}
+
+	functions := []*object.CompiledFunction{v.main}
+	for _, c := range b.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			functions = append(functions, fn)
+		}
+	}
+	for _, fn := range functions {
+		if err := v.function(fn); err != nil {
+			return err
+		}
+	}
+
+	//外层函数的局部变量 创建该函数的每一条路径上，向外depth层都必须是有足够局部变量的函数
+	for fn, refs := range v.outers {
+		for _, ref := range refs {
+			for _, outer := range v.ancestors(fn, ref[0], map[*object.CompiledFunction]bool{}) {
+				if outer == v.main || ref[1] >= outer.NumLocals {
+					return v.errorf(fn, "invalid outer variable reference %d %d", ref[0], ref[1])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 向外depth层的函数，未被任何函数创建的函数不会执行，结果为空
+func (v *verifier) ancestors(fn *object.CompiledFunction, depth int, seen map[*object.CompiledFunction]bool) []*object.CompiledFunction {
+	if depth == 0 {
+		if seen[fn] {
+			return nil
+		}
+		seen[fn] = true
+		return []*object.CompiledFunction{fn}
+	}
+	var result []*object.CompiledFunction
+	for _, parent := range v.parents[fn] {
+		result = append(result, v.ancestors(parent, depth-1, seen)...)
+	}
+	return result
+}
+
+func (v *verifier) errorf(fn *object.CompiledFunction, format string, a ...interface{}) error {
+	name := "main"
+	if fn != v.main {
+		name = "function " + fn.Name
+		if fn.Name == "" {
+			name = "anonymous function"
+		}
+	}
+	return fmt.Errorf("mkc: %s: %s", name, fmt.Sprintf(format, a...))
+}
+
+type instruction struct {
+	op       code.Opcode
+	operands []int
+	next     int //下一条指令的偏移量
+}
+
+func (v *verifier) function(fn *object.CompiledFunction) error {
+	ins := fn.Instructions
+	decoded := map[int]instruction{}
+
+	for offset := 0; offset < len(ins); {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			return v.errorf(fn, "%s at %d", err, offset)
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if offset+1+width > len(ins) {
+			return v.errorf(fn, "truncated %s at %d", def.Name, offset)
+		}
+		operands, read := code.ReadOperands(def, ins[offset+1:])
+		inst := instruction{op: code.Opcode(ins[offset]), operands: operands, next: offset + 1 + read}
+		if err := v.operands(fn, inst); err != nil {
+			return v.errorf(fn, "%s at %d: %s", def.Name, offset, err)
+		}
+		decoded[offset] = inst
+		offset = inst.next
+	}
+
+	return v.stack(fn, decoded)
+}
+
+// 检查操作数引用的常量、变量是否存在
+func (v *verifier) operands(fn *object.CompiledFunction, inst instruction) error {
+	constant := func(i int) (object.Object, error) {
+		if i >= len(v.bytecode.Constants) {
+			return nil, fmt.Errorf("constant %d out of range", i)
+		}
+		return v.bytecode.Constants[i], nil
+	}
+
+	switch inst.op {
+	case code.OpConstant:
+		_, err := constant(inst.operands[0])
+		return err
+	case code.OpGetName, code.OpProperty:
+		c, err := constant(inst.operands[0])
+		if err != nil {
+			return err
+		}
+		if _, ok := c.(*object.String); !ok {
+			return fmt.Errorf("constant %d is not a name", inst.operands[0])
+		}
+	case code.OpClosure:
+		c, err := constant(inst.operands[0])
+		if err != nil {
+			return err
+		}
+		child, ok := c.(*object.CompiledFunction)
+		if !ok {
+			return fmt.Errorf("constant %d is not a function", inst.operands[0])
+		}
+		v.parents[child] = append(v.parents[child], fn)
+	case code.OpGetGlobal, code.OpSetGlobal:
+		if inst.operands[0] >= len(v.bytecode.Globals) {
+			return fmt.Errorf("global %d out of range", inst.operands[0])
+		}
+	case code.OpGetLocal, code.OpSetLocal:
+		if inst.operands[0] >= fn.NumLocals {
+			return fmt.Errorf("local %d out of range", inst.operands[0])
+		}
+	case code.OpGetOuter:
+		if inst.operands[0] == 0 {
+			return fmt.Errorf("outer depth must be positive")
+		}
+		v.outers[fn] = append(v.outers[fn], [2]int{inst.operands[0], inst.operands[1]})
+	case code.OpHash:
+		if inst.operands[0]%2 != 0 {
+			return fmt.Errorf("odd number of hash items")
+		}
+	case code.OpSlice:
+		if inst.operands[0] > code.SliceStart|code.SliceEnd|code.SliceStep {
+			return fmt.Errorf("invalid slice flags %d", inst.operands[0])
+		}
+	}
+	return nil
+}
+
+// 指令弹出和压入栈的元素个数
+func stackEffect(inst instruction) (pops, pushes int) {
+	switch inst.op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetOuter, code.OpGetName, code.OpClosure:
+		return 0, 1
+	case code.OpPop, code.OpSetGlobal, code.OpSetLocal, code.OpJumpNotTruthy, code.OpReturnValue:
+		return 1, 0
+	case code.OpMinus, code.OpBang, code.OpProperty:
+		return 1, 1
+	case code.OpArray, code.OpHash:
+		return inst.operands[0], 1
+	case code.OpSlice:
+		pops = 1
+		for flags := inst.operands[0]; flags != 0; flags >>= 1 {
+			pops += flags & 1
+		}
+		return pops, 1
+	case code.OpCall:
+		return inst.operands[0] + 1, 1
+	case code.OpJump, code.OpReturn:
+		return 0, 0
+	default: //二元运算和索引
+		return 2, 1
+	}
+}
+
+// 沿所有可能的执行路径计算栈深度
+func (v *verifier) stack(fn *object.CompiledFunction, decoded map[int]instruction) error {
+	end := len(fn.Instructions)
+	depths := map[int]int{}
+	work := []int{0}
+	depths[0] = 0
+
+	reach := func(target, depth int) error {
+		if target == end && fn == v.main {
+			return nil
+		}
+		if _, ok := decoded[target]; !ok {
+			if target == end {
+				return fmt.Errorf("function does not end with a return")
+			}
+			return fmt.Errorf("jump to %d is not an instruction", target)
+		}
+		if d, ok := depths[target]; ok {
+			if d != depth {
+				return fmt.Errorf("inconsistent stack depth at %d", target)
+			}
+			return nil
+		}
+		depths[target] = depth
+		work = append(work, target)
+		return nil
+	}
+
+	if end == 0 {
+		if fn == v.main {
+			return nil
+		}
+		return v.errorf(fn, "function does not end with a return")
+	}
+
+	for len(work) > 0 {
+		offset := work[len(work)-1]
+		work = work[:len(work)-1]
+		inst := decoded[offset]
+
+		pops, pushes := stackEffect(inst)
+		depth := depths[offset]
+		if depth < pops {
+			return v.errorf(fn, "stack underflow at %d", offset)
+		}
+		depth += pushes - pops
+
+		var err error
+		switch inst.op {
+		case code.OpReturnValue, code.OpReturn:
+			continue
+		case code.OpJump:
+			err = reach(inst.operands[0], depth)
+		case code.OpJumpNotTruthy:
+			if err = reach(inst.operands[0], depth); err == nil {
+				err = reach(inst.next, depth)
+			}
+		default:
+			err = reach(inst.next, depth)
+		}
+		if err != nil {
+			return v.errorf(fn, "%s", err)
+		}
+	}
+	return nil
+}
@@ -2,7 +2,11 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
 	"monkey_Interpreter/object"
+	"os"
+	"sort"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
@@ -12,10 +16,12 @@ var builtins = map[string]*object.Builtin{
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
-			case *object.String: //字符串长度
-				return &object.Integer{Value: int64(len(arg.Value))}
+			case *object.String: //字符串长度 按字符(rune)计
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array: //数组长度
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash: //哈希表键值对个数
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
 				return newError("argument to 'len' not supported,got %s", args[0].Type())
 			}
@@ -98,12 +104,96 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 
-	"put": &object.Builtin{
+	"put": putTo(os.Stdout),
+}
+
+// 输出到w的put，每个值一行
+func putTo(w io.Writer) *object.Builtin {
+	return &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(w, arg.Inspect())
 			}
 			return NULL
 		},
-	},
+	}
+}
+
+// env中的脚本调用put时输出到w而不是标准输出
+// 与GrantFileSystem一样绑定在环境中，不影响其他解释器
+func SetOutput(env *object.Environment, w io.Writer) {
+	env.Set("put", putTo(w))
+}
+
+// 内置模块 通过属性访问使用其成员，如math.sqrt(2)
+var modules = map[string]*object.Hash{}
+
+// 注册内置模块，成员按名称排序以保证输出稳定
+func registerModule(name string, members map[string]object.Object) {
+	names := make([]string, 0, len(members))
+	for n := range members {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	module := object.NewHash()
+	for _, n := range names {
+		module.Set(&object.String{Value: n}, members[n])
+	}
+	modules[name] = module
+}
+
+// 注册一组内置函数，供各个builtins_*.go在init中调用
+func registerBuiltins(set map[string]*object.Builtin) {
+	for name, builtin := range set {
+		builtins[name] = builtin
+	}
+}
+
+// 检查参数个数与类型，不符时返回错误
+func checkArgs(name string, args []object.Object, types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), len(types))
+	}
+	for i, t := range types {
+		if args[i].Type() != t {
+			return newError("argument %d to '%s' must be %s,got %s", i+1, name, t, args[i].Type())
+		}
+	}
+	return nil
+}
+
+// 检查可变参数个数是否在[min,max]之间
+func checkArgCount(args []object.Object, min, max int) *object.Error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), min)
+		}
+		return newError("wrong number of arguments. got=%d, want=%d..%d", len(args), min, max)
+	}
+	return nil
+}
+
+// 检查第i个参数的类型
+func checkArgType(name string, args []object.Object, i int, t object.ObjectType) *object.Error {
+	if args[i].Type() != t {
+		return newError("argument %d to '%s' must be %s,got %s", i+1, name, t, args[i].Type())
+	}
+	return nil
+}
+
+// 所有内置函数名、模块名及模块成员（如math.sqrt），按字母排序
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins)+len(modules))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name, module := range modules {
+		names = append(names, name)
+		for _, pair := range module.OrderedPairs() {
+			names = append(names, name+"."+pair.Key.(*object.String).Value)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
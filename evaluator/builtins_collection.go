@@ -0,0 +1,421 @@
+package evaluator
+
+//数组相关的高阶内置函数 回调通过applyFunction执行用户函数
+
+import (
+	"monkey_Interpreter/object"
+	"sort"
+)
+
+// range最多生成的元素个数
+const maxRangeLength = 1 << 24
+
+func init() {
+	registerBuiltins(collectionBuiltins)
+}
+
+var collectionBuiltins = map[string]*object.Builtin{
+	"map": &object.Builtin{ //map(arr, fn) 对每个元素调用fn，返回结果数组
+		Fn: func(args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunction("map", args)
+			if err != nil {
+				return err
+			}
+			result := make([]object.Object, len(arr.Elements))
+			for i, e := range arr.Elements {
+				val := applyFunction(fn, []object.Object{e})
+				if isError(val) {
+					return val
+				}
+				result[i] = val
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+
+	"filter": &object.Builtin{ //filter(arr, fn) 保留fn返回真值的元素
+		Fn: func(args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunction("filter", args)
+			if err != nil {
+				return err
+			}
+			result := []object.Object{}
+			for _, e := range arr.Elements {
+				val := applyFunction(fn, []object.Object{e})
+				if isError(val) {
+					return val
+				}
+				if isTruthy(val) {
+					result = append(result, e)
+				}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+
+	"reduce": &object.Builtin{ //reduce(arr, fn) 或 reduce(arr, fn, initial)，fn(acc, x)
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 3); err != nil {
+				return err
+			}
+			arr, fn, err := arrayAndFunction("reduce", args[:2])
+			if err != nil {
+				return err
+			}
+
+			elements := arr.Elements
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else {
+				if len(elements) == 0 {
+					return newError("reduce of empty array with no initial value")
+				}
+				acc, elements = elements[0], elements[1:]
+			}
+
+			for _, e := range elements {
+				acc = applyFunction(fn, []object.Object{acc, e})
+				if isError(acc) {
+					return acc
+				}
+			}
+			return acc
+		},
+	},
+
+	"sort": &object.Builtin{ //sort(arr) 升序排序; sort(arr, fn) fn(a, b)为真表示a排在b前
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkArgType("sort", args, 0, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			var less func(a, b object.Object) (bool, object.Object)
+			if len(args) == 2 {
+				if !isCallable(args[1]) {
+					return newError("argument 2 to 'sort' must be FUNCTION,got %s", args[1].Type())
+				}
+				less = func(a, b object.Object) (bool, object.Object) {
+					val := applyFunction(args[1], []object.Object{a, b})
+					if isError(val) {
+						return false, val
+					}
+					return isTruthy(val), nil
+				}
+			} else {
+				less = func(a, b object.Object) (bool, object.Object) {
+					c, err := object.Compare(a, b)
+					if err != nil {
+						return false, newError("%s", err)
+					}
+					return c < 0, nil
+				}
+			}
+
+			elements := copyElements(args[0].(*object.Array))
+			var sortErr object.Object
+			sort.SliceStable(elements, func(i, j int) bool {
+				if sortErr != nil {
+					return false
+				}
+				ok, err := less(elements[i], elements[j])
+				if err != nil {
+					sortErr = err
+				}
+				return ok
+			})
+			if sortErr != nil {
+				return sortErr
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"reverse": &object.Builtin{ //反转数组或字符串
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+			switch arg := args[0].(type) {
+			case *object.Array:
+				elements := copyElements(arg)
+				for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+					elements[i], elements[j] = elements[j], elements[i]
+				}
+				return &object.Array{Elements: elements}
+			case *object.String:
+				runes := []rune(arg.Value)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+				return &object.String{Value: string(runes)}
+			default:
+				return newError("argument to 'reverse' must be ARRAY or STRING,got %s", args[0].Type())
+			}
+		},
+	},
+
+	"zip": &object.Builtin{ //zip(a, b, ...) 按位置组合，长度取最短的数组
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want>=1", len(args))
+			}
+			length := -1
+			for i := range args {
+				if err := checkArgType("zip", args, i, object.ARRAY_OBJ); err != nil {
+					return err
+				}
+				if n := len(args[i].(*object.Array).Elements); length < 0 || n < length {
+					length = n
+				}
+			}
+
+			result := make([]object.Object, length)
+			for i := 0; i < length; i++ {
+				tuple := make([]object.Object, len(args))
+				for j, arg := range args {
+					tuple[j] = arg.(*object.Array).Elements[i]
+				}
+				result[i] = &object.Array{Elements: tuple}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+
+	"enumerate": &object.Builtin{ //enumerate(arr) 返回[[0, x0], [1, x1], ...]
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("enumerate", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+			elements := args[0].(*object.Array).Elements
+			result := make([]object.Object, len(elements))
+			for i, e := range elements {
+				result[i] = &object.Array{Elements: []object.Object{&object.Integer{Value: int64(i)}, e}}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+
+	"range": &object.Builtin{ //range(end)、range(start, end)、range(start, end, step)
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 3); err != nil {
+				return err
+			}
+			bounds := make([]int64, len(args))
+			for i := range args {
+				if err := checkArgType("range", args, i, object.INTEGER_OBJ); err != nil {
+					return err
+				}
+				bounds[i] = args[i].(*object.Integer).Value
+			}
+
+			start, end, step := int64(0), bounds[0], int64(1)
+			if len(bounds) > 1 {
+				start, end = bounds[0], bounds[1]
+			}
+			if len(bounds) > 2 {
+				step = bounds[2]
+			}
+			if step == 0 {
+				return newError("range step cannot be zero")
+			}
+
+			//先算出元素个数，在uint64中相减避免溢出
+			var count uint64
+			if step > 0 && start < end {
+				count = (uint64(end)-uint64(start)-1)/uint64(step) + 1
+			} else if step < 0 && start > end {
+				count = (uint64(start)-uint64(end)-1)/uint64(-step) + 1
+			}
+			if count > maxRangeLength {
+				return newError("range is too large,got %d elements", count)
+			}
+
+			result := make([]object.Object, count)
+			for i, v := 0, start; i < len(result); i, v = i+1, v+step {
+				result[i] = &object.Integer{Value: v}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+
+	"any": &object.Builtin{ //any(arr) 或 any(arr, fn) 存在真值元素
+		Fn: func(args ...object.Object) object.Object {
+			return testElements("any", args, true)
+		},
+	},
+
+	"all": &object.Builtin{ //all(arr) 或 all(arr, fn) 全部为真值
+		Fn: func(args ...object.Object) object.Object {
+			return testElements("all", args, false)
+		},
+	},
+
+	"sum": &object.Builtin{ //数组求和 含浮点数时结果为浮点数
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("sum", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+			var total object.Object = &object.Integer{Value: 0}
+			for _, e := range args[0].(*object.Array).Elements {
+				if !isNumber(e) {
+					return newError("unsupported element for 'sum': %s", e.Type())
+				}
+				total = evalInfixExpression("+", total, e)
+			}
+			return total
+		},
+	},
+
+	"min": &object.Builtin{ //min(arr) 或 min(a, b, ...)
+		Fn: func(args ...object.Object) object.Object {
+			return extremum("min", args, -1)
+		},
+	},
+
+	"max": &object.Builtin{ //max(arr) 或 max(a, b, ...)
+		Fn: func(args ...object.Object) object.Object {
+			return extremum("max", args, 1)
+		},
+	},
+
+	"unique": &object.Builtin{ //去重，保留首次出现的顺序
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("unique", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+			seen := object.NewHash()
+			result := []object.Object{}
+			for _, e := range args[0].(*object.Array).Elements {
+				if !object.IsHashable(e) {
+					return newError("unusable as hash key: %s", e.Type())
+				}
+				if _, ok := seen.Get(e); !ok {
+					seen.Set(e, TRUE)
+					result = append(result, e)
+				}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+
+	"flatten": &object.Builtin{ //flatten(arr) 展开一层; flatten(arr, depth) 展开depth层，depth<0时全部展开
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkArgType("flatten", args, 0, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+			depth := int64(1)
+			if len(args) == 2 {
+				if err := checkArgType("flatten", args, 1, object.INTEGER_OBJ); err != nil {
+					return err
+				}
+				depth = args[1].(*object.Integer).Value
+			}
+			return &object.Array{Elements: flattenElements(args[0].(*object.Array).Elements, depth)}
+		},
+	},
+}
+
+// 判断对象能否被调用
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin, object.Callable:
+		return true
+	default:
+		return false
+	}
+}
+
+// 检查(arr, fn)形式的参数
+func arrayAndFunction(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	if err := checkArgType(name, args, 0, object.ARRAY_OBJ); err != nil {
+		return nil, nil, err
+	}
+	if !isCallable(args[1]) {
+		return nil, nil, newError("argument 2 to '%s' must be FUNCTION,got %s", name, args[1].Type())
+	}
+	return args[0].(*object.Array), args[1], nil
+}
+
+// 复制数组元素，避免修改原数组
+func copyElements(arr *object.Array) []object.Object {
+	elements := make([]object.Object, len(arr.Elements))
+	copy(elements, arr.Elements)
+	return elements
+}
+
+// any/all的公共实现，stopOn为遇到即可提前返回的判定结果
+func testElements(name string, args []object.Object, stopOn bool) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+	if err := checkArgType(name, args, 0, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+	if len(args) == 2 && !isCallable(args[1]) {
+		return newError("argument 2 to '%s' must be FUNCTION,got %s", name, args[1].Type())
+	}
+
+	for _, e := range args[0].(*object.Array).Elements {
+		val := e
+		if len(args) == 2 {
+			val = applyFunction(args[1], []object.Object{e})
+			if isError(val) {
+				return val
+			}
+		}
+		if isTruthy(val) == stopOn {
+			return nativeBoolToBooleanObject(stopOn)
+		}
+	}
+	return nativeBoolToBooleanObject(!stopOn)
+}
+
+// min/max的公共实现，sign为-1取最小值，1取最大值
+func extremum(name string, args []object.Object, sign int) object.Object {
+	elements := args
+	if len(args) == 1 {
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return newError("argument to '%s' must be ARRAY,got %s", name, args[0].Type())
+		}
+		elements = arr.Elements
+	}
+	if len(elements) == 0 {
+		return NULL
+	}
+
+	best := elements[0]
+	for _, e := range elements[1:] {
+		c, err := object.Compare(e, best)
+		if err != nil {
+			return newError("%s", err)
+		}
+		if c*sign > 0 {
+			best = e
+		}
+	}
+	return best
+}
+
+// 递归展开嵌套数组
+func flattenElements(elements []object.Object, depth int64) []object.Object {
+	result := []object.Object{}
+	for _, e := range elements {
+		if arr, ok := e.(*object.Array); ok && depth != 0 {
+			result = append(result, flattenElements(arr.Elements, depth-1)...)
+		} else {
+			result = append(result, e)
+		}
+	}
+	return result
+}
@@ -0,0 +1,229 @@
+package evaluator
+
+//文件读写内置函数 默认不可用，只有宿主程序调用GrantFileSystem授权后才会注册到环境中
+//脚本中的路径均相对于授权的根目录，经由..或符号链接逃出根目录的访问都会返回错误
+//检查路径与使用路径之间，其中的目录可能被并发地换成指向外部的符号链接：
+//  read_file、write_file、list_dir打开后再检查句柄的真实路径，不会读到或改写根目录外已有的文件，
+//  但write_file可能已在外部创建了空文件
+//  exists和mkdir只检查路径，不能防止这种替换
+//因此沙箱不能防御同时修改根目录的不可信进程
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"monkey_Interpreter/object"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// 打开后发现文件不在根目录下
+var errEscape = errors.New("path escapes the sandbox")
+
+// 授权访问的根目录
+type sandbox struct {
+	root string //已解析符号链接的绝对路径
+}
+
+// 授予env中的脚本访问root目录的权限，并注册read_file、write_file、list_dir、exists、mkdir
+func GrantFileSystem(env *object.Environment, root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", root)
+	}
+
+	sb := &sandbox{root: real}
+	for name, fn := range map[string]object.BuiltinFunction{
+		"read_file":  sb.readFile,
+		"write_file": sb.writeFile,
+		"list_dir":   sb.listDir,
+		"exists":     sb.exists,
+		"mkdir":      sb.mkdir,
+	} {
+		env.Set(name, &object.Builtin{Fn: fn})
+	}
+	return nil
+}
+
+// 将脚本中的路径解析为根目录下的真实路径
+func (sb *sandbox) resolve(name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path escapes the sandbox: %s", name)
+	}
+
+	real, err := resolveExisting(filepath.Join(sb.root, rel))
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s", name)
+	}
+	if !sb.contains(real) {
+		return "", fmt.Errorf("path escapes the sandbox: %s", name)
+	}
+	return real, nil
+}
+
+// 判断真实路径是否在根目录下
+func (sb *sandbox) contains(real string) bool {
+	inside, err := filepath.Rel(sb.root, real)
+	return err == nil && filepath.IsLocal(inside)
+}
+
+// 打开已解析的路径，并检查打开的文件仍在根目录下
+// 解析与打开之间路径中的目录可能被换成指向外部的符号链接，因此以句柄的真实路径为准
+func (sb *sandbox) open(path string, flag int) (*os.File, error) {
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	real, err := openedPath(f)
+	if err != nil || !sb.contains(real) {
+		f.Close()
+		return nil, errEscape
+	}
+	return f, nil
+}
+
+// 解析路径中已存在部分的符号链接，不存在的部分原样拼接
+func resolveExisting(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if _, lerr := os.Lstat(path); lerr == nil { //路径存在却无法解析，如悬空的符号链接
+		return "", err
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return "", err
+	}
+	realParent, err := resolveExisting(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realParent, filepath.Base(path)), nil
+}
+
+// 检查参数并解析第一个参数中的路径
+func (sb *sandbox) pathArg(name string, args []object.Object, types ...object.ObjectType) (string, *object.Error) {
+	if err := checkArgs(name, args, types...); err != nil {
+		return "", err
+	}
+	path, err := sb.resolve(args[0].(*object.String).Value)
+	if err != nil {
+		return "", newError("%s: %s", name, err)
+	}
+	return path, nil
+}
+
+// 生成错误信息时隐藏宿主机上的真实路径
+func fsError(name string, path object.Object, err error) *object.Error {
+	if err == errEscape {
+		return newError("%s: %s: %s", name, err, path.(*object.String).Value)
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return newError("%s: %s: %s", name, path.(*object.String).Value, err)
+}
+
+// read_file(path) 以字符串返回文件内容
+func (sb *sandbox) readFile(args ...object.Object) object.Object {
+	path, err := sb.pathArg("read_file", args, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	f, openErr := sb.open(path, os.O_RDONLY)
+	if openErr != nil {
+		return fsError("read_file", args[0], openErr)
+	}
+	defer f.Close()
+	data, readErr := io.ReadAll(f)
+	if readErr != nil {
+		return fsError("read_file", args[0], readErr)
+	}
+	return &object.String{Value: string(data)}
+}
+
+// write_file(path, content) 写入文件，已存在时覆盖
+func (sb *sandbox) writeFile(args ...object.Object) object.Object {
+	path, err := sb.pathArg("write_file", args, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	//检查之后再清空原有内容，不会截断根目录外的文件
+	f, openErr := sb.open(path, os.O_WRONLY|os.O_CREATE)
+	if openErr != nil {
+		return fsError("write_file", args[0], openErr)
+	}
+	defer f.Close()
+	writeErr := f.Truncate(0)
+	if writeErr == nil {
+		_, writeErr = f.WriteString(args[1].(*object.String).Value)
+	}
+	if writeErr == nil {
+		writeErr = f.Close()
+	}
+	if writeErr != nil {
+		return fsError("write_file", args[0], writeErr)
+	}
+	return NULL
+}
+
+// list_dir(path) 返回目录下的文件名，按名称排序
+func (sb *sandbox) listDir(args ...object.Object) object.Object {
+	path, err := sb.pathArg("list_dir", args, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	f, openErr := sb.open(path, os.O_RDONLY)
+	if openErr != nil {
+		return fsError("list_dir", args[0], openErr)
+	}
+	defer f.Close()
+	names, readErr := f.Readdirnames(-1)
+	if readErr != nil {
+		return fsError("list_dir", args[0], readErr)
+	}
+	sort.Strings(names)
+	return stringsToArray(names)
+}
+
+// exists(path) 文件或目录是否存在
+func (sb *sandbox) exists(args ...object.Object) object.Object {
+	path, err := sb.pathArg("exists", args, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(path)
+	return nativeBoolToBooleanObject(statErr == nil)
+}
+
+// mkdir(path) 创建目录，包括不存在的上级目录
+func (sb *sandbox) mkdir(args ...object.Object) object.Object {
+	path, err := sb.pathArg("mkdir", args, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	if mkErr := os.MkdirAll(path, 0755); mkErr != nil {
+		return fsError("mkdir", args[0], mkErr)
+	}
+	return NULL
+}
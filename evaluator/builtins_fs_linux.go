@@ -0,0 +1,13 @@
+//go:build linux
+
+package evaluator
+
+import (
+	"os"
+	"strconv"
+)
+
+// 打开的文件的真实路径，由/proc/self/fd中的链接得到
+func openedPath(f *os.File) (string, error) {
+	return os.Readlink("/proc/self/fd/" + strconv.Itoa(int(f.Fd())))
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package evaluator
+
+import (
+	"errors"
+	"os"
+)
+
+// 没有可移植的方法取得句柄的路径，只能重新解析打开时的路径，并确认它仍指向打开的文件
+// 解析之后路径可能再次被替换，因此在这些系统上检查不是原子的
+func openedPath(f *os.File) (string, error) {
+	real, err := resolveExisting(f.Name())
+	if err != nil {
+		return "", err
+	}
+	opened, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	current, err := os.Stat(real)
+	if err != nil {
+		return "", err
+	}
+	if !os.SameFile(opened, current) {
+		return "", errors.New("file was replaced while opening")
+	}
+	return real, nil
+}
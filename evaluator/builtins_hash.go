@@ -0,0 +1,112 @@
+package evaluator
+
+//哈希表相关的内置函数 均不修改原哈希表，而是返回新的哈希表
+
+import (
+	"monkey_Interpreter/object"
+)
+
+func init() {
+	registerBuiltins(hashBuiltins)
+}
+
+var hashBuiltins = map[string]*object.Builtin{
+	"keys": &object.Builtin{ //所有键组成的数组
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("keys", args, object.HASH_OBJ); err != nil {
+				return err
+			}
+			result := []object.Object{}
+			for _, pair := range args[0].(*object.Hash).OrderedPairs() {
+				result = append(result, pair.Key)
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+
+	"values": &object.Builtin{ //所有值组成的数组
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("values", args, object.HASH_OBJ); err != nil {
+				return err
+			}
+			result := []object.Object{}
+			for _, pair := range args[0].(*object.Hash).OrderedPairs() {
+				result = append(result, pair.Value)
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+
+	"items": &object.Builtin{ //所有键值对，形如[[k, v], ...]
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("items", args, object.HASH_OBJ); err != nil {
+				return err
+			}
+			result := []object.Object{}
+			for _, pair := range args[0].(*object.Hash).OrderedPairs() {
+				result = append(result, &object.Array{Elements: []object.Object{pair.Key, pair.Value}})
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+
+	"has": &object.Builtin{ //has(h, key) 判断键是否存在
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return err
+			}
+			if err := checkArgType("has", args, 0, object.HASH_OBJ); err != nil {
+				return err
+			}
+			if !object.IsHashable(args[1]) {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			_, ok := args[0].(*object.Hash).Get(args[1])
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+
+	"delete": &object.Builtin{ //delete(h, key) 返回删除该键后的新哈希表
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return err
+			}
+			if err := checkArgType("delete", args, 0, object.HASH_OBJ); err != nil {
+				return err
+			}
+			if !object.IsHashable(args[1]) {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			hash := copyHash(args[0].(*object.Hash))
+			hash.Delete(args[1])
+			return hash
+		},
+	},
+
+	"merge": &object.Builtin{ //merge(a, b, ...) 合并多个哈希表，键相同时后者覆盖前者
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want>=1", len(args))
+			}
+			hash := object.NewHash()
+			for i := range args {
+				if err := checkArgType("merge", args, i, object.HASH_OBJ); err != nil {
+					return err
+				}
+				for _, pair := range args[i].(*object.Hash).OrderedPairs() {
+					hash.Set(pair.Key, pair.Value)
+				}
+			}
+			return hash
+		},
+	},
+}
+
+// 复制哈希表，保留键的顺序
+func copyHash(src *object.Hash) *object.Hash {
+	hash := object.NewHash()
+	for _, pair := range src.OrderedPairs() {
+		hash.Set(pair.Key, pair.Value)
+	}
+	return hash
+}
@@ -0,0 +1,281 @@
+package evaluator
+
+//JSON编解码内置函数
+//哈希表<->对象 数组<->数组 字符串、整数、浮点数、布尔、null一一对应，对象的键保持原有顺序
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"monkey_Interpreter/object"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	registerBuiltins(jsonBuiltins)
+}
+
+var jsonBuiltins = map[string]*object.Builtin{
+	"json_encode": &object.Builtin{ //json_encode(value) 或 json_encode(value, {"indent": 2})
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 2); err != nil {
+				return err
+			}
+
+			indent := 0
+			if len(args) == 2 {
+				if err := checkArgType("json_encode", args, 1, object.HASH_OBJ); err != nil {
+					return err
+				}
+				if val, ok := args[1].(*object.Hash).Get(&object.String{Value: "indent"}); ok {
+					n, ok := val.(*object.Integer)
+					if !ok || n.Value < 0 {
+						return newError("json_encode: indent must be a non-negative INTEGER, got %s", val.Inspect())
+					}
+					if n.Value > maxStringLength {
+						return newError("json_encode: indent is too large,got %d", n.Value)
+					}
+					indent = int(n.Value)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := encodeJSON(&buf, args[0]); err != nil {
+				return newError("json_encode: %s", err)
+			}
+			if indent == 0 {
+				return &object.String{Value: buf.String()}
+			}
+
+			if size := indentedSize(buf.Bytes(), indent); size > maxStringLength {
+				return newError("json_encode: indented result is too large,got %d bytes", size)
+			}
+			var out bytes.Buffer
+			json.Indent(&out, buf.Bytes(), "", strings.Repeat(" ", indent))
+			return &object.String{Value: out.String()}
+		},
+	},
+
+	"json_parse": &object.Builtin{ //json_parse(s) 失败时返回带行号、列号的错误
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("json_parse", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			input := args[0].(*object.String).Value
+
+			dec := json.NewDecoder(strings.NewReader(input))
+			dec.UseNumber()
+			value, err := decodeJSON(dec)
+			if err == nil {
+				if _, extra := dec.Token(); extra != io.EOF {
+					err = fmt.Errorf("unexpected data after top-level value")
+				}
+			}
+			if err != nil {
+				line, column := jsonErrorPosition(input, err, dec.InputOffset())
+				return newError("json_parse: %s at line %d, column %d", jsonErrorMessage(err), line, column)
+			}
+			return value
+		},
+	},
+}
+
+// 将对象编码为紧凑格式的JSON
+func encodeJSON(buf *bytes.Buffer, obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		buf.WriteString("null")
+	case *object.Boolean:
+		fmt.Fprintf(buf, "%t", obj.Value)
+	case *object.Integer:
+		fmt.Fprintf(buf, "%d", obj.Value)
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return fmt.Errorf("cannot serialize %s", obj.Inspect())
+		}
+		b, _ := json.Marshal(obj.Value)
+		buf.Write(b)
+		if !bytes.ContainsAny(b, ".eE") { //保留小数点，解码后仍为浮点数
+			buf.WriteString(".0")
+		}
+	case *object.String:
+		writeJSONString(buf, obj.Value)
+	case *object.Array:
+		buf.WriteByte('[')
+		for i, e := range obj.Elements {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case *object.Hash:
+		buf.WriteByte('{')
+		for i, pair := range obj.OrderedPairs() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return fmt.Errorf("hash key must be STRING, got %s", pair.Key.Type())
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, key.Value)
+			buf.WriteByte(':')
+			if err := encodeJSON(buf, pair.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot serialize %s", obj.Type())
+	}
+	return nil
+}
+
+// 缩进后结果长度的上界，超过maxStringLength时提前返回
+// 每个括号和逗号之后换行并缩进indent*depth个空格，结果可能远大于indent本身
+func indentedSize(data []byte, indent int) int64 {
+	size := int64(len(data))
+	depth := int64(0)
+	inString, escaped := false, false
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+			continue
+		case ':':
+			size++ //冒号后的空格
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case ',':
+		default:
+			continue
+		}
+		size += 1 + int64(indent)*depth
+		if size > maxStringLength {
+			return size
+		}
+	}
+	return size
+}
+
+// 写入转义后的JSON字符串，不转义HTML字符
+func writeJSONString(buf *bytes.Buffer, s string) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	buf.Write(bytes.TrimRight(out.Bytes(), "\n"))
+}
+
+// 按词法单元递归解码，以保留对象中键的顺序
+func decodeJSON(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		return nativeBoolToBooleanObject(tok), nil
+	case string:
+		return &object.String{Value: tok}, nil
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return &object.Integer{Value: i}, nil
+		}
+		f, err := tok.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("number %s out of range", tok)
+		}
+		return &object.Float{Value: f}, nil
+	case json.Delim:
+		if tok == '[' {
+			elements := []object.Object{}
+			for dec.More() {
+				e, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, e)
+			}
+			if _, err := dec.Token(); err != nil { //读取]
+				return nil, err
+			}
+			return &object.Array{Elements: elements}, nil
+		}
+
+		hash := object.NewHash()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(&object.String{Value: key.(string)}, value)
+		}
+		if _, err := dec.Token(); err != nil { //读取}
+			return nil, err
+		}
+		return hash, nil
+	}
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
+
+// 去掉encoding/json错误信息中的包名前缀
+func jsonErrorMessage(err error) string {
+	if isJSONEOF(err) {
+		return "unexpected end of input"
+	}
+	return strings.TrimPrefix(err.Error(), "json: ")
+}
+
+// 输入在值结束前中断
+func isJSONEOF(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		strings.Contains(err.Error(), "unexpected end of JSON input")
+}
+
+// 计算出错位置的行号与列号（均从1开始，列按字符计）
+func jsonErrorPosition(input string, err error, offset int64) (int, int) {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+		if !isJSONEOF(err) && offset > 0 {
+			offset-- //Offset指向出错字符之后
+		}
+	}
+	if offset > int64(len(input)) {
+		offset = int64(len(input))
+	}
+
+	before := input[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+	return line, column
+}
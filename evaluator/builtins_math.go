@@ -0,0 +1,279 @@
+package evaluator
+
+//math模块 通过math.sqrt(2)等形式调用，整数与浮点数参数均可接受
+
+import (
+	"math"
+	"math/rand"
+	"monkey_Interpreter/object"
+	"sync"
+	"time"
+)
+
+// 随机数源 默认以当前时间为种子，可通过SeedRandom或math.seed(n)固定
+// rand.Rand不能并发使用，多个解释器同时运行时由randomMu保护
+var (
+	randomMu sync.Mutex
+	random   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// 设置随机数种子，使rand_int、rand_float的结果可复现
+func SeedRandom(seed int64) {
+	randomMu.Lock()
+	defer randomMu.Unlock()
+	random = rand.New(rand.NewSource(seed))
+}
+
+func init() {
+	registerModule("math", map[string]object.Object{
+		"pi": &object.Float{Value: math.Pi},
+		"e":  &object.Float{Value: math.E},
+
+		"abs":   &object.Builtin{Fn: mathAbs},
+		"pow":   &object.Builtin{Fn: mathPow},
+		"sqrt":  floatFunction("sqrt", math.Sqrt),
+		"exp":   floatFunction("exp", math.Exp),
+		"log":   &object.Builtin{Fn: mathLog},
+		"log10": floatFunction("log10", math.Log10),
+		"sin":   floatFunction("sin", math.Sin),
+		"cos":   floatFunction("cos", math.Cos),
+		"tan":   floatFunction("tan", math.Tan),
+		"asin":  floatFunction("asin", math.Asin),
+		"acos":  floatFunction("acos", math.Acos),
+		"atan":  floatFunction("atan", math.Atan),
+		"atan2": &object.Builtin{Fn: mathAtan2},
+		"floor": roundFunction("floor", math.Floor),
+		"ceil":  roundFunction("ceil", math.Ceil),
+		"round": roundFunction("round", math.Round),
+		"min":   &object.Builtin{Fn: func(args ...object.Object) object.Object { return mathExtremum("min", args, -1) }},
+		"max":   &object.Builtin{Fn: func(args ...object.Object) object.Object { return mathExtremum("max", args, 1) }},
+		"gcd":   &object.Builtin{Fn: mathGcd},
+		"lcm":   &object.Builtin{Fn: mathLcm},
+
+		"rand_int":   &object.Builtin{Fn: mathRandInt},
+		"rand_float": &object.Builtin{Fn: mathRandFloat},
+		"seed":       &object.Builtin{Fn: mathSeed},
+	})
+}
+
+// 检查第i个参数是否为数字
+func checkNumberArg(name string, args []object.Object, i int) *object.Error {
+	if !isNumber(args[i]) {
+		return newError("argument %d to '%s' must be INTEGER or FLOAT,got %s", i+1, name, args[i].Type())
+	}
+	return nil
+}
+
+// 单参数、返回浮点数的函数
+func floatFunction(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+			if err := checkNumberArg(name, args, 0); err != nil {
+				return err
+			}
+			return &object.Float{Value: fn(toFloat(args[0]))}
+		},
+	}
+}
+
+// 取整函数 返回整数
+func roundFunction(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+			if err := checkNumberArg(name, args, 0); err != nil {
+				return err
+			}
+			if args[0].Type() == object.INTEGER_OBJ {
+				return args[0]
+			}
+			v := fn(toFloat(args[0]))
+			if math.IsNaN(v) || math.Abs(v) >= math.MaxInt64 {
+				return newError("cannot convert %s to INTEGER", args[0].Inspect())
+			}
+			return &object.Integer{Value: int64(v)}
+		},
+	}
+}
+
+// math.abs(x) 保持参数类型
+func mathAbs(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		if arg.Value < 0 {
+			return &object.Integer{Value: -arg.Value}
+		}
+		return arg
+	case *object.Float:
+		return &object.Float{Value: math.Abs(arg.Value)}
+	default:
+		return checkNumberArg("abs", args, 0)
+	}
+}
+
+// math.pow(x, y) 整数的非负整数次幂返回整数，其余返回浮点数
+func mathPow(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+	for i := range args {
+		if err := checkNumberArg("pow", args, i); err != nil {
+			return err
+		}
+	}
+
+	base, ok1 := args[0].(*object.Integer)
+	exp, ok2 := args[1].(*object.Integer)
+	if ok1 && ok2 && exp.Value >= 0 {
+		result := int64(1)
+		for b, e := base.Value, exp.Value; e > 0; e >>= 1 { //快速幂
+			if e&1 == 1 {
+				result *= b
+			}
+			b *= b
+		}
+		return &object.Integer{Value: result}
+	}
+	return &object.Float{Value: math.Pow(toFloat(args[0]), toFloat(args[1]))}
+}
+
+// math.log(x) 自然对数; math.log(x, base) 以base为底
+func mathLog(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+	for i := range args {
+		if err := checkNumberArg("log", args, i); err != nil {
+			return err
+		}
+	}
+	result := math.Log(toFloat(args[0]))
+	if len(args) == 2 {
+		result /= math.Log(toFloat(args[1]))
+	}
+	return &object.Float{Value: result}
+}
+
+// math.atan2(y, x)
+func mathAtan2(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+	for i := range args {
+		if err := checkNumberArg("atan2", args, i); err != nil {
+			return err
+		}
+	}
+	return &object.Float{Value: math.Atan2(toFloat(args[0]), toFloat(args[1]))}
+}
+
+// math.min/math.max 接受任意个数字，返回原对象
+func mathExtremum(name string, args []object.Object, sign int) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want>=1", len(args))
+	}
+	best := args[0]
+	for i := range args {
+		if err := checkNumberArg(name, args, i); err != nil {
+			return err
+		}
+		if (toFloat(args[i])-toFloat(best))*float64(sign) > 0 {
+			best = args[i]
+		}
+	}
+	return best
+}
+
+// math.gcd(a, b) 最大公约数，结果非负
+func mathGcd(args ...object.Object) object.Object {
+	if err := checkArgs("gcd", args, object.INTEGER_OBJ, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+	return &object.Integer{Value: gcd(args[0].(*object.Integer).Value, args[1].(*object.Integer).Value)}
+}
+
+// math.lcm(a, b) 最小公倍数，结果非负
+func mathLcm(args ...object.Object) object.Object {
+	if err := checkArgs("lcm", args, object.INTEGER_OBJ, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+	a, b := args[0].(*object.Integer).Value, args[1].(*object.Integer).Value
+	if a == 0 || b == 0 {
+		return &object.Integer{Value: 0}
+	}
+	result := a / gcd(a, b) * b
+	if result < 0 {
+		result = -result
+	}
+	return &object.Integer{Value: result}
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// math.rand_int(n) 返回[0, n)内的整数; math.rand_int(lo, hi) 返回[lo, hi)内的整数
+func mathRandInt(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+	for i := range args {
+		if err := checkArgType("rand_int", args, i, object.INTEGER_OBJ); err != nil {
+			return err
+		}
+	}
+
+	lo, hi := int64(0), args[0].(*object.Integer).Value
+	if len(args) == 2 {
+		lo, hi = hi, args[1].(*object.Integer).Value
+	}
+	if hi <= lo {
+		return newError("empty range for 'rand_int': [%d, %d)", lo, hi)
+	}
+
+	//hi-lo可能超出int64，在uint64中计算区间长度
+	span := uint64(hi) - uint64(lo)
+	randomMu.Lock()
+	defer randomMu.Unlock()
+	if span <= math.MaxInt64 {
+		return &object.Integer{Value: lo + random.Int63n(int64(span))}
+	}
+	for { //区间长度超过2^63时每次被拒绝的概率小于1/2
+		if n := random.Uint64(); n < span {
+			return &object.Integer{Value: int64(uint64(lo) + n)}
+		}
+	}
+}
+
+// math.rand_float() 返回[0, 1)内的浮点数
+func mathRandFloat(args ...object.Object) object.Object {
+	if err := checkArgCount(args, 0, 0); err != nil {
+		return err
+	}
+	randomMu.Lock()
+	defer randomMu.Unlock()
+	return &object.Float{Value: random.Float64()}
+}
+
+// math.seed(n) 在脚本中设置随机数种子
+func mathSeed(args ...object.Object) object.Object {
+	if err := checkArgs("seed", args, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+	SeedRandom(args[0].(*object.Integer).Value)
+	return NULL
+}
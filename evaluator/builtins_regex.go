@@ -0,0 +1,196 @@
+package evaluator
+
+//正则表达式 regex(pattern)编译得到REGEX对象，通过re.match(s)等方法使用
+
+import (
+	"monkey_Interpreter/object"
+	"regexp"
+)
+
+func init() {
+	registerBuiltins(map[string]*object.Builtin{
+		"regex": &object.Builtin{ //regex(pattern) 编译正则表达式，语法同Go的regexp
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArgs("regex", args, object.STRING_OBJ); err != nil {
+					return err
+				}
+				re, err := regexp.Compile(args[0].(*object.String).Value)
+				if err != nil {
+					return newError("invalid regex: %s", err)
+				}
+				return &object.Regex{Value: re}
+			},
+		},
+	})
+
+	//方法中的replace会回调用户函数，放在init中赋值以避免初始化循环
+	regexMethods = map[string]regexMethod{
+		"test":        regexTest,
+		"match":       regexMatch,
+		"match_named": regexMatchNamed,
+		"find_all":    regexFindAll,
+		"replace":     regexReplace,
+		"split":       regexSplit,
+	}
+}
+
+// 正则表达式的方法，re为方法所属的对象
+type regexMethod func(re *regexp.Regexp, args []object.Object) object.Object
+
+var regexMethods map[string]regexMethod
+
+// re.name 返回绑定到re的方法，re.pattern返回源模式串
+func regexProperty(re *object.Regex, name string) object.Object {
+	if name == "pattern" {
+		return &object.String{Value: re.Value.String()}
+	}
+	method, ok := regexMethods[name]
+	if !ok {
+		return newError("unknown property for REGEX: %s", name)
+	}
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return method(re.Value, args)
+		},
+	}
+}
+
+// re.test(s) 是否匹配
+func regexTest(re *regexp.Regexp, args []object.Object) object.Object {
+	if err := checkArgs("test", args, object.STRING_OBJ); err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(re.MatchString(args[0].(*object.String).Value))
+}
+
+// re.match(s) 第一个匹配，返回[整体, 分组1, ...]，未匹配返回null
+func regexMatch(re *regexp.Regexp, args []object.Object) object.Object {
+	if err := checkArgs("match", args, object.STRING_OBJ); err != nil {
+		return err
+	}
+	s := args[0].(*object.String).Value
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return NULL
+	}
+	return submatchArray(s, loc)
+}
+
+// re.match_named(s) 第一个匹配中的命名分组，返回{名称: 值}，未匹配返回null
+func regexMatchNamed(re *regexp.Regexp, args []object.Object) object.Object {
+	if err := checkArgs("match_named", args, object.STRING_OBJ); err != nil {
+		return err
+	}
+	s := args[0].(*object.String).Value
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return NULL
+	}
+
+	groups := submatchArray(s, loc).Elements
+	hash := object.NewHash()
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			hash.Set(&object.String{Value: name}, groups[i])
+		}
+	}
+	return hash
+}
+
+// re.find_all(s) 或 re.find_all(s, n) 所有匹配
+// 没有分组时每项为匹配的字符串，有分组时每项为[整体, 分组1, ...]
+func regexFindAll(re *regexp.Regexp, args []object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+	if err := checkArgType("find_all", args, 0, object.STRING_OBJ); err != nil {
+		return err
+	}
+	n := -1
+	if len(args) == 2 {
+		if err := checkArgType("find_all", args, 1, object.INTEGER_OBJ); err != nil {
+			return err
+		}
+		n = int(args[1].(*object.Integer).Value)
+	}
+
+	s := args[0].(*object.String).Value
+	result := []object.Object{}
+	for _, loc := range re.FindAllStringSubmatchIndex(s, n) {
+		if re.NumSubexp() == 0 {
+			result = append(result, &object.String{Value: s[loc[0]:loc[1]]})
+		} else {
+			result = append(result, submatchArray(s, loc))
+		}
+	}
+	return &object.Array{Elements: result}
+}
+
+// re.replace(s, repl) 替换所有匹配
+// repl为字符串时支持$1、${name}引用分组；为函数时以[整体, 分组1, ...]调用，返回值作为替换内容
+func regexReplace(re *regexp.Regexp, args []object.Object) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+	if err := checkArgType("replace", args, 0, object.STRING_OBJ); err != nil {
+		return err
+	}
+	s := args[0].(*object.String).Value
+
+	if repl, ok := args[1].(*object.String); ok {
+		return &object.String{Value: re.ReplaceAllString(s, repl.Value)}
+	}
+	if !isCallable(args[1]) {
+		return newError("argument 2 to 'replace' must be STRING or FUNCTION,got %s", args[1].Type())
+	}
+
+	var out []byte
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+		val := applyFunction(args[1], []object.Object{submatchArray(s, loc)})
+		if isError(val) {
+			return val
+		}
+		str, ok := val.(*object.String)
+		if !ok {
+			return newError("replacement function must return STRING,got %s", val.Type())
+		}
+		out = append(out, s[last:loc[0]]...)
+		out = append(out, str.Value...)
+		last = loc[1]
+	}
+	out = append(out, s[last:]...)
+	return &object.String{Value: string(out)}
+}
+
+// re.split(s) 或 re.split(s, n) 按匹配切分
+func regexSplit(re *regexp.Regexp, args []object.Object) object.Object {
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return err
+	}
+	if err := checkArgType("split", args, 0, object.STRING_OBJ); err != nil {
+		return err
+	}
+	n := -1
+	if len(args) == 2 {
+		if err := checkArgType("split", args, 1, object.INTEGER_OBJ); err != nil {
+			return err
+		}
+		n = int(args[1].(*object.Integer).Value)
+	}
+	return stringsToArray(re.Split(args[0].(*object.String).Value, n))
+}
+
+// 将匹配位置转为[整体, 分组1, ...]，未参与匹配的分组为null
+func submatchArray(s string, loc []int) *object.Array {
+	elements := make([]object.Object, len(loc)/2)
+	for i := range elements {
+		start, end := loc[2*i], loc[2*i+1]
+		if start < 0 {
+			elements[i] = NULL
+		} else {
+			elements[i] = &object.String{Value: s[start:end]}
+		}
+	}
+	return &object.Array{Elements: elements}
+}
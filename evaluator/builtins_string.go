@@ -0,0 +1,287 @@
+package evaluator
+
+//字符串相关的内置函数 均按字符(rune)处理
+
+import (
+	"fmt"
+	"monkey_Interpreter/object"
+	"strings"
+	"unicode/utf8"
+)
+
+// 生成的字符串最多的字节数，避免repeat、pad_left等因参数过大耗尽内存
+const maxStringLength = 1 << 26
+
+func init() {
+	registerBuiltins(stringBuiltins)
+}
+
+var stringBuiltins = map[string]*object.Builtin{
+	"split": &object.Builtin{ //split(s) 按空白切分; split(s, sep) 按分隔符切分
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkArgType("split", args, 0, object.STRING_OBJ); err != nil {
+				return err
+			}
+			s := args[0].(*object.String).Value
+
+			var parts []string
+			if len(args) == 1 {
+				parts = strings.Fields(s)
+			} else {
+				if err := checkArgType("split", args, 1, object.STRING_OBJ); err != nil {
+					return err
+				}
+				parts = strings.Split(s, args[1].(*object.String).Value)
+			}
+			return stringsToArray(parts)
+		},
+	},
+
+	"join": &object.Builtin{ //join(arr, sep) 非字符串元素使用Inspect()拼接
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("join", args, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			elements := args[0].(*object.Array).Elements
+			parts := make([]string, len(elements))
+			for i, e := range elements {
+				parts[i] = e.Inspect()
+			}
+			return &object.String{Value: strings.Join(parts, args[1].(*object.String).Value)}
+		},
+	},
+
+	"trim": &object.Builtin{ //trim(s) 去除首尾空白; trim(s, cutset) 去除首尾指定字符
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkArgType("trim", args, 0, object.STRING_OBJ); err != nil {
+				return err
+			}
+			s := args[0].(*object.String).Value
+
+			if len(args) == 1 {
+				return &object.String{Value: strings.TrimSpace(s)}
+			}
+			if err := checkArgType("trim", args, 1, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Trim(s, args[1].(*object.String).Value)}
+		},
+	},
+
+	"upper": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("upper", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+		},
+	},
+
+	"lower": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("lower", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+		},
+	},
+
+	"replace": &object.Builtin{ //replace(s, old, new) 全部替换; replace(s, old, new, n) 替换前n个
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(args, 3, 4); err != nil {
+				return err
+			}
+			for i := 0; i < 3; i++ {
+				if err := checkArgType("replace", args, i, object.STRING_OBJ); err != nil {
+					return err
+				}
+			}
+
+			n := -1
+			if len(args) == 4 {
+				if err := checkArgType("replace", args, 3, object.INTEGER_OBJ); err != nil {
+					return err
+				}
+				n = int(args[3].(*object.Integer).Value)
+			}
+
+			s := args[0].(*object.String).Value
+			old := args[1].(*object.String).Value
+			new := args[2].(*object.String).Value
+			return &object.String{Value: strings.Replace(s, old, new, n)}
+		},
+	},
+
+	"contains": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("contains", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.Contains(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+
+	"starts_with": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("starts_with", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasPrefix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+
+	"ends_with": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("ends_with", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasSuffix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+
+	"index_of": &object.Builtin{ //返回子串首次出现的字符位置，不存在返回-1
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("index_of", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			s := args[0].(*object.String).Value
+			i := strings.Index(s, args[1].(*object.String).Value)
+			if i >= 0 {
+				i = utf8.RuneCountInString(s[:i]) //字节位置换算为字符位置
+			}
+			return &object.Integer{Value: int64(i)}
+		},
+	},
+
+	"repeat": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("repeat", args, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+			n := args[1].(*object.Integer).Value
+			if n < 0 {
+				return newError("negative repeat count: %d", n)
+			}
+			s := args[0].(*object.String).Value
+			if len(s) > 0 && n > int64(maxStringLength/len(s)) {
+				return newError("argument to 'repeat' is too large,got %d", n)
+			}
+			return &object.String{Value: strings.Repeat(s, int(n))}
+		},
+	},
+
+	"pad_left": &object.Builtin{ //pad_left(s, width) 左侧补空格; pad_left(s, width, pad) 左侧补指定字符串
+		Fn: func(args ...object.Object) object.Object {
+			return padString("pad_left", args, true)
+		},
+	},
+
+	"pad_right": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return padString("pad_right", args, false)
+		},
+	},
+
+	"chars": &object.Builtin{ //字符串拆分为字符数组
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("chars", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			runes := []rune(args[0].(*object.String).Value)
+			elements := make([]object.Object, len(runes))
+			for i, r := range runes {
+				elements[i] = &object.String{Value: string(r)}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"format": &object.Builtin{ //printf风格格式化 format("%s=%d", "a", 1)
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want>=1", len(args))
+			}
+			if err := checkArgType("format", args, 0, object.STRING_OBJ); err != nil {
+				return err
+			}
+			values := make([]interface{}, len(args)-1)
+			for i, arg := range args[1:] {
+				values[i] = nativeValue(arg)
+			}
+			return &object.String{Value: fmt.Sprintf(args[0].(*object.String).Value, values...)}
+		},
+	},
+}
+
+// 字符串切片转为字符串数组对象
+func stringsToArray(parts []string) *object.Array {
+	elements := make([]object.Object, len(parts))
+	for i, p := range parts {
+		elements[i] = &object.String{Value: p}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// 将字符串补齐到指定宽度（按字符计），left为true时在左侧补齐
+func padString(name string, args []object.Object, left bool) object.Object {
+	if err := checkArgCount(args, 2, 3); err != nil {
+		return err
+	}
+	if err := checkArgType(name, args, 0, object.STRING_OBJ); err != nil {
+		return err
+	}
+	if err := checkArgType(name, args, 1, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+
+	pad := " "
+	if len(args) == 3 {
+		if err := checkArgType(name, args, 2, object.STRING_OBJ); err != nil {
+			return err
+		}
+		pad = args[2].(*object.String).Value
+		if pad == "" {
+			return newError("padding for '%s' must not be empty", name)
+		}
+	}
+
+	width := args[1].(*object.Integer).Value
+	if width > maxStringLength {
+		return newError("argument to '%s' is too large,got %d", name, width)
+	}
+
+	s := args[0].(*object.String).Value
+	missing := int(width) - utf8.RuneCountInString(s)
+	if missing <= 0 {
+		return args[0]
+	}
+
+	//重复填充串后截取所需字符数
+	padRunes := []rune(strings.Repeat(pad, missing/utf8.RuneCountInString(pad)+1))[:missing]
+	if left {
+		return &object.String{Value: string(padRunes) + s}
+	}
+	return &object.String{Value: s + string(padRunes)}
+}
+
+// 将对象转为Go原生值，供格式化使用
+func nativeValue(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	default:
+		return obj.Inspect()
+	}
+}
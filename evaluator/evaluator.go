@@ -29,6 +29,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	//浮点数字面量
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	//布尔型字面量
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
@@ -89,8 +93,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		body := node.Body                                                 //取函数体
 		return &object.Function{Parameters: params, Env: env, Body: body} //返回一个包含参数、函数体、绑定的环境 的对象
 
+	//宏只能在展开前通过顶层let定义
+	case *ast.MacroLiteral:
+		return newError("macro literals can only be defined by a top-level let")
+
 	//函数调用
 	case *ast.CallExpression:
+		if isCallTo(node, "quote") {
+			if len(node.Arguments) != 1 {
+				return newError("wrong number of arguments to quote: want=1, got=%d", len(node.Arguments))
+			}
+			return quote(node.Arguments[0], env)
+		}
 		function := Eval(node.Function, env) //获取调用的函数
 		if isError(function) {               //检查函数合法性
 			return function
@@ -126,7 +140,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpresssion(left, index)
+		return evalIndexExpresssion(left, index, env.StrictIndex())
+
+	//切片
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+
+	//属性访问
+	case *ast.PropertyExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return evalPropertyExpression(obj, node.Property.Value)
 
 	//哈希表
 	case *ast.HashLiteral:
@@ -189,14 +215,16 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-// 测试-前缀表达式 仅用于整数
+// 测试-前缀表达式 仅用于整数和浮点数
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 // 中缀表达式 中转函数
@@ -205,10 +233,10 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ: //左右值都是整数
 		return evalIntegerInfixExpression(operator, left, right) //整数的处理
 
-	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+	case operator == "==": //结构相等
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 
 	case operator == "&&":
 		// 左值为假则直接返回左值
@@ -225,9 +253,15 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 		// 左值为假则返回右值
 		return right
 
+	case isNumber(left) && isNumber(right): //含浮点数的运算，整数转为浮点数
+		return evalFloatInfixExpression(operator, left, right)
+
 	case left.Type() != right.Type(): //对象不同
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 
+	case operator == "<" || operator == ">": //字符串、数组等的大小比较
+		return evalComparisonExpression(operator, left, right)
+
 	//字符串拼接
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
@@ -250,6 +284,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 
 	//布尔操作
@@ -267,6 +304,42 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+// 浮点数的中缀操作符处理
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 判断是否为整数或浮点数
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// 整数或浮点数转为float64
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 // if选择语句的求值
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env) //处理条件
@@ -278,11 +351,14 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 		return Eval(ie.Consequence, env) //执行结果
 	} //首选项条件不对，且备选结果不为空
 
-	//执行中间选项
+	//执行中间选项 最先满足条件的选项即为结果，即使其结果为null
 	for _, al := range ie.Alternatives {
-		alter_obj := evalElifExpression(al, env)
-		if alter_obj != NULL { //任何中间选项，最先正确执行的，返回它的执行结果
-			return alter_obj
+		condition := Eval(al.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return Eval(al.Consequence, env)
 		}
 	}
 
@@ -295,20 +371,6 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	return NULL
 }
 
-// elif的处理 和前面差不多
-func evalElifExpression(ef *ast.ElIfExpression, env *object.Environment) object.Object {
-	alternnative_condition := Eval(ef.Condition, env)
-	if isError(alternnative_condition) {
-		return alternnative_condition
-	}
-
-	if isTruthy(alternnative_condition) {
-		return Eval(ef.Consequence, env)
-	} else {
-		return NULL
-	}
-}
-
 // 辅助函数 判断条件是否成立
 func isTruthy(obj object.Object) bool {
 	switch obj {
@@ -338,6 +400,10 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 		}
 	}
 
+	//空块或以let结尾的块没有值，结果为null
+	if result == nil {
+		return NULL
+	}
 	return result
 }
 
@@ -365,7 +431,12 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
-	return newError("identifier not found:" + node.Value)
+
+	//内置模块
+	if module, ok := modules[node.Value]; ok {
+		return module
+	}
+	return newError("identifier not found: " + node.Value)
 }
 
 // 辅助函数：求值所有实参
@@ -388,6 +459,9 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 
 	switch fn := fn.(type) {
 	case *object.Function: //自定义的函数
+		if len(args) != len(fn.Parameters) { //实参与形参个数不符
+			return newError("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
 		extendedEnv := extendFunction(fn, args) //创建局部环境
 		evaluated := Eval(fn.Body, extendedEnv) //执行函数，也就是配合环境执行函数体的内容
 		return unwarpReturnValue(evaluated)     //解包，返回函数执行后的结果
@@ -395,6 +469,9 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	case *object.Builtin: //内置函数
 		return fn.Fn(args...)
 
+	case object.Callable: //其他后端的函数，如字节码虚拟机的闭包
+		return fn.Call(args...)
+
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
@@ -421,10 +498,22 @@ func unwarpReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
+// 通过object.Compare比较大小
+func evalComparisonExpression(operator string, left, right object.Object) object.Object {
+	c, err := object.Compare(left, right)
+	if err != nil {
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+	if operator == "<" {
+		return nativeBoolToBooleanObject(c < 0)
+	}
+	return nativeBoolToBooleanObject(c > 0)
+}
+
 // 字符串拼接
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	if operator != "+" {
-		return newError("unknown opearator:%s %s %s", left.Type(), operator, right.Type())
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
@@ -433,11 +522,13 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	return &object.String{Value: leftVal + rightVal}
 }
 
-// 索引求值
-func evalIndexExpresssion(left, index object.Object) object.Object {
+// 索引求值，strict为true时越界返回错误
+func evalIndexExpresssion(left, index object.Object, strict bool) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return evalArrayIndexExpresssion(left, index)
+		return evalArrayIndexExpresssion(left, index, strict)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index, strict)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -445,31 +536,170 @@ func evalIndexExpresssion(left, index object.Object) object.Object {
 	}
 }
 
-// 数组索引 根据索引获取具体的值
-func evalArrayIndexExpresssion(array, index object.Object) object.Object {
+// 数组索引 根据索引获取具体的值，负数从末尾开始计数
+func evalArrayIndexExpresssion(array, index object.Object, strict bool) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
+		return indexOutOfRange(index, len(arrayObject.Elements), strict)
+	}
+	return arrayObject.Elements[idx]
+}
+
+// 字符串索引 按字符(rune)取值
+func evalStringIndexExpression(str, index object.Object, strict bool) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(runes))
+	if !ok {
+		return indexOutOfRange(index, len(runes), strict)
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+// 将负数索引换算为正数索引，并检查是否越界
+func normalizeIndex(idx int64, length int) (int64, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	if idx < 0 || idx >= int64(length) {
+		return idx, false
+	}
+	return idx, true
+}
+
+// 索引越界 根据strict返回NULL或错误
+func indexOutOfRange(index object.Object, length int, strict bool) object.Object {
+	if strict {
+		return newError("index out of range: %s (length %d)", index.Inspect(), length)
+	}
+	return NULL
+}
+
+// 切片求值
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	//依次求值起始、结束位置与步长，省略的部分为nil
+	bounds := make([]*int64, 3)
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		val := Eval(exp, env)
+		if isError(val) {
+			return val
+		}
+		integer, ok := val.(*object.Integer)
+		if !ok {
+			return newError("slice indices must be INTEGER, got %s", val.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+
+	return sliceObject(left, bounds)
+}
+
+// 按求值后的起止位置与步长切片，bounds中省略的部分为nil
+func sliceObject(left object.Object, bounds []*int64) object.Object {
+	step := int64(1)
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return newError("slice step cannot be zero")
+	}
 
-	if idx < 0 || idx > max {
+	switch left := left.(type) {
+	case *object.Array:
+		indices := sliceIndices(len(left.Elements), bounds[0], bounds[1], step)
+		elements := make([]object.Object, 0, len(indices))
+		for _, i := range indices {
+			elements = append(elements, left.Elements[i])
+		}
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		indices := sliceIndices(len(runes), bounds[0], bounds[1], step)
+		result := make([]rune, 0, len(indices))
+		for _, i := range indices {
+			result = append(result, runes[i])
+		}
+		return &object.String{Value: string(result)}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// 计算切片选中的下标，规则与Python一致：越界的起止位置会被截断而不报错
+func sliceIndices(length int, start, end *int64, step int64) []int64 {
+	n := int64(length)
+
+	//步长为正时范围是[0,n]，为负时是[-1,n-1]
+	lower, upper := int64(0), n
+	if step < 0 {
+		lower, upper = -1, n-1
+	}
+
+	clamp := func(v *int64, def int64) int64 {
+		if v == nil {
+			return def
+		}
+		i := *v
+		if i < 0 {
+			i += n
+			if i < lower {
+				i = lower
+			}
+		} else if i > upper {
+			i = upper
+		}
+		return i
+	}
+
+	var indices []int64
+	if step > 0 {
+		for i, stop := clamp(start, lower), clamp(end, upper); i < stop; i += step {
+			indices = append(indices, i)
+		}
+	} else {
+		for i, stop := clamp(start, upper), clamp(end, lower); i > stop; i += step {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// 属性访问 哈希表按字符串键取值，正则表达式返回绑定的方法
+func evalPropertyExpression(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Hash:
+		if value, ok := obj.Get(&object.String{Value: name}); ok {
+			return value
+		}
 		return NULL
+	case *object.Regex:
+		return regexProperty(obj, name)
+	default:
+		return newError("property access not supported: %s", obj.Type())
 	}
-	return arrayObject.Elements[idx]
 }
 
 // 哈希表求值
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs { //取出真实键、值
+	for _, keyNode := range node.Keys { //按源码顺序取出真实键、值
+		valueNode := node.Pairs[keyNode]
 		key := Eval(keyNode, env) //解析键
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return newError("unusable as hash key:%s", key.Type())
+		if !object.IsHashable(key) {
+			return newError("unusable as hash key: %s", key.Type())
 		}
 
 		value := Eval(valueNode, env) //解析值
@@ -477,26 +707,24 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return value
 		}
 
-		hashed := hashKey.HashKey() //执行外层键值函数HashKey()获取HashKey实例作为哈希值
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(key, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 // 哈希表索引
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable) //取出外层键值函数
-	if !ok {
+	if !object.IsHashable(index) {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()] //执行外层键值，并据此取出对应的键值对
+	value, ok := hashObject.Get(index)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
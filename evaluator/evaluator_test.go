@@ -4,6 +4,11 @@ import (
 	"monkey_Interpreter/lexer"
 	"monkey_Interpreter/object"
 	"monkey_Interpreter/parser"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -74,6 +79,28 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" != "b"`, true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, [2, 3]] == [1, [2, 4]]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`1 == "1"`, false},
+		{`1 != "1"`, true},
+		{"let f = fn(x) { x }; f == f", true},
+		{"fn(x) { x } == fn(x) { x }", false},
+		{"if (false) { 1 } == if (false) { 2 }", true},
+		{`"apple" < "banana"`, true},
+		{`"apple" > "banana"`, false},
+		{`"app" < "apple"`, true},
+		{`"Zebra" < "apple"`, true},
+		{"[1, 2] < [1, 3]", true},
+		{"[1, 2] < [1, 2, 0]", true},
+		{"[2] > [1, 9]", true},
+		{`[1, "b"] > [1, "a"]`, true},
 	}
 
 	for _, tt := range tests {
@@ -131,6 +158,9 @@ func TestIfElseExpression(t *testing.T) {
 		{"if (1 > 2) { 10 } elif(1<2) { 20 }else { 10 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
 		{"if ((1 < 2)&&(3>5)) { 10 } else { 20 }", 20},
+		{"if (false) { 10 } elif (true) { } else { 30 }", nil},
+		{"if (false) { 10 } elif (true) { if (false) { 1 } } elif (true) { 20 }", nil},
+		{"if (true) { let x = 1; }", nil},
 	}
 
 	for _, tt := range tests {
@@ -232,6 +262,18 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"true < false",
+			"unknown operator: BOOLEAN < BOOLEAN",
+		},
+		{
+			`[1, "a"] < [1, 2]`,
+			"unknown operator: ARRAY < ARRAY",
+		},
+		{
+			`"a" < 1`,
+			"type mismatch: STRING < INTEGER",
+		},
 	}
 
 	for _, tt := range tests {
@@ -410,6 +452,14 @@ func TestArrayIndexExpression(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -424,6 +474,103 @@ func TestArrayIndexExpression(t *testing.T) {
 	}
 }
 
+// 测试字符串索引
+func TestStringIndexExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"hello"[0]`, "h"},
+		{`"hello"[-1]`, "o"},
+		{`"你好世界"[1]`, "好"},
+		{`"hello"[5]`, nil},
+		{`"hello"[-6]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+		result, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if result.Value != str {
+			t.Errorf("String has wrong value. got=%q, want=%q", result.Value, str)
+		}
+	}
+}
+
+// 测试切片
+func TestSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4, 5][1:3]", "[2,3]"},
+		{"[1, 2, 3, 4, 5][:2]", "[1,2]"},
+		{"[1, 2, 3, 4, 5][3:]", "[4,5]"},
+		{"[1, 2, 3, 4, 5][:]", "[1,2,3,4,5]"},
+		{"[1, 2, 3, 4, 5][::2]", "[1,3,5]"},
+		{"[1, 2, 3, 4, 5][::-1]", "[5,4,3,2,1]"},
+		{"[1, 2, 3, 4, 5][-2:]", "[4,5]"},
+		{"[1, 2, 3, 4, 5][:-2]", "[1,2,3]"},
+		{"[1, 2, 3, 4, 5][4:1:-1]", "[5,4,3]"},
+		{"[1, 2, 3, 4, 5][-100:100]", "[1,2,3,4,5]"},
+		{"[1, 2, 3, 4, 5][3:1]", "[]"},
+		{"let a = [1, 2, 3]; let i = 1; a[i:i+1]", "[2]"},
+		{`"hello"[1:4]`, "ell"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"你好世界"[1:3]`, "好世"},
+		{"[1, 2, 3][::0]", "ERROR: slice step cannot be zero"},
+		{`[1, 2, 3]["a":]`, "ERROR: slice indices must be INTEGER, got STRING"},
+		{"5[1:]", "ERROR: slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+// 测试严格模式下索引越界返回错误
+func TestStrictIndex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3][3]", "index out of range: 3 (length 3)"},
+		{"[1, 2, 3][-4]", "index out of range: -4 (length 3)"},
+		{`"abc"[10]`, "index out of range: 10 (length 3)"},
+		{"let f = fn(a) { fn() { a[5] } }; f([1])()", "index out of range: 5 (length 1)"}, //内层环境共用设置
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetStrictIndex(true)
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+
+	//设置只影响所在的环境
+	if got := testEval("[1, 2, 3][3]"); got != NULL {
+		t.Errorf("index out of range should be null by default. got=%s", got.Inspect())
+	}
+}
+
 // 测试哈希表
 func TestHashLiteral(t *testing.T) {
 	input := `let two="two";
@@ -433,7 +580,7 @@ func TestHashLiteral(t *testing.T) {
 		"thr"+"ee":6/2,
 		4:4,
 		true:5,
-		flase:6,
+		false:6,
 	}`
 
 	evaluated := testEval(input)
@@ -443,26 +590,30 @@ func TestHashLiteral(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T(%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Object
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, exevaluatedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, tt := range expected {
+		value, ok := result.Get(tt.key)
 		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+			t.Errorf("no pair for given key %s", tt.key.Inspect())
+			continue
 		}
 
-		testIntegerObject(t, pair.Value, exevaluatedValue)
+		testIntegerObject(t, value, tt.value)
 	}
 }
 
@@ -512,3 +663,739 @@ func TestHashIndexExpression(t *testing.T) {
 		}
 	}
 }
+
+// 测试字符串内置函数
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("你好")`, "2"},
+		{`split("a,b,,c", ",")`, "[a,b,,c]"},
+		{`split("  a b  c ")`, "[a,b,c]"},
+		{`join(["a", "b", 1], "-")`, "a-b-1"},
+		{`join([], "-")`, ""},
+		{`trim("  hi  ")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`upper("abc")`, "ABC"},
+		{`lower("ÀBC")`, "àbc"},
+		{`replace("aaa", "a", "b")`, "bbb"},
+		{`replace("aaa", "a", "b", 2)`, "bba"},
+		{`contains("monkey", "key")`, "true"},
+		{`contains("monkey", "dog")`, "false"},
+		{`starts_with("monkey", "mon")`, "true"},
+		{`ends_with("monkey", "mon")`, "false"},
+		{`index_of("你好世界", "世")`, "2"},
+		{`index_of("abc", "z")`, "-1"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`pad_left("7", 3, "0")`, "007"},
+		{`pad_left("世界", 4)`, "  世界"},
+		{`pad_right("a", 4, "xy")`, "axyx"},
+		{`pad_right("abcd", 2)`, "abcd"},
+		{`chars("你好")`, "[你,好]"},
+		{`format("%s has %d items, ok=%t", "cart", 3, true)`, "cart has 3 items, ok=true"},
+		{`format("%05d|%-4s|", 42, "ab")`, "00042|ab  |"},
+		{`format("%v", [1, 2])`, "[1,2]"},
+		{`upper(1)`, "ERROR: argument 1 to 'upper' must be STRING,got INTEGER"},
+		{`split()`, "ERROR: wrong number of arguments. got=0, want=1..2"},
+		{`repeat("a", -1)`, "ERROR: negative repeat count: -1"},
+		{`pad_left("a", 3, "")`, "ERROR: padding for 'pad_left' must not be empty"},
+		{`repeat("ab", 9223372036854775807)`, "ERROR: argument to 'repeat' is too large,got 9223372036854775807"},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`pad_left("a", 9223372036854775807)`, "ERROR: argument to 'pad_left' is too large,got 9223372036854775807"},
+		{`pad_right("a", 9223372036854775807, "xy")`, "ERROR: argument to 'pad_right' is too large,got 9223372036854775807"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+// 测试数组高阶内置函数
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2,4,6]"},
+		{`map([], fn(x) { x })`, "[]"},
+		{`map(["a", "b"], upper)`, "[A,B]"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3,4]"},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, "10"},
+		{`reduce([1, 2, 3], fn(acc, x) { acc * x }, 10)`, "60"},
+		{`reduce([], fn(acc, x) { acc + x }, 0)`, "0"},
+		{`sort([3, 1, 2])`, "[1,2,3]"},
+		{`sort(["pear", "apple", "fig"])`, "[apple,fig,pear]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3,2,1]"},
+		{`let a = [3, 1, 2]; sort(a); a`, "[3,1,2]"},
+		{`reverse([1, 2, 3])`, "[3,2,1]"},
+		{`reverse("你好")`, "好你"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1,a],[2,b]]"},
+		{`enumerate(["a", "b"])`, "[[0,a],[1,b]]"},
+		{`range(4)`, "[0,1,2,3]"},
+		{`range(2, 5)`, "[2,3,4]"},
+		{`range(5, 0, -2)`, "[5,3,1]"},
+		{`range(9223372036854775805, 9223372036854775807, 1)`, "[9223372036854775805,9223372036854775806]"},
+		{`range(-9223372036854775807, -9223372036854775807 - 1, -5)`, "[-9223372036854775807]"},
+		{`range(3, 3)`, "[]"},
+		{`range(0, 10, -1)`, "[]"},
+		{`range(1, 8, 3)`, "[1,4,7]"},
+		{`any([false, 1])`, "true"},
+		{`any([1, 2], fn(x) { x > 5 })`, "false"},
+		{`all([1, true])`, "true"},
+		{`all([1, 2, 3], fn(x) { x < 3 })`, "false"},
+		{`all([])`, "true"},
+		{`sum([1, 2, 3])`, "6"},
+		{`min([3, 1, 2])`, "1"},
+		{`max(3, 7, 5)`, "7"},
+		{`max(["b", "c", "a"])`, "c"},
+		{`min([])`, "null"},
+		{`unique([1, 2, 1, "a", "a", 3])`, "[1,2,a,3]"},
+		{`flatten([1, [2, [3, [4]]]])`, "[1,2,[3,[4]]]"},
+		{`flatten([1, [2, [3, [4]]]], -1)`, "[1,2,3,4]"},
+		{`map([1, 2], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`map([1, 2], fn(x, y) { x })`, "ERROR: wrong number of arguments: want=2, got=1"},
+		{`filter([1], 1)`, "ERROR: argument 2 to 'filter' must be FUNCTION,got INTEGER"},
+		{`reduce([], fn(acc, x) { acc })`, "ERROR: reduce of empty array with no initial value"},
+		{`sort([1, "a"])`, "ERROR: cannot compare STRING with INTEGER"},
+		{`sort([[2, "a"], [1, "z"], [1, "b"]])`, "[[1,b],[1,z],[2,a]]"},
+		{`let people = [{"name": "bob"}, {"name": "al"}]; map(sort(people, fn(a, b) { a["name"] < b["name"] }), fn(p) { p["name"] })`, "[al,bob]"},
+		{`min(["pear", "fig"])`, "fig"},
+		{`sort([2, 1], fn(a, b) { a + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`range(1, 2, 0)`, "ERROR: range step cannot be zero"},
+		{`range(0, 9223372036854775807)`, "ERROR: range is too large,got 9223372036854775807 elements"},
+		{`range(9223372036854775807, -9223372036854775807 - 1, -1)`, "ERROR: range is too large,got 18446744073709551615 elements"},
+		{`sum([1, "a"])`, "ERROR: unsupported element for 'sum': STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+// 测试哈希表内置函数
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`len({})`, "0"},
+		{`sort(keys({"b": 1, "a": 2}))`, "[a,b]"},
+		{`sort(values({"b": 1, "a": 2}))`, "[1,2]"},
+		{`sort(items({"b": 1, "a": 2}), fn(x, y) { x[1] < y[1] })`, "[[b,1],[a,2]]"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({1: 1}, 1)`, "true"},
+		{`let h = {"a": 1, "b": 2}; let d = delete(h, "a"); [len(h), len(d), d["b"]]`, "[2,1,2]"},
+		{`delete({"a": 1}, "z")["a"]`, "1"},
+		{`let m = merge({"a": 1, "b": 2}, {"b": 3}, {"c": 4}); [m["a"], m["b"], m["c"]]`, "[1,3,4]"},
+		{`{"z": 1, "a": 2, "m": 3}`, "{z:1,a:2,m:3}"},
+		{`keys({"z": 1, "a": 2, "m": 3})`, "[z,a,m]"},
+		{`values({"z": 1, "a": 2, "m": 3})`, "[1,2,3]"},
+		{`items({"z": 1, 2: "a"})`, "[[z,1],[2,a]]"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a:3,b:2}"},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, "{a:1,c:3}"},
+		{`merge({"b": 1, "a": 2}, {"c": 3, "b": 4})`, "{b:4,a:2,c:3}"},
+		{`let grid = {[0, 0]: "a", [0, 1]: "b"}; [grid[[0, 1]], grid[[1, 0]]]`, "[b,null]"},
+		{`let x = 2; let y = 3; {[x, y]: 1}[[2, 3]]`, "1"},
+		{`{[1, [2, "z"]]: true}[[1, [2, "z"]]]`, "true"},
+		{`has({[1, 2]: 0}, [1, 2])`, "true"},
+		{`delete({[1, 2]: 0, [2, 1]: 1}, [1, 2])`, "{[2,1]:1}"},
+		{`unique([[1, 2], [1, 2], [2, 1]])`, "[[1,2],[2,1]]"},
+		{`{[fn(x) { x }]: 1}`, "ERROR: unusable as hash key: ARRAY"},
+		{`has({}, fn(x) { x })`, "ERROR: unusable as hash key: FUNCTION"},
+		{`keys([1])`, "ERROR: argument 1 to 'keys' must be HASH,got ARRAY"},
+		{`merge({}, 1)`, "ERROR: argument 2 to 'merge' must be HASH,got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+// 测试JSON编解码
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_encode({"b": 1, "a": [true, first([]), "x"]})`, `{"b":1,"a":[true,null,"x"]}`},
+		{`json_encode(json_parse("[1.5, 2.0, 1e100]"))`, "[1.5,2.0,1e+100]"},
+		{`json_encode("<a&b>")`, `"<a&b>"`},
+		{`json_encode([])`, "[]"},
+		{`json_encode({"a": [1, 2]}, {"indent": 2})`, "{\n  \"a\": [\n    1,\n    2\n  ]\n}"},
+		{`json_parse("[1.5, false, null, -12, 2.0]")`, "[1.5,false,null,-12,2.0]"},
+		{`json_parse(json_encode({"k": [1, {"n": "v"}]}))["k"][1]["n"]`, "v"},
+		{`json_parse("[1, 2")`, "ERROR: json_parse: unexpected end of input at line 1, column 6"},
+		{`json_parse("1 2")`, "ERROR: json_parse: unexpected data after top-level value at line 1, column 4"},
+		{`json_encode(fn(x) { x })`, "ERROR: json_encode: cannot serialize FUNCTION"},
+		{`json_encode({"f": len})`, "ERROR: json_encode: cannot serialize BUILTIN"},
+		{`json_encode({1: 2})`, "ERROR: json_encode: hash key must be STRING, got INTEGER"},
+		{`json_encode(1, {"indent": "x"})`, "ERROR: json_encode: indent must be a non-negative INTEGER, got x"},
+		{`json_encode([[1]], {"indent": 4000000000000})`, "ERROR: json_encode: indent is too large,got 4000000000000"},
+		{`json_encode([[1]], {"indent": 40000000})`, "ERROR: json_encode: indented result is too large,got 120000007 bytes"},
+		{`json_encode(["a,[b"], {"indent": 1})`, "[\n \"a,[b\"\n]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+// Monkey字符串不支持转义，含引号的JSON直接从Go传入
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"z": 1, "a": [1.5, false, null], "s": "h\"i"}`, `{z:1,a:[1.5,false,null],s:h"i}`},
+		{`{"a": [1, 2}`, "ERROR: json_parse: invalid character '}' after array element at line 1, column 12"},
+		{"{\n  \"a\": tru\n}", "ERROR: json_parse: invalid character '\\n' in literal true (expecting 'e') at line 2, column 11"},
+		{`{"a" 1}`, "ERROR: json_parse: invalid character '1' after object key at line 1, column 6"},
+	}
+
+	for _, tt := range tests {
+		result := builtins["json_parse"].Fn(&object.String{Value: tt.input})
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+}
+
+// 测试正则表达式与属性访问
+func TestRegex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`regex("\\d+")`, `/\d+/`},
+		{`regex("\d+").pattern`, `\d+`},
+		{`let re = regex("\\d+"); re.test("abc123")`, "true"},
+		{`regex("^\d+$").test("12a")`, "false"},
+		{`regex("(\w+)@(\w+)").match("mail bob@host now")`, "[bob@host,bob,host]"},
+		{`regex("(\w+)@(\w+)").match("no mail")`, "null"},
+		{`regex("a(x)?b").match("ab")`, "[ab,null]"},
+		{`regex("(?P<key>\w+)=(?P<val>\w+)").match_named("k=v")`, "{key:k,val:v}"},
+		{`regex("\d+").find_all("a1 b22 c333")`, "[1,22,333]"},
+		{`regex("\d+").find_all("a1 b22 c333", 2)`, "[1,22]"},
+		{`regex("(\w)(\d)").find_all("a1 b2")`, "[[a1,a,1],[b2,b,2]]"},
+		{`regex("(\w+)=(\w+)").replace("a=1, b=2", "$2=$1")`, "1=a, 2=b"},
+		{`regex("\d+").replace("a1 b22", fn(m) { "<" + m[0] + ">" })`, "a<1> b<22>"},
+		{`regex("\s*,\s*").split("a , b,c")`, "[a,b,c]"},
+		{`let r = regex("-"); map(["a-b", "c"], r.split)`, "[[a,b],[c]]"},
+		{`regex("(")`, "ERROR: invalid regex: error parsing regexp: missing closing ): `(`"},
+		{`regex("a").nope`, "ERROR: unknown property for REGEX: nope"},
+		{`regex("a").replace("a", 1)`, "ERROR: argument 2 to 'replace' must be STRING or FUNCTION,got INTEGER"},
+		{`regex("a").replace("a", fn(m) { 1 })`, "ERROR: replacement function must return STRING,got INTEGER"},
+		{`let h = {"name": "monkey", "inner": {"n": 1}}; [h.name, h.inner.n, h.missing]`, "[monkey,1,null]"},
+		{`5.x`, "ERROR: property access not supported: INTEGER"},
+		{`"say \"hi\"\tnow"`, "say \"hi\"\tnow"},
+		{`json_parse("{\"a\": [1, 2]}").a`, "[1,2]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5", "1.5"},
+		{"-2.25", "-2.25"},
+		{"1 + 1.5", "2.5"},
+		{"3.0 * 2", "6.0"},
+		{"7 / 2", "3"},
+		{"7 / 2.0", "3.5"},
+		{"1.0 / 0", "+Inf"},
+		{"1 / 0", "ERROR: division by zero"},
+		{"0.1 + 0.2 > 0.3", "true"},
+		{"1 == 1.0", "true"},
+		{"2 < 2.5", "true"},
+		{`{1: "a"}[1.0]`, "a"},
+		{"sum([1, 2.5, 3])", "6.5"},
+		{"sum([1, 2])", "3"},
+		{`format("%.2f", 3.14159)`, "3.14"},
+		{"1.5 + true", "ERROR: type mismatch: FLOAT + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"math.abs(-3)", "3"},
+		{"math.abs(-2.5)", "2.5"},
+		{"math.pow(2, 10)", "1024"},
+		{"math.pow(2, -1)", "0.5"},
+		{"math.pow(4, 0.5)", "2.0"},
+		{"math.sqrt(16)", "4.0"},
+		{"math.floor(2.7)", "2"},
+		{"math.ceil(2.1)", "3"},
+		{"math.round(-2.5)", "-3"},
+		{"math.floor(5)", "5"},
+		{"math.sin(0)", "0.0"},
+		{"math.cos(math.pi)", "-1.0"},
+		{"math.atan2(1, 1) * 4 == math.pi", "true"},
+		{"math.log(math.e)", "1.0"},
+		{"math.log(8, 2)", "3.0"},
+		{"math.log10(1000)", "3.0"},
+		{"math.min(3, 1.5, 2)", "1.5"},
+		{"math.max(3, 1.5, 2)", "3"},
+		{"math.gcd(12, -18)", "6"},
+		{"math.lcm(4, 6)", "12"},
+		{"math.lcm(0, 6)", "0"},
+		{`math.sqrt("4")`, "ERROR: argument 1 to 'sqrt' must be INTEGER or FLOAT,got STRING"},
+		{"math.gcd(1.5, 2)", "ERROR: argument 1 to 'gcd' must be INTEGER,got FLOAT"},
+		{"math.floor(math.sqrt(-1))", "ERROR: cannot convert NaN to INTEGER"},
+		{"math.rand_int(3, 3)", "ERROR: empty range for 'rand_int': [3, 3)"},
+		{"math.nope", "null"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestMathRandom(t *testing.T) {
+	input := "[math.rand_int(100), math.rand_int(-5, 5), math.rand_float()]"
+
+	SeedRandom(42)
+	first := testEval(input).Inspect()
+	SeedRandom(42)
+	second := testEval(input).Inspect()
+	if first != second {
+		t.Errorf("same seed gave different results. first=%s, second=%s", first, second)
+	}
+
+	third := testEval("math.seed(42); " + input).Inspect()
+	if third != first {
+		t.Errorf("math.seed gave different results. got=%s, want=%s", third, first)
+	}
+
+	for i := 0; i < 100; i++ {
+		arr := testEval(input).(*object.Array)
+		n := arr.Elements[0].(*object.Integer).Value
+		m := arr.Elements[1].(*object.Integer).Value
+		f := arr.Elements[2].(*object.Float).Value
+		if n < 0 || n >= 100 || m < -5 || m >= 5 || f < 0 || f >= 1 {
+			t.Fatalf("random value out of range: %s", arr.Inspect())
+		}
+	}
+
+	//区间长度超出int64
+	for _, input := range []string{
+		"math.rand_int(-9223372036854775807, 9223372036854775807)",
+		"math.rand_int(-9223372036854775807 - 1, 9223372036854775807)",
+		"math.rand_int(-1, 9223372036854775807)",
+	} {
+		for i := 0; i < 20; i++ {
+			if _, ok := testEval(input).(*object.Integer); !ok {
+				t.Fatalf("%s did not return an integer", input)
+			}
+		}
+	}
+
+	//多个解释器同时使用随机数，用go test -race检查
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				testEval("math.seed(" + strconv.FormatInt(seed, 10) + "); " + input)
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+}
+
+func TestFileSystem(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	os.Mkdir(root, 0755)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644)
+	os.WriteFile(filepath.Join(root, "in.txt"), []byte("hello"), 0644)
+	os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "outside"))
+	os.Symlink(dir, filepath.Join(root, "up"))
+	os.Symlink("in.txt", filepath.Join(root, "alias"))
+	os.Symlink(filepath.Join(dir, "missing.txt"), filepath.Join(root, "dangling"))
+
+	if out := testEval(`read_file("in.txt")`).Inspect(); out != "ERROR: identifier not found: read_file" {
+		t.Fatalf("file builtins available without a grant: %s", out)
+	}
+
+	env := object.NewEnvironment()
+	if err := GrantFileSystem(env, root); err != nil {
+		t.Fatalf("GrantFileSystem failed: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`read_file("in.txt")`, "hello"},
+		{`read_file("./sub/../in.txt")`, "hello"},
+		{`read_file("alias")`, "hello"},
+		{`write_file("out.txt", "data"); read_file("out.txt")`, "data"},
+		{`mkdir("a/b"); write_file("a/b/c.txt", "x"); list_dir("a/b")`, "[c.txt]"},
+		{`[exists("in.txt"), exists("nope.txt"), exists("a")]`, "[true,false,true]"},
+		{`read_file("nope.txt")`, "ERROR: read_file: nope.txt: no such file or directory"},
+		{`read_file("../secret.txt")`, "ERROR: read_file: path escapes the sandbox: ../secret.txt"},
+		{`read_file("/etc/passwd")`, "ERROR: read_file: path escapes the sandbox: /etc/passwd"},
+		{`read_file("outside")`, "ERROR: read_file: path escapes the sandbox: outside"},
+		{`write_file("up/secret.txt", "pwned")`, "ERROR: write_file: path escapes the sandbox: up/secret.txt"},
+		{`mkdir("up/evil")`, "ERROR: mkdir: path escapes the sandbox: up/evil"},
+		{`exists("up")`, "ERROR: exists: path escapes the sandbox: up"},
+		{`write_file("dangling", "x")`, "ERROR: write_file: cannot resolve dangling"},
+		{`write_file("in.txt", 1)`, "ERROR: argument 2 to 'write_file' must be STRING,got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		evaluated := Eval(p.ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "secret.txt")); string(data) != "secret" {
+		t.Errorf("file outside the sandbox was modified: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil")); err == nil {
+		t.Errorf("directory created outside the sandbox")
+	}
+}
+
+// 解析路径之后、打开之前目录被换成指向外部的符号链接
+func TestFileSystemSymlinkSwap(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	root := filepath.Join(dir, "root")
+	os.MkdirAll(filepath.Join(root, "d"), 0755)
+	os.WriteFile(filepath.Join(root, "d", "f.txt"), []byte("inside"), 0644)
+	os.Mkdir(filepath.Join(dir, "out"), 0755)
+	os.WriteFile(filepath.Join(dir, "out", "f.txt"), []byte("secret"), 0644)
+
+	sb := &sandbox{root: root}
+	path, err := sb.resolve("d/f.txt")
+	if err != nil {
+		t.Fatalf("resolve failed: %s", err)
+	}
+	os.Rename(filepath.Join(root, "d"), filepath.Join(root, "old"))
+	if err := os.Symlink(filepath.Join(dir, "out"), filepath.Join(root, "d")); err != nil {
+		t.Skipf("cannot create symlink: %s", err)
+	}
+
+	for _, flag := range []int{os.O_RDONLY, os.O_WRONLY | os.O_CREATE} {
+		if f, err := sb.open(path, flag); err != errEscape {
+			if f != nil {
+				f.Close()
+			}
+			t.Errorf("open(%d) after the swap returned %v, want errEscape", flag, err)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "out", "f.txt")); string(data) != "secret" {
+		t.Errorf("file outside the sandbox was modified: %q", data)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`let foobar = 8; quote(unquote(foobar) + 1)`, `(8 + 1)`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote("a" + "b"))`, `ab`},
+		{`quote(unquote([1, 2.5]))`, `[1, 2.5]`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))`, `(8 + (4 + 4))`},
+		{`quote(fn(x) { unquote(1 + 1) * x })`, `fn(x)(2 * x)`},
+		{`quote(if (a) { unquote(1) } elif (b) { unquote(2) })`, `if a 1 elif b 2`},
+		{`quote({unquote(1): unquote(2)})`, `{1:2}`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		q, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Errorf("%s: expected *object.Quote. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if q.Node.String() != tt.expected {
+			t.Errorf("%s: wrong quoted code. got=%q, want=%q", tt.input, q.Node.String(), tt.expected)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1, 2)`, "wrong number of arguments to quote: want=1, got=2"},
+		{`quote(unquote())`, "wrong number of arguments to unquote: want=1, got=0"},
+		{`quote(unquote(missing))`, "identifier not found: missing"},
+		{`quote(unquote({}))`, "unquote: cannot convert HASH to code"},
+		{`macro(x) { x }`, "macro literals can only be defined by a top-level let"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Message != tt.expected {
+			t.Errorf("%s: wrong error. got=%v, want=%q", tt.input, evaluated, tt.expected)
+		}
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New(input)).ParseProgram()
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	if _, ok := env.Get("number"); ok {
+		t.Errorf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Errorf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 || macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Errorf("wrong macro parameters. got=%v", macro.Parameters)
+	}
+	if macro.Body.String() != "(x + y)" {
+		t.Errorf("wrong macro body. got=%q", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2) }; infixExpression()`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)) }; reverse(2 + 2, 10 - 5)`,
+			`((10 - 5) - (2 + 2))`,
+		},
+		{
+			`let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };
+			unless(10 > 5, puts("not greater"), puts("greater"))`,
+			`if (!(10 > 5)) puts(not greater) else puts(greater)`,
+		},
+		//实参和展开结果中的宏调用都会展开
+		{
+			`let double = macro(x) { quote(unquote(x) * 2) }; let quad = macro(x) { quote(double(double(unquote(x)))) }; quad(double(1))`,
+			`(((1 * 2) * 2) * 2)`,
+		},
+		//宏体可以在展开时计算
+		{
+			`let six = macro() { let n = len([1, 2, 3]); quote(unquote(n * 2)) }; six()`,
+			`6`,
+		},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if expanded.String() != tt.expected {
+			t.Errorf("wrong expansion.\ngot= %q\nwant=%q", expanded.String(), tt.expected)
+		}
+	}
+
+	//同一个宏多次展开，宏体不受影响
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New(`let inc = macro(x) { quote(unquote(x) + 1) }; inc(1); inc(2)`)).ParseProgram()
+	DefineMacros(program, env)
+	expanded, _ := ExpandMacros(program, env)
+	if expanded.String() != "(1 + 1)(2 + 1)" {
+		t.Errorf("wrong expansion of repeated calls. got=%q", expanded.String())
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = macro(x) { quote(x) }; m()`, "macro m: wrong number of arguments: want=1, got=0"},
+		{`let m = macro() { 1 }; m()`, "macro m must return a quote, got INTEGER"},
+		{`let m = macro() { 1 + true }; m()`, "macro m: type mismatch: INTEGER + BOOLEAN"},
+		{`let m = macro() { quote(m()) }; m()`, "macro m: expansion too deep"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: wrong error. got=%v, want=%q", tt.input, err, tt.expected)
+		}
+	}
+}
+
+// 宏绑定的名称被重命名，不会捕获调用者的变量
+func TestMacroHygiene(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let addOne = macro(e) { quote(fn(tmp) { tmp + unquote(e) }(1)) };
+		let tmp = 100;
+		addOne(tmp)`, 101},
+		{`let twice = macro(e) { quote(if (true) { let t = unquote(e); t + t }) };
+		let t = 5;
+		let r = twice(t * 2);
+		r + t`, 25},
+		{`let swap = macro(a, b) { quote(fn(x, y) { [y, x] }(unquote(a), unquote(b))) };
+		let x = 1; let y = 2;
+		let r = swap(x, y);
+		r[0] * 10 + r[1]`, 21},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		macros := object.NewEnvironment()
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		DefineMacros(program, macros)
+		expanded, err := ExpandMacros(program, macros)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.input, err)
+		}
+		testIntegerObject(t, Eval(expanded, env), tt.expected)
+	}
+}
+
+// 签名中的实参个数应当与内置函数的检查一致
+func TestSignatures(t *testing.T) {
+	env := object.NewEnvironment()
+	if err := GrantFileSystem(env, t.TempDir()); err != nil {
+		t.Fatalf("GrantFileSystem failed: %s", err)
+	}
+	lookup := func(name string) (*object.Builtin, bool) {
+		obj, ok := LookupBuiltin(name)
+		if module, member, found := strings.Cut(name, "."); found {
+			obj, ok = modules[module].Get(&object.String{Value: member})
+		} else if !ok {
+			obj, ok = env.Get(name)
+		}
+		builtin, isBuiltin := obj.(*object.Builtin)
+		return builtin, ok && isBuiltin
+	}
+
+	for _, name := range BuiltinNames() {
+		if _, ok := lookup(name); ok {
+			if _, ok := LookupSignature(name); !ok {
+				t.Errorf("no signature for %s", name)
+			}
+		}
+	}
+
+	nulls := func(n int) []object.Object {
+		args := make([]object.Object, n)
+		for i := range args {
+			args[i] = NULL
+		}
+		return args
+	}
+	for name, sig := range signatures {
+		builtin, ok := lookup(name)
+		if !ok {
+			t.Errorf("signature for unknown builtin %s", name)
+			continue
+		}
+		counts := []int{sig.MinArgs - 1}
+		if sig.MaxArgs >= 0 {
+			counts = append(counts, sig.MaxArgs+1)
+		}
+		for _, n := range counts {
+			if n < 0 {
+				continue
+			}
+			errObj, ok := builtin.Fn(nulls(n)...).(*object.Error)
+			if !ok || !strings.Contains(errObj.Message, "wrong number of arguments") {
+				t.Errorf("%s with %d arguments: expected an argument count error, got %v", sig, n, errObj)
+			}
+		}
+		if errObj, ok := builtin.Fn(nulls(sig.MinArgs)...).(*object.Error); ok && strings.Contains(errObj.Message, "wrong number of arguments") {
+			t.Errorf("%s with %d arguments: %s", sig, sig.MinArgs, errObj.Message)
+		}
+	}
+}
+
+// 整数除以0返回错误，不再使解释器崩溃
+func TestIntegerDivisionByZero(t *testing.T) {
+	tests := []string{"1 / 0", "let x = 0; 10 / x", "[1, 2][0] / (2 - 2)"}
+
+	for _, input := range tests {
+		evaluated := testEval(input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %s. got=%T(%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != "division by zero" {
+			t.Errorf("wrong error message for %s. got=%q", input, errObj.Message)
+		}
+	}
+}
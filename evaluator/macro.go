@@ -0,0 +1,155 @@
+package evaluator
+
+//宏 在求值之前分两步处理程序：
+//  DefineMacros  把顶层let定义的宏存入宏环境，并从程序中删除这些语句
+//  ExpandMacros  以引用的代码为实参执行宏体，用返回的代码替换宏调用
+//宏返回的代码中由宏自己绑定的名称(let和形参)会被重命名，不会捕获调用者传入的代码中的同名变量
+
+import (
+	"fmt"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/object"
+	"sync/atomic"
+)
+
+// 宏展开的结果中又包含宏调用时继续展开，超过该层数视为无限展开
+const maxExpansionDepth = 100
+
+// 重命名时使用的序号，#不能出现在标识符中，新名称不会与源码中的名称冲突
+var gensym uint64
+
+// 定义宏，env中保存已定义的宏，同一个env可用于多个程序
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := make([]ast.Statement, 0, len(program.Statements))
+	for _, s := range program.Statements {
+		if let, ok := s.(*ast.LetStatement); ok {
+			if lit, ok := let.Value.(*ast.MacroLiteral); ok {
+				env.Set(let.Name.Value, &object.Macro{Parameters: lit.Parameters, Body: lit.Body, Env: env})
+				continue
+			}
+		}
+		statements = append(statements, s)
+	}
+	program.Statements = statements
+}
+
+// 展开程序中对env中宏的调用，宏执行出错时返回error
+func ExpandMacros(program *ast.Program, env *object.Environment) (*ast.Program, error) {
+	e := &expander{env: env}
+	program = e.expand(program, 0).(*ast.Program)
+	return program, e.err
+}
+
+type expander struct {
+	env *object.Environment
+	err error //遇到的第一个错误，之后不再展开
+}
+
+// 先展开实参中的宏调用，再展开外层调用；展开结果中的宏调用继续展开
+func (e *expander) expand(node ast.Node, depth int) ast.Node {
+	return ast.Modify(node, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || e.err != nil {
+			return node
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		obj, ok := e.env.Get(ident.Value)
+		if !ok {
+			return node
+		}
+		macro, ok := obj.(*object.Macro)
+		if !ok {
+			return node
+		}
+		if depth >= maxExpansionDepth {
+			e.err = fmt.Errorf("macro %s: expansion too deep", ident.Value)
+			return node
+		}
+
+		expanded, err := expandMacro(ident.Value, macro, call.Arguments)
+		if err != nil {
+			e.err = err
+			return node
+		}
+		return e.expand(expanded, depth+1)
+	})
+}
+
+// 执行宏体，返回替换调用的代码
+func expandMacro(name string, macro *object.Macro, args []ast.Expression) (ast.Expression, error) {
+	if len(args) != len(macro.Parameters) {
+		return nil, fmt.Errorf("macro %s: wrong number of arguments: want=%d, got=%d", name, len(macro.Parameters), len(args))
+	}
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: args[i]})
+	}
+
+	result := unwarpReturnValue(Eval(macro.Body, env))
+	if err, ok := result.(*object.Error); ok {
+		return nil, fmt.Errorf("macro %s: %s", name, err.Message)
+	}
+	quoted, ok := result.(*object.Quote)
+	if !ok {
+		return nil, fmt.Errorf("macro %s must return a quote, got %s", name, typeName(result))
+	}
+	expression, ok := quoted.Node.(ast.Expression)
+	if !ok {
+		return nil, fmt.Errorf("macro %s must return an expression", name)
+	}
+
+	rename(expression, args)
+	return expression, nil
+}
+
+// 重命名宏返回的代码中由宏自己绑定的名称及其引用，调用者传入的代码保持不变
+func rename(node ast.Node, args []ast.Expression) {
+	passed := map[ast.Node]bool{}
+	for _, arg := range args {
+		ast.Inspect(arg, func(n ast.Node) bool {
+			if n != nil {
+				passed[n] = true
+			}
+			return true
+		})
+	}
+
+	bound := map[string]string{}
+	bind := func(name string) {
+		if _, ok := bound[name]; !ok {
+			bound[name] = fmt.Sprintf("%s#%d", name, atomic.AddUint64(&gensym, 1))
+		}
+	}
+	var idents []*ast.Identifier
+	var collect func(n ast.Node) bool
+	collect = func(n ast.Node) bool {
+		if n == nil || passed[n] {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			bind(n.Name.Value)
+		case *ast.FunctionLiteral:
+			for _, p := range n.Parameters {
+				bind(p.Value)
+			}
+		case *ast.Identifier:
+			idents = append(idents, n)
+		case *ast.PropertyExpression:
+			//属性名不是变量
+			ast.Inspect(n.Object, collect)
+			return false
+		}
+		return true
+	}
+	ast.Inspect(node, collect)
+
+	for _, ident := range idents {
+		if fresh, ok := bound[ident.Value]; ok {
+			ident.Value, ident.Token.Literal = fresh, fresh
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package evaluator
+
+//导出的运算 字节码虚拟机复用这些函数，保证两种后端的结果和错误信息一致
+
+import "monkey_Interpreter/object"
+
+// 中缀运算，operator为+、-、<、&&等
+func InfixOperation(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// 前缀运算，operator为!或-
+func PrefixOperation(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// 索引运算 left[index]，strict为true时越界返回错误
+func IndexOperation(left, index object.Object, strict bool) object.Object {
+	return evalIndexExpresssion(left, index, strict)
+}
+
+// 切片运算 left[start:end:step]，省略的部分为nil
+func SliceOperation(left, start, end, step object.Object) object.Object {
+	bounds := make([]*int64, 3)
+	for i, val := range []object.Object{start, end, step} {
+		if val == nil {
+			continue
+		}
+		integer, ok := val.(*object.Integer)
+		if !ok {
+			return newError("slice indices must be INTEGER, got %s", val.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+	return sliceObject(left, bounds)
+}
+
+// 属性访问 obj.name
+func PropertyOperation(obj object.Object, name string) object.Object {
+	return evalPropertyExpression(obj, name)
+}
+
+// 调用函数，fn可以是求值器的函数、内置函数或其他后端的函数
+func ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	return applyFunction(fn, args)
+}
+
+// 判断条件是否成立
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// 按名称查找内置函数或内置模块
+func LookupBuiltin(name string) (object.Object, bool) {
+	if builtin, ok := builtins[name]; ok {
+		return builtin, true
+	}
+	if module, ok := modules[name]; ok {
+		return module, true
+	}
+	return nil, false
+}
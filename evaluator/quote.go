@@ -0,0 +1,91 @@
+package evaluator
+
+//quote(代码)返回未求值的代码，其中的unquote(表达式)先求值，结果转回代码后替换unquote调用
+
+import (
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/token"
+	"strconv"
+)
+
+// 复制代码后再替换unquote调用，宏体中的代码不会被改写，每次展开都得到新的语法树
+func quote(node ast.Node, env *object.Environment) object.Object {
+	var err *object.Error
+	node = ast.Modify(ast.Copy(node), func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil || !isCallTo(call, "unquote") {
+			return node
+		}
+		if len(call.Arguments) != 1 {
+			err = newError("wrong number of arguments to unquote: want=1, got=%d", len(call.Arguments))
+			return node
+		}
+
+		value := Eval(call.Arguments[0], env)
+		if isError(value) {
+			err = value.(*object.Error)
+			return node
+		}
+		converted, ok := objectToNode(value, call.Token)
+		if !ok {
+			err = newError("unquote: cannot convert %s to code", typeName(value))
+			return node
+		}
+		return converted
+	})
+	if err != nil {
+		return err
+	}
+	return &object.Quote{Node: node}
+}
+
+// 调用的是否为指定名称的特殊形式(quote或unquote)
+func isCallTo(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// 把unquote的结果转回代码，引用的代码原样插入
+func objectToNode(obj object.Object, at token.Token) (ast.Expression, bool) {
+	tok := token.Token{Line: at.Line}
+	switch obj := obj.(type) {
+	case *object.Quote:
+		e, ok := obj.Node.(ast.Expression)
+		return e, ok
+	case *object.Integer:
+		tok.Type, tok.Literal = token.INT, strconv.FormatInt(obj.Value, 10)
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}, true
+	case *object.Float:
+		tok.Type, tok.Literal = token.FLOAT, obj.Inspect()
+		return &ast.FloatLiteral{Token: tok, Value: obj.Value}, true
+	case *object.String:
+		tok.Type, tok.Literal = token.STRING, obj.Value
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}, true
+	case *object.Boolean:
+		tok.Type, tok.Literal = token.FALSE, "false"
+		if obj.Value {
+			tok.Type, tok.Literal = token.TRUE, "true"
+		}
+		return &ast.Boolean{Token: tok, Value: obj.Value}, true
+	case *object.Array:
+		tok.Type, tok.Literal = token.LBRACKET, "["
+		array := &ast.ArrayLiteral{Token: tok, Elements: make([]ast.Expression, len(obj.Elements))}
+		for i, el := range obj.Elements {
+			e, ok := objectToNode(el, at)
+			if !ok {
+				return nil, false
+			}
+			array.Elements[i] = e
+		}
+		return array, true
+	}
+	return nil, false
+}
+
+func typeName(obj object.Object) string {
+	if obj == nil {
+		return "NOTHING"
+	}
+	return string(obj.Type())
+}
@@ -0,0 +1,126 @@
+package evaluator
+
+//内置函数的签名 供monkey vet检查实参个数、编辑器显示提示使用
+
+// 内置函数的签名
+type Signature struct {
+	Name    string //函数名，模块成员为math.sqrt的形式
+	Params  string //形参，[]中的可以省略，...表示任意多个
+	Doc     string //说明
+	MinArgs int
+	MaxArgs int //-1表示不限
+}
+
+func (s Signature) String() string {
+	return s.Name + "(" + s.Params + ")"
+}
+
+// 实参个数是否符合签名
+func (s Signature) Accepts(n int) bool {
+	return n >= s.MinArgs && (s.MaxArgs < 0 || n <= s.MaxArgs)
+}
+
+var signatures = map[string]Signature{}
+
+func init() {
+	for _, s := range []Signature{
+		{"len", "x", "Returns the number of characters in a string, elements in an array or pairs in a hash.", 1, 1},
+		{"first", "arr", "Returns the first element of an array, or null if it is empty.", 1, 1},
+		{"last", "arr", "Returns the last element of an array, or null if it is empty.", 1, 1},
+		{"rest", "arr", "Returns a new array without the first element.", 1, 1},
+		{"push", "arr, x", "Returns a new array with x appended.", 2, 2},
+		{"put", "values...", "Prints each value on its own line.", 0, -1},
+
+		{"map", "arr, fn", "Returns the results of calling fn(x) on each element.", 2, 2},
+		{"filter", "arr, fn", "Returns the elements for which fn(x) is truthy.", 2, 2},
+		{"reduce", "arr, fn[, initial]", "Folds the array with fn(acc, x).", 2, 3},
+		{"sort", "arr[, fn]", "Returns a sorted copy; fn(a, b) is truthy when a goes before b.", 1, 2},
+		{"reverse", "x", "Reverses an array or a string.", 1, 1},
+		{"zip", "arrs...", "Combines arrays element by element, up to the shortest one.", 1, -1},
+		{"enumerate", "arr", "Returns [[0, x0], [1, x1], ...].", 1, 1},
+		{"range", "[start, ]end[, step]", "Returns the integers from start up to end.", 1, 3},
+		{"any", "arr[, fn]", "Reports whether any element (or fn(x)) is truthy.", 1, 2},
+		{"all", "arr[, fn]", "Reports whether every element (or fn(x)) is truthy.", 1, 2},
+		{"sum", "arr", "Returns the sum of the numbers in an array.", 1, 1},
+		{"min", "arr | values...", "Returns the smallest element.", 0, -1},
+		{"max", "arr | values...", "Returns the largest element.", 0, -1},
+		{"unique", "arr", "Removes duplicates, keeping the first occurrence.", 1, 1},
+		{"flatten", "arr[, depth]", "Flattens nested arrays; a negative depth flattens all levels.", 1, 2},
+
+		{"keys", "hash", "Returns the keys of a hash.", 1, 1},
+		{"values", "hash", "Returns the values of a hash.", 1, 1},
+		{"items", "hash", "Returns the pairs of a hash as [[k, v], ...].", 1, 1},
+		{"has", "hash, key", "Reports whether the hash contains key.", 2, 2},
+		{"delete", "hash, key", "Returns a new hash without key.", 2, 2},
+		{"merge", "hashes...", "Merges hashes; later keys win.", 1, -1},
+
+		{"json_encode", "value[, options]", "Encodes a value as JSON; options may set \"indent\".", 1, 2},
+		{"json_parse", "s", "Parses a JSON string.", 1, 1},
+
+		{"split", "s[, sep]", "Splits a string on whitespace or on sep.", 1, 2},
+		{"join", "arr, sep", "Joins the elements of an array with sep.", 2, 2},
+		{"trim", "s[, cutset]", "Removes leading and trailing whitespace or characters in cutset.", 1, 2},
+		{"upper", "s", "Converts a string to upper case.", 1, 1},
+		{"lower", "s", "Converts a string to lower case.", 1, 1},
+		{"replace", "s, old, new[, n]", "Replaces old with new, at most n times if n is given.", 3, 4},
+		{"contains", "s, sub", "Reports whether sub is in s.", 2, 2},
+		{"starts_with", "s, prefix", "Reports whether s begins with prefix.", 2, 2},
+		{"ends_with", "s, suffix", "Reports whether s ends with suffix.", 2, 2},
+		{"index_of", "s, sub", "Returns the character index of sub in s, or -1.", 2, 2},
+		{"repeat", "s, n", "Repeats a string n times.", 2, 2},
+		{"pad_left", "s, width[, pad]", "Pads a string on the left to width.", 2, 3},
+		{"pad_right", "s, width[, pad]", "Pads a string on the right to width.", 2, 3},
+		{"chars", "s", "Splits a string into characters.", 1, 1},
+		{"format", "format, args...", "Formats values printf-style.", 1, -1},
+
+		{"regex", "pattern", "Compiles a regular expression (Go regexp syntax).", 1, 1},
+
+		{"math.abs", "x", "Returns the absolute value of x.", 1, 1},
+		{"math.pow", "x, y", "Returns x to the power y.", 2, 2},
+		{"math.sqrt", "x", "Returns the square root of x.", 1, 1},
+		{"math.exp", "x", "Returns e to the power x.", 1, 1},
+		{"math.log", "x[, base]", "Returns the natural logarithm of x, or the logarithm in base.", 1, 2},
+		{"math.log10", "x", "Returns the base 10 logarithm of x.", 1, 1},
+		{"math.sin", "x", "Returns the sine of x.", 1, 1},
+		{"math.cos", "x", "Returns the cosine of x.", 1, 1},
+		{"math.tan", "x", "Returns the tangent of x.", 1, 1},
+		{"math.asin", "x", "Returns the arcsine of x.", 1, 1},
+		{"math.acos", "x", "Returns the arccosine of x.", 1, 1},
+		{"math.atan", "x", "Returns the arctangent of x.", 1, 1},
+		{"math.atan2", "y, x", "Returns the arctangent of y/x.", 2, 2},
+		{"math.floor", "x", "Rounds x down to an integer.", 1, 1},
+		{"math.ceil", "x", "Rounds x up to an integer.", 1, 1},
+		{"math.round", "x", "Rounds x to the nearest integer.", 1, 1},
+		{"math.min", "values...", "Returns the smallest number.", 1, -1},
+		{"math.max", "values...", "Returns the largest number.", 1, -1},
+		{"math.gcd", "a, b", "Returns the greatest common divisor.", 2, 2},
+		{"math.lcm", "a, b", "Returns the least common multiple.", 2, 2},
+		{"math.rand_int", "[min, ]max", "Returns a random integer in [min, max).", 1, 2},
+		{"math.rand_float", "", "Returns a random float in [0, 1).", 0, 0},
+		{"math.seed", "n", "Seeds the random number generator.", 1, 1},
+
+		//只有使用-fs运行时才可用
+		{"read_file", "path", "Reads a file under the -fs directory.", 1, 1},
+		{"write_file", "path, content", "Writes a file under the -fs directory.", 2, 2},
+		{"list_dir", "path", "Lists a directory under the -fs directory.", 1, 1},
+		{"exists", "path", "Reports whether a path under the -fs directory exists.", 1, 1},
+		{"mkdir", "path", "Creates a directory under the -fs directory.", 1, 1},
+	} {
+		signatures[s.Name] = s
+	}
+}
+
+// 按名称查找内置函数的签名，如len、math.sqrt
+func LookupSignature(name string) (Signature, bool) {
+	s, ok := signatures[name]
+	return s, ok
+}
+
+// 文件系统函数，只有授予权限后才会定义
+func IsFileSystemBuiltin(name string) bool {
+	switch name {
+	case "read_file", "write_file", "list_dir", "exists", "mkdir":
+		return true
+	}
+	return false
+}
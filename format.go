@@ -0,0 +1,104 @@
+package main
+
+//monkey fmt 格式化源码文件
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"monkey_Interpreter/format"
+	"os"
+)
+
+const formatUsage = `usage: monkey fmt [flags] [files...]
+
+Formats Monkey source files. Without files, formats standard input.
+
+flags:
+`
+
+// monkey fmt子命令，返回退出状态码
+func runFormat(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, formatUsage)
+		flags.PrintDefaults()
+	}
+	write := flags.Bool("w", false, "write the result back to the source file instead of standard output")
+	diff := flags.Bool("d", false, "print a diff of the changes instead of the formatted source")
+	indent := flags.Int("indent", format.DefaultConfig.Indent, "indent each level by `n` spaces")
+	tabs := flags.Bool("tabs", false, "indent with tabs")
+	width := flags.Int("width", format.DefaultConfig.Width, "wrap arrays, hashes and call arguments that go past column `n`")
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+	if *indent <= 0 || *width <= 0 {
+		fmt.Fprintln(stderr, "monkey fmt: -indent and -width must be positive")
+		return exitUsage
+	}
+	config := format.Config{Indent: *indent, Tabs: *tabs, Width: *width}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "monkey fmt: -w needs a file")
+			return exitUsage
+		}
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+			return exitError
+		}
+		return formatFile("<stdin>", src, config, false, *diff, stdout, stderr)
+	}
+
+	code := exitOK
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			code = exitError
+			continue
+		}
+		if c := formatFile(name, src, config, *write, *diff, stdout, stderr); c != exitOK {
+			code = c
+		}
+	}
+	return code
+}
+
+// 格式化一个文件：write为true时写回文件，diff为true时输出差异，都为false时输出格式化结果
+func formatFile(name string, src []byte, config format.Config, write, diff bool, stdout, stderr io.Writer) int {
+	out, err := format.Source(src, config)
+	if err != nil {
+		var parseErr format.ParseError
+		if errors.As(err, &parseErr) {
+			for _, msg := range parseErr {
+				fmt.Fprintf(stderr, "%s: %s\n", name, msg)
+			}
+		} else {
+			fmt.Fprintf(stderr, "%s: %s\n", name, err)
+		}
+		return exitError
+	}
+
+	if diff {
+		io.WriteString(stdout, format.Diff(name+".orig", name, src, out))
+	}
+	if write && !bytes.Equal(src, out) {
+		info, err := os.Stat(name)
+		if err == nil {
+			err = os.WriteFile(name, out, info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			return exitError
+		}
+	}
+	if !write && !diff {
+		stdout.Write(out)
+	}
+	return exitOK
+}
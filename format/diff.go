@@ -0,0 +1,175 @@
+package format
+
+//按行比较格式化前后的源码，输出统一格式(unified)的差异，用于monkey fmt -d
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 差异中保留的上下文行数
+const contextLines = 3
+
+// 一行的变化
+type edit struct {
+	op   byte //' '不变，'-'删除，'+'插入
+	line string
+}
+
+// 比较old和new，相同时返回空字符串
+func Diff(oldName, newName string, old, new []byte) string {
+	a, b := splitLines(string(old)), splitLines(string(new))
+	edits := diffLines(a, b)
+
+	var out strings.Builder
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		//差异前后各保留contextLines行，间隔不超过2*contextLines行的差异合并到同一段
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		end, same := i, 0
+		for j := i; j < len(edits) && same <= 2*contextLines; j++ {
+			if edits[j].op == ' ' {
+				same++
+			} else {
+				end, same = j+1, 0
+			}
+		}
+		stop := end + contextLines
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		writeHunk(&out, edits, start, stop)
+		i = stop
+	}
+	return out.String()
+}
+
+// 输出edits[start:stop]，包括@@ -行号,行数 +行号,行数 @@
+func writeHunk(out *strings.Builder, edits []edit, start, stop int) {
+	oldLine, newLine := 1, 1
+	for _, e := range edits[:start] {
+		if e.op != '+' {
+			oldLine++
+		}
+		if e.op != '-' {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, e := range edits[start:stop] {
+		if e.op != '+' {
+			oldCount++
+		}
+		if e.op != '-' {
+			newCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+
+	for _, e := range edits[start:stop] {
+		out.WriteByte(e.op)
+		out.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// 行数为0时行号为这段之前的一行
+func hunkRange(line, count int) string {
+	if count == 0 {
+		line--
+	}
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// 按行切分，每行保留结尾的换行符
+func splitLines(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		lines = append(lines, s[:i])
+		s = s[i:]
+	}
+	return lines
+}
+
+// Myers差分算法，求从a变为b的最短编辑序列
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2) //v[k+max]为第k条对角线上走到的最远的x
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[k-1+max] < v[k+1+max] {
+				x = v[k+1+max] //从上方下移，插入b中的一行
+			} else {
+				x = v[k-1+max] + 1 //从左侧右移，删除a中的一行
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[k+max] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	//从终点沿记录的路径倒推
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[k-1+max] < v[k+1+max] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+max]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			edits = append(edits, edit{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, edit{'+', b[y]})
+			} else {
+				x--
+				edits = append(edits, edit{'-', a[x]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,123 @@
+package format
+
+//格式化 把源码按统一的风格重新输出，保留注释和空行
+//  运算符两侧加空格，只在优先级需要时加括号
+//  每条语句一行，块按层缩进
+//  数组、哈希表和调用的实参超出行宽时每项一行，末尾加逗号
+//  源码中写在一行内的单语句块在行宽允许时保持在一行内
+//对格式化的结果再次格式化，结果不变
+
+import (
+	"errors"
+	"fmt"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/parser"
+	"monkey_Interpreter/token"
+	"strings"
+)
+
+// 格式化选项
+type Config struct {
+	Indent int  //每层缩进的空格数
+	Tabs   bool //使用制表符缩进，计算行宽时一个制表符按Indent列计
+	Width  int  //行宽，超出时折行
+}
+
+// 默认选项
+var DefaultConfig = Config{Indent: 4, Width: 80}
+
+// 源码中的语法错误
+type ParseError []string
+
+func (e ParseError) Error() string {
+	return strings.Join(e, "\n")
+}
+
+// 格式化源码，有语法错误时返回ParseError
+func Source(src []byte, config Config) ([]byte, error) {
+	text := string(src)
+
+	//脚本开头的#!行原样保留
+	header := ""
+	if strings.HasPrefix(text, "#!") {
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			header, text = text[:i+1], text[i+1:]
+		} else {
+			header, text = text+"\n", ""
+		}
+	}
+
+	l := lexer.New(text)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, ParseError(p.Errors())
+	}
+
+	pr, err := newPrinter(text, l.Comments(), config)
+	if err != nil {
+		return nil, err
+	}
+	out := pr.program(program)
+
+	//格式化只改变排版，不能改变程序
+	check := parser.New(lexer.New(out))
+	if formatted := check.ParseProgram(); len(check.Errors()) != 0 || formatted.String() != program.String() {
+		return nil, errors.New("format: formatting changed the program")
+	}
+	//语法分析时被跳过的代码(如return x y;中的y)不在语法树中，格式化后会丢失
+	if tok, ok := dropped(text, out); ok {
+		return nil, ParseError{fmt.Sprintf("line %d: unexpected %q", tok.Line, tok.Literal)}
+	}
+	return []byte(header + out), nil
+}
+
+// 格式化只增删分号、逗号、切片中的冒号和括号，其他词法单元应当原样保留
+// 返回源码中第一个在格式化结果里缺失的词法单元
+func dropped(src, out string) (token.Token, bool) {
+	a, b := significant(src), significant(out)
+	for i, tok := range a {
+		if i >= len(b) || b[i].Type != tok.Type || b[i].Literal != tok.Literal {
+			return tok, true
+		}
+	}
+	return token.Token{}, false
+}
+
+func significant(src string) []token.Token {
+	var tokens []token.Token
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.SEMICOLON, token.COMMA, token.COLON, token.LPAREN, token.RPAREN:
+		default:
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+// 词法分析器遇到无法识别的字符时返回EOF，语法分析会在此处提前结束
+// 格式化之前先检查整个输入都被读完，避免丢掉后面的代码
+func checkTokens(src string) (map[int]token.Token, error) {
+	closing := map[int]token.Token{}
+	var open []token.Token
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		switch tok.Type {
+		case token.EOF:
+			if tok.Offset < len(src) {
+				return nil, ParseError{fmt.Sprintf("line %d: unexpected character %q", tok.Line, src[tok.Offset])}
+			}
+			return closing, nil
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open = append(open, tok)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(open) > 0 {
+				closing[open[len(open)-1].Offset] = tok
+				open = open[:len(open)-1]
+			}
+		}
+	}
+}
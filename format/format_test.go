@@ -0,0 +1,203 @@
+package format
+
+import (
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/parser"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"((a + b) * c)", "(a + b) * c;\n"},
+		{"a - (b - c); (a - b) - c; a / (b * c)", "a - (b - c);\na - b - c;\na / (b * c);\n"},
+		{"-(-x); -(a + b); !(a == b); -a[0]; (-a)[0]", "-(-x);\n-(a + b);\n!(a == b);\n-a[0];\n(-a)[0];\n"},
+		{"(a && b) || c; a && (b || c)", "a && b || c;\na && (b || c);\n"},
+		{"f(x)(y); (f + g)(x); a.b.c; (a + b).c; s[1:]; s[::2]; s[1:2:3]", "f(x)(y);\n(f + g)(x);\na.b.c;\n(a + b).c;\ns[1:];\ns[::2];\ns[1:2:3];\n"},
+		{`let s = "a\"b\d\n";`, "let s = \"a\\\"b\\d\\n\";\n"},
+		{"let f = fn(a,b) { return a+b; }", "let f = fn(a, b) { return a + b; };\n"},
+		{"let f = fn(a,b) {\n  let c = a; c }", "let f = fn(a, b) {\n    let c = a;\n    c\n};\n"},
+		{"let f = fn() {}; let h = {}; let a = []; f()", "let f = fn() {};\nlet h = {};\nlet a = [];\nf();\n"},
+		{"if (x) { 1 } elif (y) { 2 } else { 3 }", "if (x) { 1 } elif (y) { 2 } else { 3 }\n"},
+		{"if (x) { 1 } else {\n2 }", "if (x) {\n    1\n} else {\n    2\n}\n"},
+		//if之后是(、[或-时保留分号
+		{"if (x) { 1 }; -1; if (x) { 1 }; let y = 2", "if (x) { 1 };\n-1;\nif (x) { 1 }\nlet y = 2;\n"},
+		{"let m = macro(c, a) { quote(if (!(unquote(c))) { unquote(a) }) };", "let m = macro(c, a) { quote(if (!unquote(c)) { unquote(a) }) };\n"},
+		{"[1, 2,]; f(a,\n  b,\n)", "[1, 2];\nf(a, b);\n"},
+		{"", ""},
+		{"#!/usr/bin/env monkey\nput(1)", "#!/usr/bin/env monkey\nput(1);\n"},
+	}
+
+	for _, tt := range tests {
+		testFormat(t, tt.input, DefaultConfig, tt.expected)
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"// 开头\nlet a = 1; // 行尾\n\n\n\n// 空行只保留一个\na", "// 开头\nlet a = 1; // 行尾\n\n// 空行只保留一个\na;\n"},
+		{"let f = fn() {\n  // 第一行\n  1 // 值\n  // 结尾\n}", "let f = fn() {\n    // 第一行\n    1 // 值\n    // 结尾\n};\n"},
+		{"if (x) { // 注释\n}", "if (x) {\n    // 注释\n}\n"},
+		//列表中有注释时每项一行
+		{"let a = [1, // 一\n2]", "let a = [\n    1, // 一\n    2,\n];\n"},
+		{"let h = {\n  // 名称\n  \"k\": 1,\n  // 结尾\n}", "let h = {\n    // 名称\n    \"k\": 1,\n    // 结尾\n};\n"},
+		//不在块或列表中的注释移到语句之后
+		{"let a = 1 + // 一\n 2; // 二\nb", "let a = 1 + 2;\n// 一\n// 二\nb;\n"},
+		{"// 只有注释", "// 只有注释\n"},
+	}
+
+	for _, tt := range tests {
+		testFormat(t, tt.input, DefaultConfig, tt.expected)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let xs = [1, 2, 3, 4, 5];", "let xs = [1, 2, 3, 4, 5];\n"},
+		{"let xs = [100, 200, 300, 400, 500];", "let xs = [\n    100,\n    200,\n    300,\n    400,\n    500,\n];\n"},
+		{`let h = {"name": "monkey", "tags": ["a", "b"]}`, "let h = {\n    \"name\": \"monkey\",\n    \"tags\": [\"a\", \"b\"],\n};\n"},
+		{`put(first, second, third, fourth)`, "put(\n    first,\n    second,\n    third,\n    fourth,\n);\n"},
+		//最后一个实参是函数时不折行
+		{"map(xs, fn(x) { let y = x; y })", "map(xs, fn(x) {\n    let y = x;\n    y\n});\n"},
+		{"let f = fn() { [100, 200, 300, 400] }", "let f = fn() {\n    [100, 200, 300, 400]\n};\n"},
+		{"let f = fn() { return [100, 200, 300, 400, 500]; }", "let f = fn() {\n    return [\n        100,\n        200,\n        300,\n        400,\n        500,\n    ];\n};\n"},
+	}
+
+	config := Config{Indent: 4, Width: 30}
+	for _, tt := range tests {
+		testFormat(t, tt.input, config, tt.expected)
+		out, _ := Source([]byte(tt.input), config)
+		for _, line := range strings.Split(string(out), "\n") {
+			if len(line) > config.Width {
+				t.Errorf("line longer than %d: %q", config.Width, line)
+			}
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	input := "let f = fn(x) { if (x) {\nput([100, 200, 300]) } }"
+	testFormat(t, input, Config{Indent: 2, Width: 20}, "let f = fn(x) {\n  if (x) {\n    put([\n      100,\n      200,\n      300,\n    ])\n  }\n};\n")
+	testFormat(t, input, Config{Tabs: true, Indent: 8, Width: 40}, "let f = fn(x) {\n\tif (x) {\n\t\tput([100, 200, 300])\n\t}\n};\n")
+	testFormat(t, input, Config{}, "let f = fn(x) {\n    if (x) {\n        put([100, 200, 300])\n    }\n};\n")
+}
+
+// 格式化的结果再次格式化不变，且程序不变
+func TestIdempotent(t *testing.T) {
+	inputs := []string{
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; put(fib(10))",
+		"let config = {\"server\": {\"host\": \"localhost\", \"port\": 8080}, \"debug\": true, \"paths\": [\"/usr/bin\", \"/usr/local/bin\"]};",
+		"put(reduce(map(range(100), fn(x) { x * x }), 0, fn(acc, x) { acc + x }), \"done\");",
+		"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(x > 1, put(\"a\"), put(\"b\"))",
+		"// 头部\n\nlet a = [1, // 一\n 2, 3 // 三\n]; // 尾\nlet b = 1 + // 中间\n 2\n\n\n// 结尾",
+		"if (x) { // a\n 1 } elif (y) { 2 } // b\n else { // c\n }",
+		"let f = fn(a, b) {\n\n  // 说明\n\n  let c = a * (b + 1)\n  return {a: [c, c], \"b\": fn() { c }};\n};\nf(1, 2)[\"b\"]()",
+		"let s = \"中文字符串中文字符串中文字符串\"; put([s, s, s, s, s]);",
+		"if (x) { 1 }; -1; if (y) { 2 }; [1][0]; (fn(x) { x })(1)",
+		"a[1:2:3][0].b(c)[::]; -(-(-1)); !!x; x - -1",
+	}
+
+	configs := []Config{DefaultConfig, {Indent: 2, Width: 20}, {Tabs: true, Indent: 4, Width: 40}, {Indent: 4, Width: 1}}
+	for _, config := range configs {
+		for _, input := range inputs {
+			once, err := Source([]byte(input), config)
+			if err != nil {
+				t.Fatalf("Source(%q) failed: %s", input, err)
+			}
+			twice, err := Source(once, config)
+			if err != nil {
+				t.Fatalf("formatting %q again failed: %s\n%s", input, err, once)
+			}
+			if string(once) != string(twice) {
+				t.Errorf("formatting twice differs for %q (%+v).\nonce=\n%s\ntwice=\n%s", input, config, once, twice)
+			}
+			if parse(t, input) != parse(t, string(once)) {
+				t.Errorf("formatting changed the program %q.\ngot=\n%s", input, once)
+			}
+			if got, want := strings.Count(string(once), "//"), strings.Count(input, "//"); got != want {
+				t.Errorf("comments lost for %q. got=%d, want=%d\n%s", input, got, want, once)
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 1;", `expected next token to be "IDENT"`},
+		{"let a = 1; a & b; let c = 2;", `line 1: unexpected character '&'`},
+		{"let f = fn() {\n  return 993 322;\n}", `line 2: unexpected "322"`},
+	}
+
+	for _, tt := range tests {
+		_, err := Source([]byte(tt.input), DefaultConfig)
+		if _, ok := err.(ParseError); !ok || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Source(%q) wrong error. got=%v, want to contain %q", tt.input, err, tt.expected)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	expected := `--- x.orig
++++ x
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -10,4 +10,5 @@
+ j
+ k
+ l
+-m
+\ No newline at end of file
++m
++n
+`
+	if got := Diff("x.orig", "x", []byte(old), []byte(new)); got != expected {
+		t.Errorf("wrong diff.\ngot=\n%s\nwant=\n%s", got, expected)
+	}
+	if got := Diff("x.orig", "x", []byte(new), []byte(new)); got != "" {
+		t.Errorf("expected no diff for equal input. got=%q", got)
+	}
+	if got := Diff("x.orig", "x", nil, []byte("a\n")); got != "--- x.orig\n+++ x\n@@ -0,0 +1 @@\n+a\n" {
+		t.Errorf("wrong diff for empty input. got=%q", got)
+	}
+}
+
+func testFormat(t *testing.T, input string, config Config, expected string) {
+	t.Helper()
+	got, err := Source([]byte(input), config)
+	if err != nil {
+		t.Fatalf("Source(%q) failed: %s", input, err)
+	}
+	if string(got) != expected {
+		t.Errorf("Source(%q) wrong.\ngot=\n%s\nwant=\n%s", input, got, expected)
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program.String()
+}
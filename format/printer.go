@@ -0,0 +1,585 @@
+package format
+
+import (
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// 运算符优先级，与parser中的一致
+const (
+	_ int = iota
+	lowest
+	and
+	equals
+	lessGreater
+	sum
+	product
+	prefix
+	call
+	primary //字面量、标识符等不需要括号的表达式
+)
+
+var precedences = map[string]int{
+	"&&": and,
+	"||": and,
+	"==": equals,
+	"!=": equals,
+	"<":  lessGreater,
+	">":  lessGreater,
+	"+":  sum,
+	"-":  sum,
+	"*":  product,
+	"/":  product,
+}
+
+type printer struct {
+	config   Config
+	src      string
+	comments []token.Token
+	used     []bool              //注释是否已经输出
+	closing  map[int]token.Token //左括号的位置 -> 对应的右括号
+}
+
+func newPrinter(src string, comments []token.Token, config Config) (*printer, error) {
+	closing, err := checkTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	if config.Indent <= 0 {
+		config.Indent = DefaultConfig.Indent
+	}
+	if config.Width <= 0 {
+		config.Width = DefaultConfig.Width
+	}
+	return &printer{
+		config:   config,
+		src:      src,
+		comments: comments,
+		used:     make([]bool, len(comments)),
+		closing:  closing,
+	}, nil
+}
+
+// 节点在源码中的范围
+type span struct {
+	start, end     int //起止位置，end指向节点之后
+	line, lastLine int //起止行
+}
+
+func (s *span) add(tok token.Token) {
+	if s.line == 0 || tok.Offset < s.start {
+		s.start, s.line = tok.Offset, tok.Line
+	}
+	if tok.End > s.end {
+		s.end, s.lastLine = tok.End, tok.Line
+	}
+}
+
+func (p *printer) span(node ast.Node) span {
+	var s span
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if tok, ok := tokenOf(n); ok {
+			s.add(tok)
+			//调用、索引、数组、哈希表和块的范围到右括号为止
+			if c, ok := p.closing[tok.Offset]; ok && isOpening(tok) {
+				s.add(c)
+			}
+		}
+		return true
+	})
+	return s
+}
+
+func isOpening(tok token.Token) bool {
+	return tok.Type == token.LPAREN || tok.Type == token.LBRACKET || tok.Type == token.LBRACE
+}
+
+func tokenOf(node ast.Node) (token.Token, bool) {
+	switch n := node.(type) {
+	case *ast.LetStatement:
+		return n.Token, true
+	case *ast.ReturnStatement:
+		return n.Token, true
+	case *ast.ExpressionStatement:
+		return n.Token, true
+	case *ast.BlockStatement:
+		return n.Token, true
+	case *ast.Identifier:
+		return n.Token, true
+	case *ast.IntegerLiteral:
+		return n.Token, true
+	case *ast.FloatLiteral:
+		return n.Token, true
+	case *ast.StringLiteral:
+		return n.Token, true
+	case *ast.Boolean:
+		return n.Token, true
+	case *ast.PrefixExpression:
+		return n.Token, true
+	case *ast.InfixExpression:
+		return n.Token, true
+	case *ast.IfExpression:
+		return n.Token, true
+	case *ast.ElIfExpression:
+		return n.Token, true
+	case *ast.FunctionLiteral:
+		return n.Token, true
+	case *ast.MacroLiteral:
+		return n.Token, true
+	case *ast.CallExpression:
+		return n.Token, true
+	case *ast.ArrayLiteral:
+		return n.Token, true
+	case *ast.IndexExpression:
+		return n.Token, true
+	case *ast.SliceExpression:
+		return n.Token, true
+	case *ast.PropertyExpression:
+		return n.Token, true
+	case *ast.HashLiteral:
+		return n.Token, true
+	}
+	return token.Token{}, false
+}
+
+// ----------------------------------------注释----------------------------------------
+
+// 取出[from, to)范围内尚未输出的注释，标记为已输出
+func (p *printer) take(from, to int) []token.Token {
+	var taken []token.Token
+	for i, c := range p.comments {
+		if !p.used[i] && c.Offset >= from && c.Offset < to {
+			p.used[i] = true
+			taken = append(taken, c)
+		}
+	}
+	return taken
+}
+
+// [from, to)范围内是否还有未输出的注释
+func (p *printer) pending(from, to int) bool {
+	for i, c := range p.comments {
+		if !p.used[i] && c.Offset >= from && c.Offset < to {
+			return true
+		}
+	}
+	return false
+}
+
+// 尝试不同排版前保存注释的输出状态
+func (p *printer) save() []bool {
+	return append([]bool(nil), p.used...)
+}
+
+func (p *printer) restore(used []bool) {
+	copy(p.used, used)
+}
+
+// ----------------------------------------排版----------------------------------------
+
+func (p *printer) indent(depth int) string {
+	if p.config.Tabs {
+		return strings.Repeat("\t", depth)
+	}
+	return strings.Repeat(" ", depth*p.config.Indent)
+}
+
+// 文本所占的列数
+func (p *printer) width(s string) int {
+	return utf8.RuneCountInString(s) + strings.Count(s, "\t")*(p.config.Indent-1)
+}
+
+// 从col列开始输出s之后所在的列
+func (p *printer) column(col int, s string) int {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return p.width(s[i+1:])
+	}
+	return col + p.width(s)
+}
+
+// 从col列开始输出s，且之后还有tail列时是否超出行宽
+func (p *printer) fits(s string, col, tail int) bool {
+	first, rest, multiline := strings.Cut(s, "\n")
+	if !multiline {
+		return col+p.width(first)+tail <= p.config.Width
+	}
+	return col+p.width(first) <= p.config.Width && p.column(col, rest)+tail <= p.config.Width
+}
+
+// 逐行排列的一项：语句、数组元素、实参或键值对
+type item struct {
+	span   span
+	render func(depth, col, tail int) string
+}
+
+// 每项一行，保留项之间的注释和空行(连续的空行只保留一个)
+// from、to为所在块或列表的范围，sep返回每项之后的分隔符
+func (p *printer) lines(items []item, depth, from, to int, sep func(i int) string) string {
+	var out strings.Builder
+	prev := 0 //上一行在源码中的行号
+	emit := func(text string, line, lastLine int) {
+		if prev > 0 && line > prev+1 {
+			out.WriteString("\n")
+		}
+		out.WriteString(p.indent(depth) + text + "\n")
+		prev = lastLine
+	}
+
+	for i, it := range items {
+		for _, c := range p.take(from, it.span.start) {
+			emit(c.Literal, c.Line, c.Line)
+		}
+		s := sep(i)
+		text := it.render(depth, p.width(p.indent(depth)), len(s)) + s
+
+		//项内没能放到原处的注释移到这一项之后
+		inner := p.take(it.span.start, it.span.end)
+		next := to
+		if i+1 < len(items) {
+			next = items[i+1].span.start
+		}
+		for j, c := range p.comments {
+			if !p.used[j] && c.Offset >= it.span.end && c.Offset < next && c.Line == it.span.lastLine {
+				p.used[j] = true
+				if len(inner) == 0 {
+					text += " " + c.Literal //行尾注释
+				} else {
+					inner = append(inner, c)
+				}
+				break
+			}
+		}
+		emit(text, it.span.line, it.span.lastLine)
+		for _, c := range inner {
+			out.WriteString(p.indent(depth) + c.Literal + "\n")
+		}
+	}
+
+	for _, c := range p.take(from, to) {
+		emit(c.Literal, c.Line, c.Line)
+	}
+	return out.String()
+}
+
+// ----------------------------------------语句----------------------------------------
+
+func (p *printer) program(program *ast.Program) string {
+	return p.statements(program.Statements, 0, 0, len(p.src)+1, false)
+}
+
+// 一组语句，inBlock表示是块中的语句，块的最后一个表达式语句(块的值)不加分号
+func (p *printer) statements(list []ast.Statement, depth, from, to int, inBlock bool) string {
+	items := make([]item, len(list))
+	for i, s := range list {
+		s := s
+		items[i] = item{span: p.span(s), render: func(depth, col, tail int) string {
+			return p.statement(s, depth, col, tail)
+		}}
+	}
+	return p.lines(items, depth, from, to, func(i int) string {
+		es, ok := list[i].(*ast.ExpressionStatement)
+		if !ok {
+			return ";"
+		}
+		last := i == len(list)-1
+		if inBlock && last {
+			return ""
+		}
+		//以}结尾的if之后通常不需要分号，除非下一条语句会被解析为它的后续(调用、索引或减法)
+		if _, ok := es.Expression.(*ast.IfExpression); ok && (last || !continues(list[i+1])) {
+			return ""
+		}
+		return ";"
+	})
+}
+
+// 语句是否以(、[或-开头，紧跟在表达式之后时会被解析为同一个表达式
+func continues(s ast.Statement) bool {
+	es, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	switch es.Token.Type {
+	case token.LPAREN, token.LBRACKET, token.MINUS:
+		return true
+	}
+	return false
+}
+
+// 语句，不含分号
+func (p *printer) statement(s ast.Statement, depth, col, tail int) string {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		head := "let " + s.Name.Value + " = "
+		return head + p.expr(s.Value, depth, col+p.width(head), tail)
+	case *ast.ReturnStatement:
+		if s.ReturnValue == nil {
+			return "return"
+		}
+		return "return " + p.expr(s.ReturnValue, depth, col+len("return "), tail)
+	case *ast.ExpressionStatement:
+		return p.expr(s.Expression, depth, col, tail)
+	}
+	return s.String()
+}
+
+// 块，inline为true且源码中写在一行内的单语句块在行宽允许时保持一行
+func (p *printer) block(b *ast.BlockStatement, inline bool, depth, col, tail int) string {
+	closing := p.closing[b.Token.Offset]
+	if len(b.Statements) == 0 && !p.pending(b.Token.Offset, closing.Offset) {
+		return "{}"
+	}
+	if inline && len(b.Statements) == 1 && p.inlinable(b) {
+		if s, ok := p.inlineBlock(b, closing, depth, col, tail); ok {
+			return s
+		}
+	}
+	return "{\n" + p.statements(b.Statements, depth+1, b.Token.Offset, closing.Offset, true) + p.indent(depth) + "}"
+}
+
+// 块在源码中写在一行内，且为空或只含一个表达式或return语句
+func (p *printer) inlinable(b *ast.BlockStatement) bool {
+	if p.closing[b.Token.Offset].Line != b.Token.Line || len(b.Statements) > 1 {
+		return false
+	}
+	for _, s := range b.Statements {
+		switch s.(type) {
+		case *ast.ExpressionStatement, *ast.ReturnStatement:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// 写在一行内的块 { x }
+func (p *printer) inlineBlock(b *ast.BlockStatement, closing token.Token, depth, col, tail int) (string, bool) {
+	sep := ""
+	if _, ok := b.Statements[0].(*ast.ReturnStatement); ok {
+		sep = ";"
+	}
+
+	saved := p.save()
+	s := "{ " + p.statement(b.Statements[0], depth, col+2, len(sep)+2+tail) + sep + " }"
+	if strings.Contains(s, "\n") || p.pending(b.Token.Offset, closing.Offset) || !p.fits(s, col, tail) {
+		p.restore(saved)
+		return "", false
+	}
+	return s, true
+}
+
+// ----------------------------------------表达式----------------------------------------
+
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return precedences[e.Operator]
+	case *ast.PrefixExpression:
+		return prefix
+	case *ast.CallExpression, *ast.IndexExpression, *ast.SliceExpression, *ast.PropertyExpression:
+		return call
+	}
+	return primary
+}
+
+// 表达式，从col列开始，之后紧跟tail列的其他内容
+func (p *printer) expr(e ast.Expression, depth, col, tail int) string {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		return e.Value
+	case *ast.IntegerLiteral:
+		return e.Token.Literal
+	case *ast.FloatLiteral:
+		return e.Token.Literal
+	case *ast.Boolean:
+		return e.Token.Literal
+	case *ast.StringLiteral:
+		//保留源码中的写法，如转义方式
+		return p.src[e.Token.Offset:e.Token.End]
+
+	case *ast.PrefixExpression:
+		//-(-x)不写成--x
+		inner, double := e.Right.(*ast.PrefixExpression)
+		parens := precedence(e.Right) < prefix || double && e.Operator == "-" && inner.Operator == "-"
+		return e.Operator + p.operand(e.Right, parens, depth, col+len(e.Operator), tail)
+
+	case *ast.InfixExpression:
+		//左结合：右操作数优先级相同时也要加括号
+		prec := precedences[e.Operator]
+		left := p.operand(e.Left, precedence(e.Left) < prec, depth, col, 0)
+		op := " " + e.Operator + " "
+		right := p.operand(e.Right, precedence(e.Right) <= prec, depth, p.column(col, left)+len(op), tail)
+		return left + op + right
+
+	case *ast.IfExpression:
+		return p.ifExpression(e, depth, col, tail)
+
+	case *ast.FunctionLiteral:
+		head := "fn(" + params(e.Parameters) + ") "
+		return head + p.block(e.Body, true, depth, col+p.width(head), tail)
+	case *ast.MacroLiteral:
+		head := "macro(" + params(e.Parameters) + ") "
+		return head + p.block(e.Body, true, depth, col+p.width(head), tail)
+
+	case *ast.CallExpression:
+		function := p.operand(e.Function, precedence(e.Function) < call, depth, col, 0)
+		items := make([]item, len(e.Arguments))
+		for i, arg := range e.Arguments {
+			items[i] = p.exprItem(arg)
+		}
+		return function + p.list(e.Token, ")", items, depth, p.column(col, function), tail)
+
+	case *ast.ArrayLiteral:
+		items := make([]item, len(e.Elements))
+		for i, el := range e.Elements {
+			items[i] = p.exprItem(el)
+		}
+		return p.list(e.Token, "]", items, depth, col, tail)
+
+	case *ast.HashLiteral:
+		items := make([]item, len(e.Keys))
+		for i, key := range e.Keys {
+			key, value := key, e.Pairs[key]
+			sp := p.span(key)
+			if v := p.span(value); v.end > sp.end {
+				sp.end, sp.lastLine = v.end, v.lastLine
+			}
+			items[i] = item{span: sp, render: func(depth, col, tail int) string {
+				k := p.expr(key, depth, col, 1) + ": "
+				return k + p.expr(value, depth, p.column(col, k), tail)
+			}}
+		}
+		return p.list(e.Token, "}", items, depth, col, tail)
+
+	case *ast.IndexExpression:
+		left := p.operand(e.Left, precedence(e.Left) < call, depth, col, 0)
+		return left + "[" + p.expr(e.Index, depth, p.column(col, left)+1, tail+1) + "]"
+
+	case *ast.SliceExpression:
+		var out strings.Builder
+		out.WriteString(p.operand(e.Left, precedence(e.Left) < call, depth, col, 0))
+		out.WriteString("[")
+		for i, part := range []ast.Expression{e.Start, e.End, e.Step} {
+			if i == 2 && part == nil {
+				break
+			}
+			if i > 0 {
+				out.WriteString(":")
+			}
+			if part != nil {
+				out.WriteString(p.expr(part, depth, p.column(col, out.String()), 0))
+			}
+		}
+		out.WriteString("]")
+		return out.String()
+
+	case *ast.PropertyExpression:
+		return p.operand(e.Object, precedence(e.Object) < call, depth, col, 0) + "." + e.Property.Value
+	}
+	return e.String()
+}
+
+// 操作数，parens为true时加括号
+func (p *printer) operand(e ast.Expression, parens bool, depth, col, tail int) string {
+	if parens {
+		return "(" + p.expr(e, depth, col+1, tail+1) + ")"
+	}
+	return p.expr(e, depth, col, tail)
+}
+
+// if表达式，每个分支的块都能写在一行内时整个表达式写在一行，否则每个块都分行
+func (p *printer) ifExpression(e *ast.IfExpression, depth, col, tail int) string {
+	inline := p.inlinable(e.Consequence)
+	for _, alt := range e.Alternatives {
+		inline = inline && p.inlinable(alt.Consequence)
+	}
+	if e.LastAlternative != nil {
+		inline = inline && p.inlinable(e.LastAlternative)
+	}
+
+	if inline {
+		saved := p.save()
+		if s := p.branches(e, true, depth, col, tail); !strings.Contains(s, "\n") && p.fits(s, col, tail) {
+			return s
+		}
+		p.restore(saved)
+	}
+	return p.branches(e, false, depth, col, tail)
+}
+
+func (p *printer) branches(e *ast.IfExpression, inline bool, depth, col, tail int) string {
+	var out strings.Builder
+	branch := func(keyword string, condition ast.Expression, consequence *ast.BlockStatement, last bool) {
+		out.WriteString(keyword + " (")
+		out.WriteString(p.expr(condition, depth, p.column(col, out.String()), 3))
+		out.WriteString(") ")
+		blockTail := 0
+		if last {
+			blockTail = tail
+		}
+		out.WriteString(p.block(consequence, inline, depth, p.column(col, out.String()), blockTail))
+	}
+
+	branch("if", e.Condition, e.Consequence, e.LastAlternative == nil && len(e.Alternatives) == 0)
+	for i, alt := range e.Alternatives {
+		branch(" elif", alt.Condition, alt.Consequence, e.LastAlternative == nil && i == len(e.Alternatives)-1)
+	}
+	if e.LastAlternative != nil {
+		out.WriteString(" else ")
+		out.WriteString(p.block(e.LastAlternative, inline, depth, p.column(col, out.String()), tail))
+	}
+	return out.String()
+}
+
+func params(list []*ast.Identifier) string {
+	names := make([]string, len(list))
+	for i, p := range list {
+		names[i] = p.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+func (p *printer) exprItem(e ast.Expression) item {
+	return item{span: p.span(e), render: func(depth, col, tail int) string {
+		return p.expr(e, depth, col, tail)
+	}}
+}
+
+// 数组、哈希表或实参列表，放得下时写在一行内，否则每项一行
+func (p *printer) list(open token.Token, close string, items []item, depth, col, tail int) string {
+	closing := p.closing[open.Offset]
+
+	saved := p.save()
+	var out strings.Builder
+	multiline := false
+	out.WriteString(open.Literal)
+	for i, it := range items {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		itemTail := 1
+		if i == len(items)-1 {
+			itemTail = len(close) + tail
+		}
+		text := it.render(depth, p.column(col, out.String()), itemTail)
+		out.WriteString(text)
+		//只有最后一项(如函数字面量)可以跨行
+		if i < len(items)-1 && strings.Contains(text, "\n") {
+			multiline = true
+		}
+	}
+	out.WriteString(close)
+	if s := out.String(); !multiline && !p.pending(open.Offset, closing.Offset) && p.fits(s, col, tail) {
+		return s
+	}
+	p.restore(saved)
+
+	return open.Literal + "\n" +
+		p.lines(items, depth+1, open.Offset, closing.Offset, func(int) string { return "," }) +
+		p.indent(depth) + close
+}
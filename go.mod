@@ -1,3 +1,7 @@
 module monkey_Interpreter
 
 go 1.20
+
+require golang.org/x/term v0.25.0
+
+require golang.org/x/sys v0.26.0 // indirect
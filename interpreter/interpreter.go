@@ -0,0 +1,164 @@
+package interpreter
+
+//嵌入用的解释器 可选择树遍历求值器或字节码虚拟机执行代码，两者结果一致
+//同一个解释器多次执行的代码共享全局环境，宿主程序通过Env读取或设置变量
+//执行前先定义并展开宏，之前执行的代码中定义的宏同样可用
+
+import (
+	"fmt"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/compiler"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/parser"
+	"monkey_Interpreter/vm"
+	"strings"
+)
+
+// 执行方式
+type Backend int
+
+const (
+	TreeWalking Backend = iota //evaluator.Eval
+	Bytecode                   //编译为字节码后由虚拟机执行
+)
+
+var backendNames = map[Backend]string{
+	TreeWalking: "eval",
+	Bytecode:    "vm",
+}
+
+func (b Backend) String() string {
+	if name, ok := backendNames[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("Backend(%d)", int(b))
+}
+
+// 按名称(eval或vm)选择执行方式
+func ParseBackend(name string) (Backend, error) {
+	for b, n := range backendNames {
+		if n == name {
+			return b, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown backend %q (want eval or vm)", name)
+}
+
+type Interpreter struct {
+	backend Backend
+	env     *object.Environment
+	macros  *object.Environment //已定义的宏
+
+	//字节码后端在多次执行之间保留的状态
+	symbols   *compiler.SymbolTable
+	constants []object.Object
+	machine   *vm.VM
+}
+
+func New(backend Backend) *Interpreter {
+	return &Interpreter{
+		backend: backend,
+		env:     object.NewEnvironment(),
+		macros:  object.NewEnvironment(),
+		symbols: compiler.NewSymbolTable(),
+	}
+}
+
+func (in *Interpreter) Backend() Backend {
+	return in.backend
+}
+
+// 全局环境 字节码后端在每次执行前后与虚拟机的全局变量同步
+func (in *Interpreter) Env() *object.Environment {
+	return in.env
+}
+
+// 执行程序，返回最后一条语句的值，运行时错误以*object.Error返回
+func (in *Interpreter) Run(program *ast.Program) object.Object {
+	evaluator.DefineMacros(program, in.macros)
+	program, err := evaluator.ExpandMacros(program, in.macros)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	if in.backend == TreeWalking {
+		return evaluator.Eval(program, in.env)
+	}
+
+	names := in.defineHostNames()
+	c := compiler.NewWithState(in.symbols, in.constants)
+	err = c.Compile(program)
+	bytecode := c.Bytecode()
+	in.constants = bytecode.Constants
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return in.execute(bytecode, names)
+}
+
+// 执行预先编译好的字节码(如从.mkc文件加载)，只能用于尚未执行过代码的字节码后端
+func (in *Interpreter) RunBytecode(bytecode *compiler.Bytecode) object.Object {
+	if in.backend != Bytecode {
+		return &object.Error{Message: "compiled bytecode requires the vm backend"}
+	}
+	if in.machine != nil || in.symbols.NumDefinitions() > 0 {
+		return &object.Error{Message: "compiled bytecode must run in a fresh interpreter"}
+	}
+
+	for _, name := range bytecode.Globals {
+		in.symbols.Define(name)
+	}
+	in.constants = bytecode.Constants
+	names := in.defineHostNames()
+	return in.execute(&compiler.Bytecode{
+		Instructions: bytecode.Instructions,
+		Constants:    bytecode.Constants,
+		Globals:      in.symbols.Names(),
+		Lines:        bytecode.Lines,
+	}, names)
+}
+
+// 宿主程序设置的变量作为全局变量
+func (in *Interpreter) defineHostNames() []string {
+	names := in.env.Names()
+	for _, name := range names {
+		in.symbols.Define(name)
+	}
+	return names
+}
+
+// 在虚拟机中执行，执行前后与环境同步全局变量
+func (in *Interpreter) execute(bytecode *compiler.Bytecode, names []string) object.Object {
+	if in.machine == nil {
+		in.machine = vm.New(bytecode)
+	} else {
+		in.machine.Load(bytecode)
+	}
+	globals := in.machine.Globals()
+	for _, name := range names {
+		symbol, _ := in.symbols.Resolve(name)
+		globals[symbol.Index], _ = in.env.Get(name)
+	}
+
+	in.machine.SetStrictIndex(in.env.StrictIndex())
+	in.machine.Run()
+
+	for i, name := range bytecode.Globals {
+		if globals[i] != nil {
+			in.env.Set(name, globals[i])
+		}
+	}
+	return in.machine.Result()
+}
+
+// 解析并执行源代码，语法错误以error返回
+func (in *Interpreter) RunString(source string) (object.Object, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parser errors:\n\t%s", strings.Join(p.Errors(), "\n\t"))
+	}
+	return in.Run(program), nil
+}
@@ -0,0 +1,337 @@
+package interpreter
+
+import (
+	"bytes"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/compiler"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/parser"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var backends = []Backend{TreeWalking, Bytecode}
+
+// 两种后端对同一段代码的结果必须相同
+func TestBackendsEquivalent(t *testing.T) {
+	inputs := []string{
+		//运算
+		"1 + 2 * 3 - 4 / 2",
+		"-(5) + 10",
+		"1.5 * 2",
+		"1 == 1.0",
+		`"a" + "b"`,
+		`"a" < "b"`,
+		"[1, 2] == [1, 2]",
+		"!5",
+		"!!null_value_missing",
+		"true && 0",
+		"false || 7",
+		"1 && 2",
+		"1 / 0",
+		"1 + true",
+		"-true",
+		`"a" - "b"`,
+
+		//条件
+		"if (1 > 2) { 1 } elif (2 > 3) { 2 } elif (3 > 2) { 3 } else { 4 }",
+		"if (false) { 1 }",
+		"if (false) { 1 } elif (true) { } else { 3 }",
+		"if (true) { let z = 1; }",
+		"if (null_value) { 1 }",
+
+		//变量与函数
+		"let a = 5; let b = a * 2; b",
+		"let a = 5;",
+		"let a = 1; let a = a + 1; a",
+		"let f = fn(x, y) { x * y }; f(3, 4)",
+		"let f = fn() { return 1; 2 }; f()",
+		"let f = fn() { if (true) { return 1; } 2 }; f()",
+		"return 5; 6",
+		"let f = fn(x) { x }; f(1, 2)",
+		"let f = fn() { }; f()",
+		"3(1)",
+		"fn(a, b) { a + b }",
+		"let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(10)",
+		"let adder = fn(x) { fn(y) { x + y } }; let add2 = adder(2); add2(3)",
+		"let counter = fn() { let n = 0; let get = fn() { n }; let n = 10; get }; counter()()",
+		"let f = fn() { g() }; let g = fn() { 42 }; f()",
+		"let x = 1; let f = fn() { let y = x; let x = 2; [y, x] }; f()",
+		"let f = fn() { if (false) { let v = 1; } v }; f()",
+		"let f = fn() { missing }; f()",
+		"let outer = fn(a) { fn(b) { fn(c) { a + b + c } } }; outer(1)(2)(3)",
+
+		//内置函数与模块
+		`len("héllo")`,
+		"first([])",
+		"push([1], 2)",
+		"map([1, 2, 3], fn(x) { x * x })",
+		"filter([1, 2, 3, 4], fn(x) { x > 2 })",
+		"reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)",
+		"sort([3, 1, 2], fn(a, b) { b < a })",
+		"map([1], fn(x) { x + missing })",
+		"let len = fn(x) { 0 }; len([1])",
+		"math.pow(2, 10)",
+		"math.floor(2.5)",
+		`split("a,b", ",")`,
+		`json.parse("[1, {\"a\": null}]")`,
+
+		//数组、哈希表
+		"[1, 2, 3][0]",
+		"[1, 2, 3][5]",
+		"[1, 2, 3, 4, 5][1:4:2]",
+		`"hello"[::-1]`,
+		`[1, 2]["a"]`,
+		`{"a": 1, "b": [2, 3]}`,
+		`{"a": 1}["b"]`,
+		`{"a": {"b": 2}}.a.b`,
+		`{1: "x", 1.0: "y"}`,
+		`{[1, 2]: 3}[[1, 2]]`,
+		"{fn() {}: 1}",
+		`5.a`,
+		`[1][1:"a"]`,
+	}
+
+	for _, input := range inputs {
+		var results []string
+		for _, b := range backends {
+			result, err := New(b).RunString(input)
+			if err != nil {
+				t.Fatalf("%s: %s", input, err)
+			}
+			results = append(results, describe(result))
+		}
+		if results[0] != results[1] {
+			t.Errorf("%s: backends disagree.\neval=%s\nvm=%s", input, results[0], results[1])
+		}
+	}
+}
+
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return string(obj.Type()) + " " + obj.Inspect()
+}
+
+// 输出的顺序与次数相同
+func TestBackendsSideEffects(t *testing.T) {
+	inputs := []string{
+		`put(1); put(2, 3); put("a" + "b")`,
+		`let f = fn(x) { put(x); x }; f(1) < f(2); [f(3), f(4)]; {f(5): f(6)}`,
+		`let f = fn(x) { put(x); x }; f(1) && f(false) || f(2)`,
+		`put(1); 1 + true; put(2)`,
+		`let f = fn() { put("in"); return 1; put("after") }; f()`,
+		`each([1, 2], fn(x) { put(x * 10) })`,
+	}
+
+	for _, input := range inputs {
+		var outputs []string
+		for _, b := range backends {
+			var out bytes.Buffer
+			in := New(b)
+			evaluator.SetOutput(in.Env(), &out)
+			if _, err := in.RunString(input); err != nil {
+				t.Fatalf("%s: %s", input, err)
+			}
+			outputs = append(outputs, out.String())
+		}
+		if outputs[0] != outputs[1] {
+			t.Errorf("%s: outputs differ.\neval=%q\nvm=%q", input, outputs[0], outputs[1])
+		}
+	}
+}
+
+// 多次执行共享全局变量，宿主程序通过Env读写
+func TestInterpreterEnv(t *testing.T) {
+	for _, b := range backends {
+		in := New(b)
+		in.Env().Set("host", &object.Integer{Value: 40})
+
+		steps := []struct {
+			input    string
+			expected string
+		}{
+			{"let twice = fn(x) { x * 2 };", "<nil>"},
+			{"let f = fn() { later };", "<nil>"},
+			{"twice(host) + 2", "INTEGER 82"},
+			{"let later = 7; f()", "INTEGER 7"},
+			{"map([1], twice)", "ARRAY [2]"},
+		}
+		for _, step := range steps {
+			result, err := in.RunString(step.input)
+			if err != nil {
+				t.Fatalf("%s: %s", b, err)
+			}
+			if got := describe(result); got != step.expected {
+				t.Errorf("%s: %s: got=%s, want=%s", b, step.input, got, step.expected)
+			}
+		}
+
+		if later, ok := in.Env().Get("later"); !ok || later.Inspect() != "7" {
+			t.Errorf("%s: later not visible in Env. got=%v", b, later)
+		}
+		in.Env().Set("host", &object.Integer{Value: 1})
+		if result, _ := in.RunString("host"); describe(result) != "INTEGER 1" {
+			t.Errorf("%s: host change not visible. got=%s", b, describe(result))
+		}
+	}
+}
+
+// 输出目标和索引越界的处理方式属于各自的解释器，可以同时执行
+func TestInterpreterSettings(t *testing.T) {
+	input := `let f = fn(a) { put(a[0]); a[5] }; f([1])`
+	var wg sync.WaitGroup
+	for _, b := range backends {
+		for _, strict := range []bool{false, true} {
+			b, strict := b, strict
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var out bytes.Buffer
+				in := New(b)
+				evaluator.SetOutput(in.Env(), &out)
+				in.Env().SetStrictIndex(strict)
+				expected := "NULL null"
+				if strict {
+					expected = "ERROR ERROR: index out of range: 5 (length 1)"
+				}
+				for i := 0; i < 100; i++ {
+					result, _ := in.RunString(input)
+					if got := describe(result); got != expected {
+						t.Errorf("%s strict=%t: got=%s, want=%s", b, strict, got, expected)
+						return
+					}
+				}
+				if out.String() != strings.Repeat("1\n", 100) {
+					t.Errorf("%s strict=%t: wrong output %q", b, strict, out.String())
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// 一个后端创建的函数可以交给另一个后端的内置函数回调
+func TestCrossBackendCallback(t *testing.T) {
+	vmInterp := New(Bytecode)
+	fn, _ := vmInterp.RunString("let k = 3; fn(x) { x * k }")
+
+	evalInterp := New(TreeWalking)
+	evalInterp.Env().Set("f", fn)
+	result, _ := evalInterp.RunString("map([1, 2], f)")
+	if got := describe(result); got != "ARRAY [3,6]" {
+		t.Errorf("wrong result. got=%s", got)
+	}
+}
+
+// 从.mkc文件加载的字节码与直接执行源代码结果相同
+func TestRunBytecode(t *testing.T) {
+	const input = `let adder = fn(n) { fn(x) { x + n } };
+let c = adder(2);
+put(host, c(40), map([1, 2], c))`
+
+	p := parser.New(lexer.New(input))
+	c := compiler.New()
+	if err := c.Compile(p.ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	var buf bytes.Buffer
+	c.Bytecode().WriteTo(&buf)
+	bytecode, err := compiler.ReadBytecode(&buf)
+	if err != nil {
+		t.Fatalf("ReadBytecode failed: %s", err)
+	}
+
+	var outputs []string
+	for _, loaded := range []bool{false, true} {
+		var out bytes.Buffer
+		in := New(Bytecode)
+		evaluator.SetOutput(in.Env(), &out)
+		in.Env().Set("host", &object.String{Value: "h"})
+		if loaded {
+			in.RunBytecode(bytecode)
+		} else {
+			in.RunString(input)
+		}
+		if n, ok := in.Env().Get("c"); !ok || n.Type() != object.FUNCTION_OBJ {
+			t.Errorf("globals not synced to Env. got=%v", n)
+		}
+		outputs = append(outputs, out.String())
+	}
+	if outputs[0] != "h\n42\n[3,4]\n" || outputs[1] != outputs[0] {
+		t.Errorf("outputs differ. source=%q, bytecode=%q", outputs[0], outputs[1])
+	}
+
+	if got := describe(New(TreeWalking).RunBytecode(bytecode)); got != "ERROR ERROR: compiled bytecode requires the vm backend" {
+		t.Errorf("wrong error for eval backend. got=%s", got)
+	}
+	used := New(Bytecode)
+	used.RunString("1")
+	if got := describe(used.RunBytecode(bytecode)); got != "ERROR ERROR: compiled bytecode must run in a fresh interpreter" {
+		t.Errorf("wrong error for used interpreter. got=%s", got)
+	}
+}
+
+// 宏在执行前展开，两种后端结果相同，之前定义的宏在之后执行的代码中可用
+func TestMacros(t *testing.T) {
+	for _, b := range backends {
+		in := New(b)
+		steps := []struct {
+			input    string
+			expected string
+		}{
+			{"let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };", "<nil>"},
+			{"unless(10 > 5, 1, 2)", "INTEGER 2"},
+			{"let tmp = 10; let with = macro(e) { quote(fn(tmp) { tmp * unquote(e) }(2)) }; with(tmp)", "INTEGER 20"},
+			{"unless(false, with(3), 0)", "INTEGER 6"},
+			{"unless(1)", "ERROR ERROR: macro unless: wrong number of arguments: want=3, got=1"},
+		}
+		for _, step := range steps {
+			result, err := in.RunString(step.input)
+			if err != nil {
+				t.Fatalf("%s: %s", b, err)
+			}
+			if got := describe(result); got != step.expected {
+				t.Errorf("%s: %s: got=%s, want=%s", b, step.input, got, step.expected)
+			}
+		}
+	}
+
+	if got := describe(New(TreeWalking).Run(parse("quote(1 + 2)"))); got != "QUOTE QUOTE((1 + 2))" {
+		t.Errorf("wrong quote result. got=%s", got)
+	}
+	if got := describe(New(Bytecode).Run(parse("quote(1 + 2)"))); got != "ERROR ERROR: quote outside of a macro is only supported by the eval backend" {
+		t.Errorf("wrong error for quote on the vm. got=%s", got)
+	}
+}
+
+func parse(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
+
+func TestParseBackend(t *testing.T) {
+	for _, b := range backends {
+		parsed, err := ParseBackend(b.String())
+		if err != nil || parsed != b {
+			t.Errorf("ParseBackend(%q) = %v, %v", b.String(), parsed, err)
+		}
+	}
+	if _, err := ParseBackend("jit"); err == nil {
+		t.Errorf("expected an error for unknown backend")
+	}
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	const input = "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(20)"
+	for _, backend := range backends {
+		b.Run(backend.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				New(backend).RunString(input)
+			}
+		})
+	}
+}
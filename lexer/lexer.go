@@ -4,7 +4,9 @@ package lexer
 	词法分析器
 */
 import (
+	"bytes"
 	"monkey_Interpreter/token"
+	"strings"
 )
 
 type Lexer struct {
@@ -12,10 +14,12 @@ type Lexer struct {
 	position     int    // 输入的字符串中的当前位置 (指向当前字符)
 	readPosition int    // 输入的字符串中的当前读取位置 (指向当前字符之后的一个字符(ch))
 	ch           byte   // 当前正在查看的字符
+	line         int    // 当前字符所在行
+	comments     []token.Token
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	// 初始化 l.ch,l.position,l.readPosition
 	l.readChar()
 	return l
@@ -24,6 +28,9 @@ func New(input string) *Lexer {
 // 读取input的下一个字符，并前移其在input中的位置
 // 检查是否到到input的结尾
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+	}
 	if l.readPosition >= len(l.input) { //下一个要读取的字符位置大于整个输入字符串的长度，已经读到输入的末尾
 		l.ch = 0 // NUL的ASSII码(0)，表示尚未读取任何内容或文件结尾
 	} else {
@@ -43,12 +50,29 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 	}
 }
 
-// 根据当前的ch创建词法单元，匹配对应的Type和字面量Literal
+// 读取下一个词法单元，并记录它在输入中的起止位置
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace() //跳过空格和一些
 
+	start, line := l.clampPosition(l.position), l.line
+	tok := l.readToken()
+	tok.Offset, tok.End = start, l.clampPosition(l.position)
+	tok.Line = line
+	return tok
+}
+
+// 读到输入末尾后position会越过输入长度
+func (l *Lexer) clampPosition(position int) int {
+	if position > len(l.input) {
+		return len(l.input)
+	}
+	return position
+}
+
+// 根据当前的ch创建词法单元，匹配对应的Type和字面量Literal
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' { //特殊处理 等于==
@@ -131,6 +155,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 
 	//检查是否是标识符
 	default:
@@ -139,8 +165,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal) //检查关键字
 			return tok
 		} else if isDigit(l.ch) { //检查是否是数字
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -155,7 +180,7 @@ func (l *Lexer) NextToken() token.Token {
 // 读一个标识符并前移词法分析器的位置，直到遇见非字母
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) { //首字符为字母，其后可以包含数字，如log10
 		l.readChar() //读下一个字符 不断后移l.positionS
 	}
 	return l.input[position:l.position] //截取输出字符串 完整的标识符ident
@@ -167,21 +192,57 @@ func isLetter(ch byte) bool {
 }
 
 // ***********************************空白******************************************
-// 跳过空白
+// 跳过空白和注释
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+			l.readChar()
+		}
+		if l.ch != '/' || l.peekChar() != '/' {
+			return
+		}
+		l.skipComment()
+	}
+}
+
+// 跳过//开始的行注释，记录下来供格式化使用
+func (l *Lexer) skipComment() {
+	start, line := l.position, l.line
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	end := l.clampPosition(l.position)
+	l.comments = append(l.comments, token.Token{
+		Type:    token.COMMENT,
+		Literal: strings.TrimRight(l.input[start:end], " \t\r"),
+		Offset:  start,
+		End:     end,
+		Line:    line,
+	})
+}
+
+// 已经读过的注释，按出现顺序
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
 }
 
 // *******************************************数字************************************
-// 读一个标识符并前移词法分析器的位置，知道遇见非数字
-func (l *Lexer) readNumber() string {
+// 读一个数字并前移词法分析器的位置，知道遇见非数字
+// 小数点后紧跟数字时读作浮点数，如3.14
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	var tokenType token.TokenType = token.INT
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+	return l.input[position:l.position], tokenType
 }
 
 // 判断是否是数字
@@ -198,9 +259,9 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
-// 读字符串
+// 读字符串 支持转义\" \\ \n \t \r，其他反斜杠原样保留（如正则中的\d）
 func (l *Lexer) readString() string {
-	position := l.position + 1
+	var out bytes.Buffer
 
 	for {
 		l.readChar()
@@ -208,6 +269,24 @@ func (l *Lexer) readString() string {
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
+
+		if l.ch == '\\' {
+			if escaped, ok := escapes[l.peekChar()]; ok {
+				l.readChar()
+				out.WriteByte(escaped)
+				continue
+			}
+		}
+		out.WriteByte(l.ch)
 	}
-	return l.input[position:l.position]
+	return out.String()
+}
+
+// 转义字符表
+var escapes = map[byte]byte{
+	'"':  '"',
+	'\\': '\\',
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
 }
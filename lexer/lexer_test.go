@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"monkey_Interpreter/token"
+	"strings"
 	"testing"
 )
 
@@ -30,6 +31,9 @@ if (5 < 10) {
 ||
 [1,2]
 {"foo":"bar"}
+obj.name
+"say \"hi\"\n\d"
+3.14 1.x log10
 `
 
 	tests := []struct {
@@ -128,6 +132,15 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "obj"},
+		{token.DOT, "."},
+		{token.IDENT, "name"},
+		{token.STRING, "say \"hi\"\n\\d"},
+		{token.FLOAT, "3.14"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.IDENT, "log10"},
 		{token.EOF, ""},
 	}
 
@@ -152,3 +165,102 @@ if (5 < 10) {
 	}
 
 }
+
+func TestTokenOffsets(t *testing.T) {
+	input := `let s = "a\"b" == 1.5;`
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedText string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "s"},
+		{token.ASSIGN, "="},
+		{token.STRING, `"a\"b"`},
+		{token.EQ, "=="},
+		{token.FLOAT, "1.5"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if text := input[tok.Offset:tok.End]; text != tt.expectedText {
+			t.Fatalf("tests[%d] - source text wrong. expected=%q, got=%q", i, tt.expectedText, text)
+		}
+	}
+}
+
+func TestTokenLines(t *testing.T) {
+	input := "let a = 1;\n\nlet s = \"x\ny\";\n  a"
+
+	expected := []int{1, 1, 1, 1, 1, 3, 3, 3, 3, 4, 5, 5}
+
+	l := New(input)
+	for i, line := range expected {
+		tok := l.NextToken()
+		if tok.Line != line {
+			t.Fatalf("tests[%d] - line of %q wrong. expected=%d, got=%d", i, tok.Literal, line, tok.Line)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// 开头\nlet a = 1; // 行尾  \r\na / 2 //\n\"//不是注释\""
+
+	expected := []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.SLASH, token.INT, token.STRING, token.EOF}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+
+	comments := l.Comments()
+	want := []struct {
+		literal string
+		line    int
+	}{{"// 开头", 1}, {"// 行尾", 2}, {"//", 3}}
+	if len(comments) != len(want) {
+		t.Fatalf("wrong number of comments. got=%v", comments)
+	}
+	for i, c := range comments {
+		if c.Type != token.COMMENT || c.Literal != want[i].literal || c.Line != want[i].line {
+			t.Errorf("comments[%d] wrong. got=%+v", i, c)
+		}
+		if !strings.HasPrefix(input[c.Offset:c.End], c.Literal) {
+			t.Errorf("comments[%d] offsets wrong. got=%q", i, input[c.Offset:c.End])
+		}
+	}
+}
+
+// 标识符以字母开头，其后可以包含数字
+func TestIdentifierWithDigits(t *testing.T) {
+	input := "log10 x2y 2x"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "log10"},
+		{token.IDENT, "x2y"},
+		{token.INT, "2"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
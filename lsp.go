@@ -0,0 +1,40 @@
+package main
+
+//monkey lsp 在标准输入输出上运行语言服务器
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey_Interpreter/lsp"
+)
+
+const lspUsage = `usage: monkey lsp [-stdio]
+
+Runs a Language Server Protocol server on standard input and output.
+
+flags:
+`
+
+// monkey lsp子命令，返回退出状态码
+func runLSP(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, lspUsage)
+		flags.PrintDefaults()
+	}
+	flags.Bool("stdio", true, "communicate over standard input and output (the only transport, accepted for editor clients that pass it)")
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintln(stderr, "monkey lsp: unexpected arguments")
+		return exitUsage
+	}
+	if err := lsp.Serve(stdin, stdout); err != nil {
+		fmt.Fprintf(stderr, "monkey lsp: %s\n", err)
+		return exitError
+	}
+	return exitOK
+}
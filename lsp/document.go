@@ -0,0 +1,174 @@
+package lsp
+
+//打开的文档 每次修改后重新分析，位置在字节偏移和LSP的行列之间转换
+
+import (
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/token"
+	"monkey_Interpreter/vet"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type document struct {
+	uri     string
+	version int
+	text    string
+	lines   []int         //每行开头的字节偏移
+	tokens  []token.Token //词法单元和注释，按位置排序，有语法错误时也可用
+	diags   []vet.Diagnostic
+
+	//以下来自语法树，有语法错误时来自不完整的语法树，只包含解析出来的部分
+	//遇到无法识别的字符时program为nil
+	program *ast.Program
+	loc     *vet.Locator
+	refs    map[*ast.Identifier]*ast.Identifier //标识符 -> 声明
+	idents  []*ast.Identifier                   //所有标识符，包括属性名，按位置排序
+	props   map[*ast.Identifier]*ast.PropertyExpression
+	lets    map[*ast.Identifier]*ast.LetStatement //let的名称 -> let语句
+	params  map[*ast.Identifier]bool
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+
+	//#!行换成空格，偏移不变
+	code := text
+	if strings.HasPrefix(code, "#!") {
+		n := strings.IndexByte(code, '\n')
+		if n < 0 {
+			n = len(code)
+		}
+		code = strings.Repeat(" ", n) + code[n:]
+	}
+	l := lexer.New(code)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		d.tokens = append(d.tokens, tok)
+	}
+	d.tokens = append(d.tokens, l.Comments()...)
+	sort.SliceStable(d.tokens, func(i, j int) bool { return d.tokens[i].Offset < d.tokens[j].Offset })
+
+	//有语法错误时只发布syntax诊断，其他检查在不完整的语法树上会误报
+	//但仍然在解析出来的部分中查找定义和引用
+	program, diags := vet.Parse([]byte(text))
+	if program == nil {
+		d.diags = diags
+		return d
+	}
+	if len(diags) != 0 {
+		d.diags = diags
+	} else {
+		d.diags = vet.Check(program, []byte(text))
+	}
+	d.program = program
+	d.loc = vet.NewLocator([]byte(text))
+	d.refs = vet.Resolve(program, []byte(text))
+	d.props = map[*ast.Identifier]*ast.PropertyExpression{}
+	d.lets = map[*ast.Identifier]*ast.LetStatement{}
+	d.params = map[*ast.Identifier]bool{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			d.idents = append(d.idents, node)
+		case *ast.PropertyExpression:
+			d.props[node.Property] = node
+		case *ast.LetStatement:
+			d.lets[node.Name] = node
+		case *ast.FunctionLiteral:
+			for _, p := range node.Parameters {
+				d.params[p] = true
+			}
+		case *ast.MacroLiteral:
+			for _, p := range node.Parameters {
+				d.params[p] = true
+			}
+		}
+		return true
+	})
+	sort.SliceStable(d.idents, func(i, j int) bool { return d.idents[i].Token.Offset < d.idents[j].Token.Offset })
+	return d
+}
+
+// 字节偏移对应的位置
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.SearchInts(d.lines, offset+1) - 1
+	return Position{Line: line, Character: utf16Len(d.text[d.lines[line]:offset])}
+}
+
+// 位置对应的字节偏移，超出行尾时为行尾
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	i := d.lines[pos.Line]
+	for n := 0; n < pos.Character && i < len(d.text) && d.text[i] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[i:])
+		n += utf16Len(string(r))
+		i += size
+	}
+	return i
+}
+
+func (d *document) rangeOf(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+func (d *document) tokenRange(tok token.Token) Range {
+	return d.rangeOf(tok.Offset, tok.End)
+}
+
+// 字符串的UTF-16编码单元数
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// 位置处的标识符，光标在标识符末尾时也算
+func (d *document) identAt(offset int) *ast.Identifier {
+	i := sort.Search(len(d.idents), func(i int) bool { return d.idents[i].Token.End >= offset })
+	if i < len(d.idents) && d.idents[i].Token.Offset <= offset {
+		return d.idents[i]
+	}
+	return nil
+}
+
+// 位置处的词法单元的下标，没有时返回-1
+func (d *document) tokenAt(offset int) int {
+	i := sort.Search(len(d.tokens), func(i int) bool { return d.tokens[i].End >= offset })
+	if i < len(d.tokens) && d.tokens[i].Offset <= offset {
+		return i
+	}
+	return -1
+}
+
+// 修改一段文本，返回新的文本
+func (d *document) apply(change TextDocumentContentChangeEvent) string {
+	if change.Range == nil {
+		return change.Text
+	}
+	start, end := d.offset(change.Range.Start), d.offset(change.Range.End)
+	if end < start {
+		end = start
+	}
+	return d.text[:start] + change.Text + d.text[end:]
+}
@@ -0,0 +1,429 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/format"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/token"
+	"sort"
+	"strings"
+)
+
+var keywords = []string{"let", "fn", "if", "elif", "else", "return", "macro", "true", "false"}
+
+// -------------------------------------------悬停提示-----------------------------------------
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	i := d.tokenAt(d.offset(p.Position))
+	if i < 0 || d.tokens[i].Type != token.IDENT {
+		return nil, nil
+	}
+	text := d.describe(i)
+	if text == "" {
+		return nil, nil
+	}
+	r := d.tokenRange(d.tokens[i])
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}, nil
+}
+
+// 第i个词法单元(标识符)的说明
+func (d *document) describe(i int) string {
+	tok := d.tokens[i]
+	var ident *ast.Identifier
+	if d.program != nil {
+		ident = d.identAt(tok.Offset)
+	}
+	if ident == nil {
+		//不在语法树中(有语法错误)时只根据词法单元查找内置函数
+		if i >= 2 && d.tokens[i-1].Type == token.DOT && d.tokens[i-2].Type == token.IDENT {
+			return builtinDoc(d.tokens[i-2].Literal + "." + tok.Literal)
+		}
+		if i >= 1 && d.tokens[i-1].Type == token.DOT {
+			return ""
+		}
+		return builtinDoc(tok.Literal)
+	}
+	if decl, ok := d.refs[ident]; ok {
+		return "```monkey\n" + d.declaration(decl) + "\n```"
+	}
+	if prop, ok := d.props[ident]; ok {
+		if module, ok := prop.Object.(*ast.Identifier); ok && d.refs[module] == nil {
+			return builtinDoc(module.Value + "." + ident.Value)
+		}
+		return ""
+	}
+	return builtinDoc(ident.Value)
+}
+
+// 声明的简短形式，如let add = fn(a, b)
+func (d *document) declaration(decl *ast.Identifier) string {
+	if d.params[decl] {
+		return "(parameter) " + decl.Value
+	}
+	let, ok := d.lets[decl]
+	if !ok {
+		return decl.Value
+	}
+	switch value := let.Value.(type) {
+	case *ast.FunctionLiteral:
+		return fmt.Sprintf("let %s = fn(%s)", decl.Value, paramList(value.Parameters))
+	case *ast.MacroLiteral:
+		return fmt.Sprintf("let %s = macro(%s)", decl.Value, paramList(value.Parameters))
+	}
+	return "let " + decl.Value
+}
+
+func paramList(params []*ast.Identifier) string {
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+// 内置函数、模块或模块成员的说明，name不是内置名称时返回空字符串
+func builtinDoc(name string) string {
+	if sig, ok := evaluator.LookupSignature(name); ok {
+		return "```monkey\n" + sig.String() + "\n```\n\n" + sig.Doc
+	}
+	if value, ok := moduleMember(name); ok {
+		return "```monkey\n" + name + " = " + value.Inspect() + "\n```"
+	}
+	if obj, ok := evaluator.LookupBuiltin(name); ok {
+		if _, isModule := obj.(*object.Hash); isModule {
+			var members []string
+			for _, member := range moduleMembers(name) {
+				members = append(members, "`"+member+"`")
+			}
+			return "```monkey\nmodule " + name + "\n```\n\nMembers: " + strings.Join(members, ", ")
+		}
+	}
+	return ""
+}
+
+// 模块成员的值，如math.pi
+func moduleMember(name string) (object.Object, bool) {
+	module, member, ok := strings.Cut(name, ".")
+	if !ok {
+		return nil, false
+	}
+	obj, ok := evaluator.LookupBuiltin(module)
+	hash, isModule := obj.(*object.Hash)
+	if !ok || !isModule {
+		return nil, false
+	}
+	return hash.Get(&object.String{Value: member})
+}
+
+// 模块的成员名，按字母排序
+func moduleMembers(module string) []string {
+	var members []string
+	for _, name := range evaluator.BuiltinNames() {
+		if member, ok := strings.CutPrefix(name, module+"."); ok {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+// -------------------------------------------定义和引用-----------------------------------------
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	decl := d.declAt(d.offset(p.Position))
+	if decl == nil {
+		return nil, nil
+	}
+	return Location{URI: d.uri, Range: d.tokenRange(decl.Token)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	decl := d.declAt(d.offset(p.Position))
+	if decl == nil {
+		return nil, nil
+	}
+	locations := []Location{}
+	for _, ident := range d.idents {
+		if d.refs[ident] != decl || ident == decl && !p.Context.IncludeDeclaration {
+			continue
+		}
+		locations = append(locations, Location{URI: d.uri, Range: d.tokenRange(ident.Token)})
+	}
+	return locations, nil
+}
+
+// 位置处的标识符引用的声明，不在语法树中或不是let和形参时返回nil
+func (d *document) declAt(offset int) *ast.Identifier {
+	if d.program == nil {
+		return nil
+	}
+	if ident := d.identAt(offset); ident != nil {
+		return d.refs[ident]
+	}
+	return nil
+}
+
+// -------------------------------------------补全-----------------------------------------
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	offset := d.offset(p.Position)
+	start := offset
+	for start > 0 && isIdentChar(d.text[start-1]) {
+		start--
+	}
+	prefix := d.text[start:offset]
+
+	items := []CompletionItem{}
+	add := func(item CompletionItem) {
+		if strings.HasPrefix(item.Label, prefix) {
+			items = append(items, item)
+		}
+	}
+
+	//模块成员，如math.之后
+	if start > 0 && d.text[start-1] == '.' {
+		end := start - 1
+		begin := end
+		for begin > 0 && isIdentChar(d.text[begin-1]) {
+			begin--
+		}
+		module := d.text[begin:end]
+		for _, member := range moduleMembers(module) {
+			add(builtinItem(module+"."+member, member))
+		}
+		return items, nil
+	}
+
+	for _, item := range d.visibleNames(offset) {
+		add(item)
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		if !strings.Contains(name, ".") {
+			add(builtinItem(name, name))
+		}
+	}
+	for _, kw := range keywords {
+		add(CompletionItem{Label: kw, Kind: CompletionKeyword})
+	}
+	return items, nil
+}
+
+func isIdentChar(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || '0' <= ch && ch <= '9'
+}
+
+// 内置名称的补全项，name为完整名称，label为补全的文本
+func builtinItem(name, label string) CompletionItem {
+	item := CompletionItem{Label: label, Kind: CompletionConstant}
+	if sig, ok := evaluator.LookupSignature(name); ok {
+		item.Kind = CompletionFunction
+		item.Detail = sig.String()
+		item.Documentation = &MarkupContent{Kind: "markdown", Value: sig.Doc}
+	} else if value, ok := moduleMember(name); ok {
+		item.Detail = name + " = " + value.Inspect()
+	} else {
+		item.Kind = CompletionModule
+		item.Detail = "module " + name
+	}
+	return item
+}
+
+// 光标处可见的let和形参
+// 只用词法单元判断作用域，输入到一半、有语法错误时也可用
+func (d *document) visibleNames(offset int) []CompletionItem {
+	//函数体的范围，没有闭合的函数体到文件末尾
+	type scope struct{ start, end int }
+	closing := map[int]int{}
+	var open []int
+	for i, tok := range d.tokens {
+		switch tok.Type {
+		case token.LBRACE:
+			open = append(open, i)
+		case token.RBRACE:
+			if len(open) > 0 {
+				closing[open[len(open)-1]] = tok.Offset
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	bodyOf := func(i int) scope {
+		end, ok := closing[i]
+		if !ok {
+			end = len(d.text)
+		}
+		return scope{d.tokens[i].End, end}
+	}
+
+	//每个名称所在的函数体
+	type name struct {
+		tok   token.Token
+		kind  int
+		scope scope
+	}
+	var names []name
+	var bodies []scope
+	for i, tok := range d.tokens {
+		if tok.Type != token.FUNCTION && tok.Type != token.MACRO {
+			continue
+		}
+		j := i + 1
+		if j >= len(d.tokens) || d.tokens[j].Type != token.LPAREN {
+			continue
+		}
+		var params []token.Token
+		for j++; j < len(d.tokens) && d.tokens[j].Type != token.RPAREN; j++ {
+			if d.tokens[j].Type == token.IDENT {
+				params = append(params, d.tokens[j])
+			}
+		}
+		if j+1 >= len(d.tokens) || d.tokens[j+1].Type != token.LBRACE {
+			continue
+		}
+		body := bodyOf(j + 1)
+		bodies = append(bodies, body)
+		for _, p := range params {
+			names = append(names, name{p, CompletionVariable, body})
+		}
+	}
+	innermost := func(offset int) scope {
+		s := scope{0, len(d.text)}
+		for _, b := range bodies {
+			if b.start <= offset && offset <= b.end && b.start >= s.start {
+				s = b
+			}
+		}
+		return s
+	}
+	for i, tok := range d.tokens {
+		if tok.Type != token.LET || i+1 >= len(d.tokens) || d.tokens[i+1].Type != token.IDENT {
+			continue
+		}
+		kind := CompletionVariable
+		if i+3 < len(d.tokens) && (d.tokens[i+3].Type == token.FUNCTION || d.tokens[i+3].Type == token.MACRO) {
+			kind = CompletionFunction
+		}
+		names = append(names, name{d.tokens[i+1], kind, innermost(tok.Offset)})
+	}
+
+	var items []CompletionItem
+	seen := map[string]bool{}
+	sort.SliceStable(names, func(i, j int) bool { return names[i].tok.Offset < names[j].tok.Offset })
+	for _, n := range names {
+		visible := n.scope.start <= offset && offset <= n.scope.end
+		typing := n.tok.Offset <= offset && offset <= n.tok.End //正在输入的声明本身
+		if !visible || typing || seen[n.tok.Literal] {
+			continue
+		}
+		seen[n.tok.Literal] = true
+		items = append(items, CompletionItem{Label: n.tok.Literal, Kind: n.kind})
+	}
+	return items
+}
+
+// -------------------------------------------大纲-----------------------------------------
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p DocumentSymbolParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if d.program == nil {
+		return []DocumentSymbol{}, nil
+	}
+	return d.symbols(d.program), nil
+}
+
+// node中的let，函数中的let作为函数的子符号
+func (d *document) symbols(node ast.Node) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.LetStatement:
+			sym := DocumentSymbol{
+				Name:           n.Name.Value,
+				Kind:           SymbolVariable,
+				Range:          d.rangeOf(d.loc.Start(n), d.loc.End(n)),
+				SelectionRange: d.tokenRange(n.Name.Token),
+			}
+			switch value := n.Value.(type) {
+			case *ast.FunctionLiteral:
+				sym.Kind = SymbolFunction
+				sym.Detail = "fn(" + paramList(value.Parameters) + ")"
+				sym.Children = d.symbols(value.Body)
+			case *ast.MacroLiteral:
+				sym.Kind = SymbolFunction
+				sym.Detail = "macro(" + paramList(value.Parameters) + ")"
+				sym.Children = d.symbols(value.Body)
+			}
+			symbols = append(symbols, sym)
+		}
+		return true
+	})
+	return symbols
+}
+
+// -------------------------------------------格式化-----------------------------------------
+
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentFormattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	config := format.DefaultConfig
+	if p.Options.TabSize > 0 {
+		config.Indent = p.Options.TabSize
+	}
+	config.Tabs = !p.Options.InsertSpaces
+	out, err := format.Source([]byte(d.text), config)
+	if err != nil {
+		return nil, nil //有语法错误时不格式化，错误已经作为诊断发布
+	}
+	if string(out) == d.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: d.rangeOf(0, len(d.text)), NewText: string(out)}}, nil
+}
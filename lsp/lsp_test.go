@@ -0,0 +1,669 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 在同一进程中通过管道与服务器通信的客户端
+type client struct {
+	t             *testing.T
+	conn          *conn
+	in            io.Closer
+	nextID        int
+	messages      chan *message
+	notifications []*message
+	done          chan error //Serve的返回值
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, conn: newConn(clientIn, clientOut), in: clientOut, messages: make(chan *message, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	//服务器写消息时会阻塞，客户端要一直读
+	go func() {
+		defer close(c.messages)
+		for {
+			body, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("invalid message from server: %s", body)
+				return
+			}
+			c.messages <- &msg
+		}
+	}()
+	return c
+}
+
+// 初始化后的客户端，uri为打开的文档
+func openClient(t *testing.T, uri, text string) *client {
+	c := newClient(t)
+	c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, nil)
+	c.notify("initialized", map[string]interface{}{})
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text}})
+	c.diagnostics()
+	return c
+}
+
+func (c *client) receive() *message {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+	}
+	return nil
+}
+
+// 发送请求，结果解码到result中，返回错误响应
+func (c *client) call(method string, params, result interface{}) *rpcError {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	data, _ := json.Marshal(params)
+	if err := c.conn.write(&message{ID: id, Method: method, Params: data}); err != nil {
+		c.t.Fatalf("write failed: %s", err)
+	}
+	for {
+		msg := c.receive()
+		if msg.Method != "" {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(msg.ID) != string(id) {
+			c.t.Fatalf("response to %s has id %s, want %s", method, msg.ID, id)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("cannot decode result of %s: %s (%s)", method, err, msg.Result)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatalf("write failed: %s", err)
+	}
+}
+
+// 下一条发布的诊断
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	var msg *message
+	if len(c.notifications) > 0 {
+		msg, c.notifications = c.notifications[0], c.notifications[1:]
+	} else {
+		msg = c.receive()
+	}
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %s", msg.Method)
+	}
+	var params PublishDiagnosticsParams
+	json.Unmarshal(msg.Params, &params)
+	return params
+}
+
+func at(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{line, character}}
+}
+
+func rng(line, start, endLine, end int) Range {
+	return Range{Position{line, start}, Position{endLine, end}}
+}
+
+func TestLifecycle(t *testing.T) {
+	handlers["test/panic"] = func(s *Server, params json.RawMessage) (interface{}, error) { panic("boom") }
+	defer delete(handlers, "test/panic")
+
+	c := newClient(t)
+	if err := c.call("textDocument/hover", at("file:///a.mk", 0, 0), nil); err == nil || err.Code != codeServerNotInitialized {
+		t.Fatalf("expected ServerNotInitialized before initialize, got %v", err)
+	}
+
+	var result InitializeResult
+	if err := c.call("initialize", map[string]interface{}{"processId": nil, "capabilities": map[string]interface{}{}}, &result); err != nil {
+		t.Fatalf("initialize failed: %s", err)
+	}
+	caps := result.Capabilities
+	if !caps.HoverProvider || !caps.DefinitionProvider || !caps.ReferencesProvider || !caps.DocumentSymbolProvider ||
+		!caps.DocumentFormattingProvider || !caps.SemanticTokensProvider.Full || caps.TextDocumentSync.Change != SyncIncremental {
+		t.Errorf("missing capabilities: %+v", caps)
+	}
+	if !reflect.DeepEqual(caps.SemanticTokensProvider.Legend, legend) {
+		t.Errorf("wrong legend: %+v", caps.SemanticTokensProvider.Legend)
+	}
+
+	if err := c.call("workspace/nope", nil, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected MethodNotFound, got %v", err)
+	}
+	if err := c.call("textDocument/hover", at("file:///missing.mk", 0, 0), nil); err == nil || err.Code != codeInvalidParams {
+		t.Errorf("expected InvalidParams for a document that is not open, got %v", err)
+	}
+	c.notify("$/cancelRequest", map[string]int{"id": 1}) //不支持的通知被忽略
+	//处理函数出错时返回InternalError，会话继续
+	if err := c.call("test/panic", nil, nil); err == nil || err.Code != codeInternalError {
+		t.Errorf("expected InternalError from a panicking handler, got %v", err)
+	}
+	if err := c.call("textDocument/hover", at("file:///missing.mk", 0, 0), nil); err == nil || err.Code != codeInvalidParams {
+		t.Errorf("session did not survive a panic, got %v", err)
+	}
+
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatalf("shutdown failed: %s", err)
+	}
+	if err := c.call("textDocument/hover", at("file:///a.mk", 0, 0), nil); err == nil || err.Code != codeInvalidRequest {
+		t.Errorf("expected InvalidRequest after shutdown, got %v", err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+
+	//没有shutdown就exit
+	c = newClient(t)
+	c.notify("exit", nil)
+	if err := <-c.done; err != ErrNoShutdown {
+		t.Errorf("expected ErrNoShutdown, got %v", err)
+	}
+
+	//输入关闭时正常返回
+	c = newClient(t)
+	c.in.Close()
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}
+
+func TestFraming(t *testing.T) {
+	out := &strings.Builder{}
+	in := "Content-Length: 8\r\n\r\n{broken}" +
+		"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\nContent-Length: 46\r\n\r\n" +
+		`{"jsonrpc":"2.0","id":"a","method":"shutdown"}`
+	if err := Serve(strings.NewReader(in), out); err != nil {
+		t.Fatalf("Serve returned %v", err)
+	}
+	replies := strings.Split(out.String(), "Content-Length: ")[1:]
+	if len(replies) != 2 {
+		t.Fatalf("expected 2 replies, got %q", out.String())
+	}
+	if !strings.Contains(replies[0], `"id":null`) || !strings.Contains(replies[0], fmt.Sprint(codeParseError)) {
+		t.Errorf("expected a parse error, got %q", replies[0])
+	}
+	if !strings.Contains(replies[1], `"id":"a"`) || !strings.Contains(replies[1], fmt.Sprint(codeServerNotInitialized)) {
+		t.Errorf("wrong reply: %q", replies[1])
+	}
+
+	if err := Serve(strings.NewReader("Content-Length: 10\r\n\r\n{}"), io.Discard); err == nil {
+		t.Errorf("expected an error for a truncated message")
+	}
+	if err := Serve(strings.NewReader("\r\n{}"), io.Discard); err == nil {
+		t.Errorf("expected an error for a missing Content-Length")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	uri := "file:///diag.mk"
+	c := newClient(t)
+	c.call("initialize", map[string]interface{}{}, nil)
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: "let x = 1;\nlet = 2;"}})
+	diags := c.diagnostics()
+	if diags.URI != uri || diags.Version != 1 || len(diags.Diagnostics) == 0 {
+		t.Fatalf("wrong diagnostics: %+v", diags)
+	}
+	if d := diags.Diagnostics[0]; d.Range != rng(1, 4, 1, 5) || d.Severity != SeverityError || d.Code != "syntax" || d.Source != "monkey" {
+		t.Errorf("wrong syntax diagnostic: %+v", d)
+	}
+
+	//增量修改：把第二行改成put(x, y);
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Range: &Range{Position{1, 0}, Position{1, 8}}, Text: "put(x, y);"}},
+	})
+	diags = c.diagnostics()
+	expected := []Diagnostic{{Range: rng(1, 7, 1, 8), Severity: SeverityError, Code: "undefined", Source: "monkey", Message: "undefined: y"}}
+	if diags.Version != 2 || !reflect.DeepEqual(diags.Diagnostics, expected) {
+		t.Errorf("wrong diagnostics after change: %+v", diags)
+	}
+
+	//整个替换
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let unused = 1;"}},
+	})
+	diags = c.diagnostics()
+	if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Severity != SeverityWarning || diags.Diagnostics[0].Code != "unused" {
+		t.Errorf("wrong diagnostics after full change: %+v", diags)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if diags = c.diagnostics(); len(diags.Diagnostics) != 0 || diags.Diagnostics == nil {
+		t.Errorf("expected an empty diagnostics array after close: %+v", diags)
+	}
+}
+
+const program = `let add = fn(a, b) { a + b };
+let total = add(1, 2);
+let twice = fn(f, x) {
+  let y = f(x);
+  f(y)
+};
+put(twice(fn(n) { n * 2 }, total), len("abc"), math.sqrt(total), math.pi);
+`
+
+func TestDefinitionAndReferences(t *testing.T) {
+	uri := "file:///prog.mk"
+	c := openClient(t, uri, program)
+
+	tests := []struct {
+		line, character int
+		expected        *Location
+	}{
+		{0, 21, &Location{uri, rng(0, 13, 0, 14)}}, //a + b 中的a
+		{0, 26, &Location{uri, rng(0, 16, 0, 17)}}, //b
+		{1, 12, &Location{uri, rng(0, 4, 0, 7)}},   //add
+		{1, 15, &Location{uri, rng(0, 4, 0, 7)}},   //光标在add末尾
+		{4, 4, &Location{uri, rng(3, 6, 3, 7)}},    //f(y)中的y
+		{6, 30, &Location{uri, rng(1, 4, 1, 9)}},   //total
+		{6, 18, &Location{uri, rng(6, 13, 6, 14)}}, //n * 2中的形参n
+		{0, 4, &Location{uri, rng(0, 4, 0, 7)}},    //声明本身
+		{6, 38, nil},                               //len
+		{6, 53, nil},                               //math.sqrt
+		{0, 9, nil},                                //不在标识符上
+	}
+	for _, tt := range tests {
+		var loc *Location
+		if err := c.call("textDocument/definition", at(uri, tt.line, tt.character), &loc); err != nil {
+			t.Fatalf("definition failed: %s", err)
+		}
+		if !reflect.DeepEqual(loc, tt.expected) {
+			t.Errorf("definition at %d:%d: got %+v, want %+v", tt.line, tt.character, loc, tt.expected)
+		}
+	}
+
+	refs := func(line, character int, decl bool) []Range {
+		params := ReferenceParams{TextDocumentPositionParams: at(uri, line, character)}
+		params.Context.IncludeDeclaration = decl
+		var locs []Location
+		if err := c.call("textDocument/references", params, &locs); err != nil {
+			t.Fatalf("references failed: %s", err)
+		}
+		var ranges []Range
+		for _, loc := range locs {
+			ranges = append(ranges, loc.Range)
+		}
+		return ranges
+	}
+	if got, want := refs(2, 15, true), []Range{rng(2, 15, 2, 16), rng(3, 10, 3, 11), rng(4, 2, 4, 3)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("references of f: got %v, want %v", got, want)
+	}
+	if got, want := refs(6, 30, false), []Range{rng(6, 27, 6, 32), rng(6, 57, 6, 62)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("references of total: got %v, want %v", got, want)
+	}
+}
+
+// 有语法错误时仍然在解析出来的部分中查找定义和引用
+func TestDefinitionWithSyntaxError(t *testing.T) {
+	uri := "file:///partial.mk"
+	text := "let add = fn(a, b) { a + b };\nlet total = add(1, 2);\nput(total, add(total, 1));\nlet = 3;\nlet z = ;\n"
+	c := newClient(t)
+	c.call("initialize", map[string]interface{}{}, nil)
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: text}})
+	for _, d := range c.diagnostics().Diagnostics {
+		if d.Code != "syntax" {
+			t.Errorf("only syntax errors should be reported: %+v", d)
+		}
+	}
+
+	var loc *Location
+	if err := c.call("textDocument/definition", at(uri, 2, 5), &loc); err != nil {
+		t.Fatalf("definition failed: %s", err)
+	}
+	if expected := (&Location{uri, rng(1, 4, 1, 9)}); !reflect.DeepEqual(loc, expected) {
+		t.Errorf("definition of total: got %+v, want %+v", loc, expected)
+	}
+
+	params := ReferenceParams{TextDocumentPositionParams: at(uri, 0, 5)}
+	var locs []Location
+	if err := c.call("textDocument/references", params, &locs); err != nil {
+		t.Fatalf("references failed: %s", err)
+	}
+	var got []Range
+	for _, loc := range locs {
+		got = append(got, loc.Range)
+	}
+	if want := []Range{rng(1, 12, 1, 15), rng(2, 11, 2, 14)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("references of add: got %v, want %v", got, want)
+	}
+
+	//quote的实参有语法错误时为nil
+	broken := "file:///quote.mk"
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: broken, Version: 1, Text: "let x = 1;\nquote(if(x)))"}})
+	c.diagnostics()
+	if err := c.call("textDocument/definition", at(broken, 0, 4), &loc); err != nil {
+		t.Fatalf("definition failed on a broken quote: %s", err)
+	}
+
+	//输入到一半的每个前缀都不会使服务器出错
+	for i := range program {
+		prefix := "file:///prefix.mk"
+		c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: prefix, Version: 1, Text: program[:i]}})
+		c.diagnostics()
+		var symbols []DocumentSymbol
+		if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: prefix}}, &symbols); err != nil {
+			t.Fatalf("documentSymbol failed on %q: %s", program[:i], err)
+		}
+		var tokens SemanticTokens
+		if err := c.call("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: TextDocumentIdentifier{URI: prefix}}, &tokens); err != nil {
+			t.Fatalf("semanticTokens failed on %q: %s", program[:i], err)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	uri := "file:///prog.mk"
+	c := openClient(t, uri, program)
+
+	tests := []struct {
+		line, character int
+		expected        string //为空表示没有提示
+	}{
+		{6, 36, "```monkey\nlen(x)\n```\n\nReturns the number of characters in a string, elements in an array or pairs in a hash."},
+		{6, 53, "```monkey\nmath.sqrt(x)\n```\n\nReturns the square root of x."},
+		{6, 71, "```monkey\nmath.pi = 3.141592653589793\n```"},
+		{6, 48, "```monkey\nmodule math\n```"},
+		{1, 13, "```monkey\nlet add = fn(a, b)\n```"},
+		{1, 6, "```monkey\nlet total\n```"},
+		{3, 10, "```monkey\n(parameter) f\n```"},
+		{6, 41, ""}, //字符串
+	}
+	for _, tt := range tests {
+		var hover *Hover
+		if err := c.call("textDocument/hover", at(uri, tt.line, tt.character), &hover); err != nil {
+			t.Fatalf("hover failed: %s", err)
+		}
+		if tt.expected == "" {
+			if hover != nil {
+				t.Errorf("hover at %d:%d: expected nothing, got %q", tt.line, tt.character, hover.Contents.Value)
+			}
+			continue
+		}
+		if hover == nil || !strings.HasPrefix(hover.Contents.Value, tt.expected) || hover.Contents.Kind != "markdown" {
+			t.Errorf("hover at %d:%d: got %+v, want %q", tt.line, tt.character, hover, tt.expected)
+		}
+	}
+
+	//遮蔽内置函数后显示let
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let len = fn(s) { 0 };\nput(len(1));"}},
+	})
+	c.diagnostics()
+	var hover *Hover
+	c.call("textDocument/hover", at(uri, 1, 5), &hover)
+	if hover == nil || hover.Contents.Value != "```monkey\nlet len = fn(s)\n```" || *hover.Range != rng(1, 4, 1, 7) {
+		t.Errorf("wrong hover for a shadowed builtin: %+v", hover)
+	}
+
+	//有语法错误时仍然显示内置函数的签名
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "put(math.pow(2, "}},
+	})
+	c.diagnostics()
+	hover = nil
+	c.call("textDocument/hover", at(uri, 0, 10), &hover)
+	if hover == nil || !strings.HasPrefix(hover.Contents.Value, "```monkey\nmath.pow(x, y)\n```") {
+		t.Errorf("wrong hover in a broken document: %+v", hover)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	uri := "file:///comp.mk"
+	text := `let limit = 10;
+let scale = fn(value, factor) {
+  let result = value * factor;
+  res
+};
+let other = fn(unrelated) { unrelated };
+put(math.s
+`
+	c := openClient(t, uri, text)
+
+	labels := func(line, character int) map[string]CompletionItem {
+		var items []CompletionItem
+		if err := c.call("textDocument/completion", at(uri, line, character), &items); err != nil {
+			t.Fatalf("completion failed: %s", err)
+		}
+		m := map[string]CompletionItem{}
+		for _, item := range items {
+			m[item.Label] = item
+		}
+		return m
+	}
+
+	//函数体内：形参、函数内的let和外层的let可见，其他函数的形参不可见
+	items := labels(3, 5)
+	if len(items) != 2 || items["result"].Kind != CompletionVariable || items["rest"].Kind != CompletionFunction {
+		t.Errorf("completion of res: %v", items)
+	}
+	items = labels(3, 2)
+	for _, name := range []string{"value", "factor", "result", "limit", "scale", "other", "len", "math", "let", "fn"} {
+		if _, ok := items[name]; !ok {
+			t.Errorf("completion in scale: missing %s", name)
+		}
+	}
+	if _, ok := items["unrelated"]; ok {
+		t.Errorf("completion in scale: parameter of another function is visible")
+	}
+	if items["scale"].Kind != CompletionFunction || items["len"].Kind != CompletionFunction || items["len"].Detail != "len(x)" ||
+		items["math"].Kind != CompletionModule || items["let"].Kind != CompletionKeyword {
+		t.Errorf("wrong completion kinds: %+v %+v %+v %+v", items["scale"], items["len"], items["math"], items["let"])
+	}
+
+	//顶层看不到函数内的名称
+	items = labels(7, 0)
+	if _, ok := items["result"]; ok {
+		t.Errorf("completion at top level: local variable is visible")
+	}
+	if _, ok := items["limit"]; !ok {
+		t.Errorf("completion at top level: missing limit")
+	}
+
+	//模块成员
+	items = labels(6, 10)
+	for _, name := range []string{"sqrt", "sin", "seed"} {
+		if _, ok := items[name]; !ok {
+			t.Errorf("completion of math.s: missing %s", name)
+		}
+	}
+	if _, ok := items["abs"]; ok {
+		t.Errorf("completion of math.s: abs does not match the prefix")
+	}
+	if items["sqrt"].Detail != "math.sqrt(x)" || items["sqrt"].Documentation == nil {
+		t.Errorf("wrong item for math.sqrt: %+v", items["sqrt"])
+	}
+	items = labels(6, 9)
+	if items["pi"].Kind != CompletionConstant || items["pi"].Detail != "math.pi = 3.141592653589793" {
+		t.Errorf("wrong item for math.pi: %+v", items["pi"])
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	uri := "file:///prog.mk"
+	c := openClient(t, uri, program)
+
+	var symbols []DocumentSymbol
+	if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols); err != nil {
+		t.Fatalf("documentSymbol failed: %s", err)
+	}
+	expected := []DocumentSymbol{
+		{Name: "add", Detail: "fn(a, b)", Kind: SymbolFunction, Range: rng(0, 0, 0, 28), SelectionRange: rng(0, 4, 0, 7)},
+		{Name: "total", Kind: SymbolVariable, Range: rng(1, 0, 1, 21), SelectionRange: rng(1, 4, 1, 9)},
+		{Name: "twice", Detail: "fn(f, x)", Kind: SymbolFunction, Range: rng(2, 0, 5, 1), SelectionRange: rng(2, 4, 2, 9), Children: []DocumentSymbol{
+			{Name: "y", Kind: SymbolVariable, Range: rng(3, 2, 3, 14), SelectionRange: rng(3, 6, 3, 7)},
+		}},
+	}
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("wrong symbols:\ngot  %+v\nwant %+v", symbols, expected)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let = 1;"}},
+	})
+	c.diagnostics()
+	symbols = nil
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+	if symbols == nil || len(symbols) != 0 {
+		t.Errorf("expected no symbols in a broken document, got %+v", symbols)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	uri := "file:///fmt.mk"
+	c := openClient(t, uri, "let f=fn(x){\nx*2}\nput(f(1))")
+
+	format := func(tabSize int, spaces bool) []TextEdit {
+		params := DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}, Options: FormattingOptions{TabSize: tabSize, InsertSpaces: spaces}}
+		var edits []TextEdit
+		if err := c.call("textDocument/formatting", params, &edits); err != nil {
+			t.Fatalf("formatting failed: %s", err)
+		}
+		return edits
+	}
+	edits := format(2, true)
+	expected := []TextEdit{{Range: rng(0, 0, 2, 9), NewText: "let f = fn(x) {\n  x * 2\n};\nput(f(1));\n"}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("wrong edits: %+v", edits)
+	}
+	if edits := format(4, false); len(edits) != 1 || edits[0].NewText != "let f = fn(x) {\n\tx * 2\n};\nput(f(1));\n" {
+		t.Errorf("wrong edits with tabs: %+v", edits)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: expected[0].NewText}},
+	})
+	c.diagnostics()
+	if edits := format(2, true); edits == nil || len(edits) != 0 {
+		t.Errorf("expected no edits for a formatted document, got %+v", edits)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let = 1;"}},
+	})
+	c.diagnostics()
+	if edits := format(2, true); edits != nil {
+		t.Errorf("expected null for a broken document, got %+v", edits)
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	uri := "file:///sem.mk"
+	text := "// 注释\nlet f = fn(x) { x + len(\"a\nb\") };\nput(f(1), math.pi, y.z);"
+	c := openClient(t, uri, text)
+
+	var tokens SemanticTokens
+	if err := c.call("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &tokens); err != nil {
+		t.Fatalf("semanticTokens failed: %s", err)
+	}
+
+	//还原成绝对位置后比较
+	type tok struct {
+		line, character, length int
+		typ                     string
+		mods                    int
+	}
+	var got []tok
+	line, character := 0, 0
+	for i := 0; i+5 <= len(tokens.Data); i += 5 {
+		d := tokens.Data[i : i+5]
+		if d[0] > 0 {
+			character = 0
+		}
+		line += int(d[0])
+		character += int(d[1])
+		got = append(got, tok{line, character, int(d[2]), legend.TokenTypes[d[3]], int(d[4])})
+	}
+	expected := []tok{
+		{0, 0, 5, "comment", 0},
+		{1, 0, 3, "keyword", 0},
+		{1, 4, 1, "function", modDeclaration},
+		{1, 6, 1, "operator", 0},
+		{1, 8, 2, "keyword", 0},
+		{1, 11, 1, "parameter", modDeclaration},
+		{1, 16, 1, "parameter", 0},
+		{1, 18, 1, "operator", 0},
+		{1, 20, 3, "function", modDefaultLibrary},
+		{1, 24, 2, "string", 0}, //跨行的字符串拆成两段
+		{2, 0, 2, "string", 0},
+		{3, 0, 3, "function", modDefaultLibrary},
+		{3, 4, 1, "function", 0},
+		{3, 6, 1, "number", 0},
+		{3, 10, 4, "namespace", modDefaultLibrary},
+		{3, 15, 2, "property", modDefaultLibrary},
+		{3, 19, 1, "variable", 0},
+		{3, 21, 1, "property", 0},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong semantic tokens:\ngot  %v\nwant %v", got, expected)
+	}
+}
+
+// 列号按UTF-16编码单元计
+func TestUTF16Positions(t *testing.T) {
+	uri := "file:///utf16.mk"
+	text := "let s = \"😀é\"; put(s, s);"
+	c := openClient(t, uri, text)
+
+	//😀占2个编码单元，é占1个
+	var locs []Location
+	params := ReferenceParams{TextDocumentPositionParams: at(uri, 0, 19)}
+	params.Context.IncludeDeclaration = true
+	if err := c.call("textDocument/references", params, &locs); err != nil {
+		t.Fatalf("references failed: %s", err)
+	}
+	var ranges []Range
+	for _, loc := range locs {
+		ranges = append(ranges, loc.Range)
+	}
+	if want := []Range{rng(0, 4, 0, 5), rng(0, 19, 0, 20), rng(0, 22, 0, 23)}; !reflect.DeepEqual(ranges, want) {
+		t.Errorf("wrong ranges: got %v, want %v", ranges, want)
+	}
+
+	d := newDocument(uri, 1, "a😀\nb")
+	for offset, pos := range map[int]Position{0: {0, 0}, 1: {0, 1}, 5: {0, 3}, 6: {1, 0}, 7: {1, 1}} {
+		if got := d.position(offset); got != pos {
+			t.Errorf("position(%d) = %v, want %v", offset, got, pos)
+		}
+		if got := d.offset(pos); got != offset {
+			t.Errorf("offset(%v) = %d, want %d", pos, got, offset)
+		}
+	}
+	if got := d.offset(Position{0, 99}); got != 5 {
+		t.Errorf("offset past the end of the line = %d, want 5", got)
+	}
+}
@@ -0,0 +1,150 @@
+package lsp
+
+//语义高亮 按词法单元分类，标识符根据作用域分析区分变量、形参、函数和内置名称
+
+import (
+	"encoding/json"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/token"
+	"strings"
+)
+
+// 词法单元的类型，下标与legend中的顺序一致
+const (
+	semKeyword = iota
+	semVariable
+	semParameter
+	semFunction
+	semProperty
+	semNamespace
+	semString
+	semNumber
+	semOperator
+	semComment
+)
+
+// 修饰符的位
+const (
+	modDeclaration    = 1 << iota
+	modDefaultLibrary //内置函数和模块
+)
+
+var legend = SemanticTokensLegend{
+	TokenTypes:     []string{"keyword", "variable", "parameter", "function", "property", "namespace", "string", "number", "operator", "comment"},
+	TokenModifiers: []string{"declaration", "defaultLibrary"},
+}
+
+func (s *Server) semanticTokens(params json.RawMessage) (interface{}, error) {
+	var p SemanticTokensParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	data := []uint32{}
+	prev := Position{}
+	for i, tok := range d.tokens {
+		typ, mods, ok := d.classify(i)
+		if !ok {
+			continue
+		}
+		//跨行的词法单元(如包含换行的字符串)按行拆开
+		start := tok.Offset
+		for _, part := range strings.SplitAfter(d.text[tok.Offset:tok.End], "\n") {
+			part = strings.TrimSuffix(part, "\n")
+			if part != "" {
+				pos := d.position(start)
+				delta := pos.Character
+				if pos.Line == prev.Line {
+					delta -= prev.Character
+				}
+				data = append(data, uint32(pos.Line-prev.Line), uint32(delta), uint32(utf16Len(part)), uint32(typ), uint32(mods))
+				prev = pos
+			}
+			start += len(part) + 1
+		}
+	}
+	return SemanticTokens{Data: data}, nil
+}
+
+// 第i个词法单元的类型和修饰符，不需要高亮时ok为false
+func (d *document) classify(i int) (typ, mods int, ok bool) {
+	tok := d.tokens[i]
+	switch tok.Type {
+	case token.LET, token.FUNCTION, token.IF, token.ELIF, token.ELSE, token.RETURN, token.MACRO, token.TRUE, token.FALSE:
+		return semKeyword, 0, true
+	case token.INT, token.FLOAT:
+		return semNumber, 0, true
+	case token.STRING:
+		return semString, 0, true
+	case token.COMMENT:
+		return semComment, 0, true
+	case token.ASSIGN, token.PLUS, token.MINUS, token.BANG, token.ASTERISK, token.SLASH,
+		token.LT, token.GT, token.EQ, token.NOT_EQ, token.AND, token.OR:
+		return semOperator, 0, true
+	case token.IDENT:
+		typ, mods = d.classifyIdent(i)
+		return typ, mods, true
+	}
+	return 0, 0, false
+}
+
+func (d *document) classifyIdent(i int) (typ, mods int) {
+	tok := d.tokens[i]
+	var ident *ast.Identifier
+	if d.program != nil {
+		ident = d.identAt(tok.Offset)
+	}
+	if ident == nil {
+		//不在语法树中(有语法错误)时只根据词法单元判断
+		if i >= 1 && d.tokens[i-1].Type == token.DOT {
+			if i >= 2 {
+				return builtinClass(d.tokens[i-2].Literal+"."+tok.Literal, semProperty)
+			}
+			return semProperty, 0
+		}
+		return builtinClass(tok.Literal, semVariable)
+	}
+
+	if decl, ok := d.refs[ident]; ok {
+		if ident == decl {
+			mods = modDeclaration
+		}
+		if d.params[decl] {
+			return semParameter, mods
+		}
+		switch d.lets[decl].Value.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return semFunction, mods
+		}
+		return semVariable, mods
+	}
+	if prop, ok := d.props[ident]; ok {
+		if module, ok := prop.Object.(*ast.Identifier); ok && d.refs[module] == nil {
+			return builtinClass(module.Value+"."+ident.Value, semProperty)
+		}
+		return semProperty, 0
+	}
+	return builtinClass(ident.Value, semVariable)
+}
+
+// 内置名称的类型，name不是内置名称时为otherwise
+func builtinClass(name string, otherwise int) (typ, mods int) {
+	if _, ok := evaluator.LookupSignature(name); ok {
+		return semFunction, modDefaultLibrary
+	}
+	if _, ok := moduleMember(name); ok {
+		return semProperty, modDefaultLibrary
+	}
+	if obj, ok := evaluator.LookupBuiltin(name); ok {
+		if _, isModule := obj.(*object.Hash); isModule {
+			return semNamespace, modDefaultLibrary
+		}
+	}
+	return otherwise, 0
+}
@@ -0,0 +1,204 @@
+package lsp
+
+//语言服务器 通过标准输入输出与编辑器通信
+//  文档同步后发布语法错误和monkey vet的检查结果
+//  语义高亮、跳转到定义、查找引用、悬停提示、补全、大纲和格式化
+//按收到的顺序逐个处理消息
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey_Interpreter/vet"
+)
+
+// 处理一种请求或通知，params为消息中的参数
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                       (*Server).initialize,
+	"initialized":                      nil,
+	"shutdown":                         (*Server).shutdown,
+	"textDocument/didOpen":             (*Server).didOpen,
+	"textDocument/didChange":           (*Server).didChange,
+	"textDocument/didClose":            (*Server).didClose,
+	"textDocument/hover":               (*Server).hover,
+	"textDocument/definition":          (*Server).definition,
+	"textDocument/references":          (*Server).references,
+	"textDocument/completion":          (*Server).completion,
+	"textDocument/documentSymbol":      (*Server).documentSymbol,
+	"textDocument/formatting":          (*Server).formatting,
+	"textDocument/semanticTokens/full": (*Server).semanticTokens,
+}
+
+// 收到exit之前没有收到shutdown
+var ErrNoShutdown = errors.New("lsp: exit before shutdown")
+
+type Server struct {
+	conn         *conn
+	docs         map[string]*document
+	initialized  bool
+	shuttingDown bool
+}
+
+// 在in和out上运行语言服务器，直到收到exit通知或in关闭
+func Serve(in io.Reader, out io.Writer) error {
+	s := &Server{conn: newConn(in, out), docs: map[string]*document{}}
+	for {
+		body, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.conn.reply(json.RawMessage("null"), nil, &rpcError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shuttingDown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+		if err := s.handle(&msg); err != nil {
+			return err
+		}
+	}
+}
+
+// 处理一条消息，只在写回复失败时返回错误
+func (s *Server) handle(msg *message) error {
+	isRequest := len(msg.ID) != 0
+	h, ok := handlers[msg.Method]
+	var result interface{}
+	var err error
+	switch {
+	case !ok:
+		if !isRequest {
+			return nil //忽略不支持的通知，如$/cancelRequest
+		}
+		err = &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", msg.Method)}
+	case !s.initialized && msg.Method != "initialize":
+		err = &rpcError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	case s.shuttingDown:
+		err = &rpcError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	case h != nil:
+		result, err = h.call(s, msg.Params)
+	}
+	if !isRequest {
+		return nil
+	}
+	return s.conn.reply(msg.ID, result, err)
+}
+
+// 执行处理函数，分析代码时出错(panic)返回InternalError，不中断整个会话
+func (h handler) call(s *Server, params json.RawMessage) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &rpcError{Code: codeInternalError, Message: fmt.Sprintf("internal error: %v", r)}
+		}
+	}()
+	return h(s, params)
+}
+
+// 解析参数，失败时返回InvalidParams错误
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	if s.initialized {
+		return nil, &rpcError{Code: codeInvalidRequest, Message: "server already initialized"}
+	}
+	s.initialized = true
+	var result InitializeResult
+	result.ServerInfo.Name = "monkey"
+	result.Capabilities = ServerCapabilities{
+		TextDocumentSync:           TextDocumentSyncOptions{OpenClose: true, Change: SyncIncremental},
+		HoverProvider:              true,
+		DefinitionProvider:         true,
+		ReferencesProvider:         true,
+		CompletionProvider:         CompletionOptions{TriggerCharacters: []string{"."}},
+		DocumentSymbolProvider:     true,
+		DocumentFormattingProvider: true,
+		SemanticTokensProvider:     SemanticTokensOptions{Legend: legend, Full: true},
+	}
+	return result, nil
+}
+
+func (s *Server) shutdown(params json.RawMessage) (interface{}, error) {
+	s.shuttingDown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	s.update(newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text))
+	return nil, nil
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	for _, change := range p.ContentChanges {
+		d = newDocument(d.uri, p.TextDocument.Version, d.apply(change))
+	}
+	s.update(d)
+	return nil, nil
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	//关闭后清除编辑器中的诊断
+	s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	return nil, nil
+}
+
+// 保存文档并发布诊断
+func (s *Server) update(d *document) {
+	s.docs[d.uri] = d
+	diags := []Diagnostic{}
+	for _, vd := range d.diags {
+		severity := SeverityWarning
+		if vd.Severity == vet.Error {
+			severity = SeverityError
+		}
+		diags = append(diags, Diagnostic{
+			Range:    d.rangeOf(vd.Offset, vd.End),
+			Severity: severity,
+			Code:     vd.Check,
+			Source:   "monkey",
+			Message:  vd.Message,
+		})
+	}
+	s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: d.uri, Version: d.version, Diagnostics: diags})
+}
+
+// 请求中的文档，没有打开时返回错误
+func (s *Server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("document not open: %s", uri)}
+	}
+	return d, nil
+}
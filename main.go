@@ -2,19 +2,27 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"monkey_Interpreter/interpreter"
 	"monkey_Interpreter/repl"
 	"os"
 	"os/user"
 )
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// 没有给出脚本时启动交互式REPL
+func startRepl(in io.Reader, out io.Writer, backend interpreter.Backend) int {
 	//在 main 函数内部，首先使用 os/user 包中的 user.Current() 函数获取当前用户的信息
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 
-	fmt.Printf("Hello %s! This is the Monkey programmimg language!\n", user.Username)
-	fmt.Printf("Feel freee to type in commamds\n")
-	repl.Start(os.Stdin, os.Stdout)
+	fmt.Fprintf(out, "Hello %s! This is the Monkey programmimg language!\n", user.Username)
+	fmt.Fprintf(out, "Feel freee to type in commamds\n")
+	repl.StartBackend(in, out, backend)
+	return 0
 }
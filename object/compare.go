@@ -0,0 +1,143 @@
+package object
+
+//对象之间的比较
+
+import "fmt"
+
+// 判断两个对象在结构上是否相等
+// 数组逐个比较元素，哈希表比较键值对（与顺序无关），函数等其他对象比较是否为同一个对象
+func Equal(a, b Object) bool {
+	return equal(a, b, nil)
+}
+
+// visiting记录正在比较的对象对，再次遇到时视为相等，防止循环引用导致无限递归
+// 只在比较数组和哈希表时才创建，比较标量(如哈希表查找键)时不分配内存
+func equal(a, b Object, visiting map[[2]Object]bool) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	if x, y, ok := numberPair(a, b); ok { //整数与浮点数按数值比较
+		return x == y
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Float:
+		return a.Value == b.(*Float).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Null:
+		return true
+	case *Array:
+		other := b.(*Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		pair := [2]Object{a, other}
+		if visiting[pair] {
+			return true
+		}
+		if visiting == nil {
+			visiting = make(map[[2]Object]bool)
+		}
+		visiting[pair] = true
+		defer delete(visiting, pair)
+
+		for i := range a.Elements {
+			if !equal(a.Elements[i], other.Elements[i], visiting) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		other := b.(*Hash)
+		if a.Len() != other.Len() {
+			return false
+		}
+		pair := [2]Object{a, other}
+		if visiting[pair] {
+			return true
+		}
+		if visiting == nil {
+			visiting = make(map[[2]Object]bool)
+		}
+		visiting[pair] = true
+		defer delete(visiting, pair)
+
+		for _, p := range a.OrderedPairs() {
+			value, ok := other.Get(p.Key)
+			if !ok || !equal(p.Value, value, visiting) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// 比较两个对象的大小，a<b返回-1，a==b返回0，a>b返回1
+// 字符串按字典序，数组逐个元素比较（前缀较短者更小），无法比较时返回错误
+func Compare(a, b Object) (int, error) {
+	if x, y, ok := numberPair(a, b); ok {
+		return compareOrdered(x, y), nil
+	}
+	if a.Type() != b.Type() {
+		return 0, fmt.Errorf("cannot compare %s with %s", a.Type(), b.Type())
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return compareOrdered(a.Value, b.(*Integer).Value), nil
+	case *Float:
+		return compareOrdered(a.Value, b.(*Float).Value), nil
+	case *String:
+		return compareOrdered(a.Value, b.(*String).Value), nil
+	case *Array:
+		other := b.(*Array)
+		for i := 0; i < len(a.Elements) && i < len(other.Elements); i++ {
+			c, err := Compare(a.Elements[i], other.Elements[i])
+			if err != nil || c != 0 {
+				return c, err
+			}
+		}
+		return compareOrdered(len(a.Elements), len(other.Elements)), nil
+	default:
+		return 0, fmt.Errorf("cannot compare %s with %s", a.Type(), b.Type())
+	}
+}
+
+func compareOrdered[T int | int64 | float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// 一个整数与一个浮点数时，将两者都转为float64
+func numberPair(a, b Object) (float64, float64, bool) {
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Float); ok {
+			return float64(a.Value), b.Value, true
+		}
+	case *Float:
+		if b, ok := b.(*Integer); ok {
+			return a.Value, float64(b.Value), true
+		}
+	}
+	return 0, 0, false
+}
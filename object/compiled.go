@@ -0,0 +1,74 @@
+package object
+
+//字节码虚拟机使用的函数对象
+
+import (
+	"bytes"
+	"fmt"
+	"monkey_Interpreter/code"
+	"strings"
+)
+
+const (
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION" //编译后的函数，只出现在常量池中
+)
+
+// 编译后的函数
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int            //局部变量个数，包括形参
+	NumParameters int            //形参个数，形参占用前NumParameters个局部变量
+	LocalNames    []string       //局部变量名，运行时按名称查找变量时使用
+	Name          string         //通过let绑定时的名称，匿名函数为空
+	Body          string         //函数体的源码，Inspect用
+	Lines         code.LineTable //行号表，调试用
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// 一次函数调用的局部变量 存放在堆上，闭包引用它以看到之后的赋值
+type Locals struct {
+	Values []Object //尚未赋值的变量为nil
+	Fn     *CompiledFunction
+	Outer  *Locals //外层函数的局部变量，顶层定义的函数为nil
+}
+
+// 执行闭包的虚拟机
+type ClosureRunner interface {
+	RunClosure(cl *Closure, args []Object) Object
+}
+
+// 闭包 由编译后的函数和定义时外层函数的局部变量组成
+type Closure struct {
+	Fn    *CompiledFunction
+	Outer *Locals
+	Owner ClosureRunner //创建该闭包的虚拟机
+}
+
+// 与求值器的函数对象类型相同，两种后端对外表现一致
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("fn")
+	out.WriteString("(")
+	out.WriteString(strings.Join(c.Fn.LocalNames[:c.Fn.NumParameters], ", "))
+	out.WriteString(") {\n")
+	out.WriteString(c.Fn.Body)
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+func (c *Closure) Call(args ...Object) Object {
+	return c.Owner.RunClosure(c, args)
+}
+
+// 可被内置函数回调的函数，求值器通过它调用其他后端创建的函数
+type Callable interface {
+	Object
+	Call(args ...Object) Object
+}
@@ -1,9 +1,12 @@
 package object
 
+import "sort"
+
 //
 type Environment struct {
-	store map[string]Object
-	outer *Environment //上一层环境
+	store       map[string]Object
+	outer       *Environment //上一层环境
+	strictIndex bool         //只在最外层环境中设置
 }
 
 func NewEnvironment() *Environment {
@@ -32,3 +35,35 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env.outer = outer
 	return env
 }
+
+// 当前环境及外层环境中绑定的所有名称，按字母排序
+func (e *Environment) Names() []string {
+	seen := map[string]bool{}
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 索引越界时返回错误而不是NULL，设置在最外层环境上，内层环境共用
+func (e *Environment) SetStrictIndex(strict bool) {
+	e.root().strictIndex = strict
+}
+
+func (e *Environment) StrictIndex() bool {
+	return e.root().strictIndex
+}
+
+func (e *Environment) root() *Environment {
+	for e.outer != nil {
+		e = e.outer
+	}
+	return e
+}
@@ -0,0 +1,207 @@
+package object
+
+//哈希表
+//键先按HashKey分桶，桶内再比较键是否真正相等，避免哈希碰撞时互相覆盖
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// 哈希表的外层键值
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+type Hashable interface { //外层键值函数接口
+	HashKey() HashKey
+}
+
+// 布尔
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	} else {
+		value = 0
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+// 整数
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// 浮点数 值为整数时与对应的整数使用同一个键（1.0 == 1），0.0与-0.0视为同一个键
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && math.Abs(f.Value) < math.MaxInt64 {
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
+// 字符串
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()} //可能哈希碰撞，由桶内比较处理
+}
+
+// 数组 由各元素的HashKey组合而成，元素都可哈希时才能作为键
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, e := range ao.Elements {
+		hashable, ok := e.(Hashable)
+		if !ok {
+			continue
+		}
+		key := hashable.HashKey()
+		h.Write([]byte(key.Type))
+		binary.LittleEndian.PutUint64(buf, key.Value)
+		h.Write(buf)
+	}
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+
+// 判断对象能否作为哈希键
+func IsHashable(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Array:
+		for _, e := range obj.Elements {
+			if !IsHashable(e) {
+				return false
+			}
+		}
+		return true
+	case Hashable:
+		return true
+	default:
+		return false
+	}
+}
+
+// 哈希表的真实键值对
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// 哈希表 pairs记录插入顺序，Inspect与遍历均按此顺序进行
+type Hash struct {
+	buckets map[HashKey][]*HashPair
+	pairs   []*HashPair
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]*HashPair)}
+}
+
+// 按键取值
+func (h *Hash) Get(key Object) (Object, bool) {
+	if !IsHashable(key) {
+		return nil, false
+	}
+	pair := h.lookup(key.(Hashable).HashKey(), key)
+	if pair == nil {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+// 写入键值对，已存在的键保持原有位置；键不可哈希时返回false
+func (h *Hash) Set(key, value Object) bool {
+	if !IsHashable(key) {
+		return false
+	}
+	h.insert(key.(Hashable).HashKey(), key, value)
+	return true
+}
+
+// 删除键值对
+func (h *Hash) Delete(key Object) {
+	if !IsHashable(key) {
+		return
+	}
+	hashed := key.(Hashable).HashKey()
+	pair := h.lookup(hashed, key)
+	if pair == nil {
+		return
+	}
+
+	bucket := h.buckets[hashed]
+	for i, p := range bucket {
+		if p == pair {
+			bucket = append(bucket[:i:i], bucket[i+1:]...)
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(h.buckets, hashed)
+	} else {
+		h.buckets[hashed] = bucket
+	}
+
+	for i, p := range h.pairs {
+		if p == pair {
+			h.pairs = append(h.pairs[:i:i], h.pairs[i+1:]...)
+			break
+		}
+	}
+}
+
+// 键值对个数
+func (h *Hash) Len() int { return len(h.pairs) }
+
+// 按插入顺序返回所有键值对
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.pairs))
+	for _, p := range h.pairs {
+		pairs = append(pairs, *p)
+	}
+	return pairs
+}
+
+// 在桶中查找与key相等的键值对
+func (h *Hash) lookup(hashed HashKey, key Object) *HashPair {
+	for _, pair := range h.buckets[hashed] {
+		if Equal(pair.Key, key) {
+			return pair
+		}
+	}
+	return nil
+}
+
+func (h *Hash) insert(hashed HashKey, key, value Object) {
+	if pair := h.lookup(hashed, key); pair != nil {
+		pair.Value = value
+		return
+	}
+	pair := &HashPair{Key: key, Value: value}
+	h.buckets[hashed] = append(h.buckets[hashed], pair)
+	h.pairs = append(h.pairs, pair)
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.OrderedPairs() {
+		pairs = append(pairs, fmt.Sprintf("%s:%s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ","))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -5,8 +5,9 @@ package object
 import (
 	"bytes"
 	"fmt"
-	"hash/fnv"
 	"monkey_Interpreter/ast"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -24,6 +25,10 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"      //内置函数
 	ARRAY_OBJ        = "ARRAY"        //数组
 	HASH_OBJ         = "HASH"         //哈希表
+	FLOAT_OBJ        = "FLOAT"        //浮点数
+	REGEX_OBJ        = "REGEX"        //正则表达式
+	QUOTE_OBJ        = "QUOTE"        //引用的代码
+	MACRO_OBJ        = "MACRO"        //宏
 )
 
 // 对象接口
@@ -40,6 +45,20 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// 浮点数对象
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") { //整数值保留小数点，与整数区分
+		s += ".0"
+	}
+	return s
+}
+
 // 布尔型对象
 type Boolean struct {
 	Value bool
@@ -88,7 +107,6 @@ func (f *Function) Inspect() string {
 
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(")")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
@@ -97,6 +115,42 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// quote(...)的结果，保存未求值的代码
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// 宏 在展开时以引用的代码为实参执行宏体，返回的代码替换宏调用
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
 // 字符串
 type String struct {
 	Value string
@@ -112,7 +166,7 @@ type Builtin struct {
 	Fn BuiltinFunction
 }
 
-func (b *Builtin) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
 // 数组
@@ -136,63 +190,10 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
-// 哈希表的外层键值
-type HashKey struct {
-	Type  ObjectType
-	Value uint64
+// 正则表达式 编译一次后可重复使用
+type Regex struct {
+	Value *regexp.Regexp
 }
 
-// 布尔
-func (b *Boolean) HashKey() HashKey {
-	var value uint64
-
-	if b.Value {
-		value = 1
-	} else {
-		value = 0
-	}
-
-	return HashKey{Type: b.Type(), Value: value}
-}
-
-// 整数
-func (i *Integer) HashKey() HashKey {
-	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
-}
-
-// 字符串
-func (s *String) HashKey() HashKey {
-	h := fnv.New64a()
-	h.Write([]byte(s.Value))
-	return HashKey{Type: s.Type(), Value: h.Sum64()} //可能哈希碰撞
-}
-
-type Hashable interface { //外层键值函数接口
-	HashKey() HashKey
-}
-
-// 哈希表的真实键值对
-type HashPair struct {
-	Key   Object
-	Value Object
-}
-
-type Hash struct {
-	Pairs map[HashKey]HashPair
-}
-
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s:%s", pair.Key.Inspect(), pair.Value.Inspect()))
-	}
-
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ","))
-	out.WriteString("}")
-
-	return out.String()
-}
+func (r *Regex) Type() ObjectType { return REGEX_OBJ }
+func (r *Regex) Inspect() string  { return "/" + r.Value.String() + "/" }
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -21,3 +24,192 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with same different content have same hash keys")
 	}
 }
+
+func TestHashInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	for _, s := range []string{"c", "a", "b"} {
+		hash.Set(&String{Value: s}, &Integer{Value: 1})
+	}
+
+	//覆盖已有的键不改变其位置
+	hash.Set(&String{Value: "a"}, &Integer{Value: 2})
+
+	if hash.Inspect() != "{c:1,a:2,b:1}" {
+		t.Errorf("hash.Inspect() wrong. got=%q", hash.Inspect())
+	}
+
+	hash.Delete(&String{Value: "a"})
+	if hash.Inspect() != "{c:1,b:1}" {
+		t.Errorf("hash.Inspect() after Delete wrong. got=%q", hash.Inspect())
+	}
+	if hash.Len() != 2 {
+		t.Errorf("hash.Len() wrong. got=%d", hash.Len())
+	}
+}
+
+// 测试哈希碰撞时不同的键不会互相覆盖
+func TestHashCollision(t *testing.T) {
+	hash := NewHash()
+	collided := HashKey{Type: STRING_OBJ, Value: 42}
+
+	a := &String{Value: "a"}
+	b := &String{Value: "b"}
+	hash.insert(collided, a, &Integer{Value: 1})
+	hash.insert(collided, b, &Integer{Value: 2})
+
+	if hash.Len() != 2 {
+		t.Fatalf("colliding keys overwrote each other. got=%d pairs", hash.Len())
+	}
+	if pair := hash.lookup(collided, a); pair == nil || pair.Value.Inspect() != "1" {
+		t.Errorf("wrong pair for key a. got=%+v", pair)
+	}
+	if pair := hash.lookup(collided, b); pair == nil || pair.Value.Inspect() != "2" {
+		t.Errorf("wrong pair for key b. got=%+v", pair)
+	}
+}
+
+func TestCompositeHashKey(t *testing.T) {
+	key1 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}
+	key2 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}
+	key3 := &Array{Elements: []Object{&String{Value: "x"}, &Integer{Value: 1}}}
+
+	if key1.HashKey() != key2.HashKey() {
+		t.Errorf("arrays with same content have different hash keys")
+	}
+	if key1.HashKey() == key3.HashKey() {
+		t.Errorf("arrays with different content have same hash keys")
+	}
+
+	hash := NewHash()
+	hash.Set(key1, &Integer{Value: 1})
+	if _, ok := hash.Get(key2); !ok {
+		t.Errorf("equal array key not found")
+	}
+
+	unhashable := &Array{Elements: []Object{&Hash{}}}
+	if IsHashable(unhashable) || hash.Set(unhashable, &Integer{Value: 1}) {
+		t.Errorf("array containing a hash must not be usable as key")
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	if (&Float{Value: 1.5}).HashKey() != (&Float{Value: 1.5}).HashKey() {
+		t.Errorf("floats with same value have different hash keys")
+	}
+	if (&Float{Value: 0}).HashKey() != (&Float{Value: math.Copysign(0, -1)}).HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+	if (&Float{Value: 1}).HashKey() != (&Integer{Value: 1}).HashKey() {
+		t.Errorf("1.0 and 1 have different hash keys")
+	}
+	if (&Float{Value: 1.5}).HashKey() == (&Float{Value: 2.5}).HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+	if (&Float{Value: 2}).Inspect() != "2.0" {
+		t.Errorf("Float.Inspect() wrong. got=%q", (&Float{Value: 2}).Inspect())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	one := &Integer{Value: 1}
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Float{Value: 1}, true},
+		{&Integer{Value: 1}, &Float{Value: 1.5}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "a"}, &String{Value: "b"}, false},
+		{&Null{}, &Null{}, true},
+		{&Array{Elements: []Object{one, &String{Value: "x"}}}, &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}, true},
+		{&Array{Elements: []Object{one}}, &Array{Elements: []Object{one, one}}, false},
+		{&Array{Elements: []Object{}}, &Hash{}, false},
+		{&Builtin{}, &Builtin{}, false},
+	}
+
+	for _, tt := range tests {
+		if Equal(tt.a, tt.b) != tt.expected {
+			t.Errorf("Equal(%s, %s) wrong. want=%t", tt.a.Inspect(), tt.b.Inspect(), tt.expected)
+		}
+	}
+
+	//哈希表相等与插入顺序无关
+	h1, h2 := NewHash(), NewHash()
+	h1.Set(&String{Value: "a"}, one)
+	h1.Set(&String{Value: "b"}, &Array{Elements: []Object{one}})
+	h2.Set(&String{Value: "b"}, &Array{Elements: []Object{&Integer{Value: 1}}})
+	h2.Set(&String{Value: "a"}, &Integer{Value: 1})
+	if !Equal(h1, h2) {
+		t.Errorf("hashes with same pairs are not equal")
+	}
+	h2.Set(&String{Value: "a"}, &Integer{Value: 2})
+	if Equal(h1, h2) {
+		t.Errorf("hashes with different values are equal")
+	}
+}
+
+// 测试循环引用时比较能正常结束
+func TestEqualCycle(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	a.Elements[1] = a
+	b := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	b.Elements[1] = b
+
+	if !Equal(a, b) {
+		t.Errorf("equal cyclic arrays are not equal")
+	}
+
+	c := &Array{Elements: []Object{&Integer{Value: 2}, nil}}
+	c.Elements[1] = c
+	if Equal(a, c) {
+		t.Errorf("different cyclic arrays are equal")
+	}
+}
+
+// 比较标量和查找哈希表时不分配内存
+func TestEqualAllocs(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: "k"}, &Integer{Value: 1})
+	key, x, y := &String{Value: "k"}, &Integer{Value: 1}, &Float{Value: 1}
+	allocs := testing.AllocsPerRun(100, func() {
+		Equal(x, y)
+		h.Get(key)
+	})
+	if allocs != 0 {
+		t.Errorf("Equal allocated %v times for scalars", allocs)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	arr := func(elements ...Object) *Array { return &Array{Elements: elements} }
+	tests := []struct {
+		a, b     Object
+		expected int
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 2}, -1},
+		{&Float{Value: 2.5}, &Float{Value: 2.5}, 0},
+		{&String{Value: "banana"}, &String{Value: "apple"}, 1},
+		{arr(&Integer{Value: 1}, &Integer{Value: 2}), arr(&Integer{Value: 1}, &Integer{Value: 3}), -1},
+		{arr(&Integer{Value: 1}), arr(&Integer{Value: 1}, &Integer{Value: 0}), -1},
+		{arr(), arr(), 0},
+	}
+
+	for _, tt := range tests {
+		c, err := Compare(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("Compare(%s, %s) returned error: %s", tt.a.Inspect(), tt.b.Inspect(), err)
+			continue
+		}
+		if c != tt.expected {
+			t.Errorf("Compare(%s, %s) wrong. got=%d, want=%d", tt.a.Inspect(), tt.b.Inspect(), c, tt.expected)
+		}
+	}
+
+	if _, err := Compare(&Integer{Value: 1}, &String{Value: "1"}); err == nil {
+		t.Errorf("expected error comparing INTEGER with STRING")
+	}
+	if _, err := Compare(&Boolean{Value: true}, &Boolean{Value: false}); err == nil {
+		t.Errorf("expected error comparing BOOLEAN with BOOLEAN")
+	}
+}
@@ -0,0 +1,308 @@
+package optimizer
+
+//函数内联 只内联满足以下条件的调用，保证结果、输出和错误都不变：
+//  被调用的是顶层let定义的函数，该名称在整个程序中只定义一次，且定义在当前顶层语句之前
+//  函数体只有一个表达式(或return表达式)，不含let、return和函数字面量，节点数不超过maxInlineSize
+//  函数体中的其他名称同样只在顶层定义一次(或者是内置函数)，不会被调用处的局部变量遮蔽
+//  实参是字面量、外层函数的形参或已定义的顶层变量，重复求值、不求值或改变求值顺序都不影响结果
+
+import (
+	"monkey_Interpreter/ast"
+)
+
+// 可内联的函数体最多包含的节点数
+const maxInlineSize = 16
+
+// 可内联的函数
+type inlineCandidate struct {
+	name   string
+	index  int //定义所在的顶层语句
+	params []string
+	body   ast.Expression
+}
+
+type inliner struct {
+	definitions map[string]int              //每个名称在程序中被定义(let或形参)的次数
+	topLevel    map[string]int              //只定义一次的顶层let所在的语句
+	candidates  map[string]*inlineCandidate //可内联的函数
+	statement   int                         //当前处理的顶层语句
+	params      []map[string]bool           //外层函数的形参
+	expanding   []string                    //正在展开的函数，防止相互调用时无限展开
+}
+
+func newInliner(program *ast.Program) *inliner {
+	in := &inliner{
+		definitions: map[string]int{},
+		topLevel:    map[string]int{},
+		candidates:  map[string]*inlineCandidate{},
+	}
+	for _, s := range program.Statements {
+		in.countDefinitions(s)
+	}
+	for i, s := range program.Statements {
+		if let, ok := s.(*ast.LetStatement); ok && in.definitions[let.Name.Value] == 1 {
+			in.topLevel[let.Name.Value] = i
+		}
+	}
+	for i, s := range program.Statements {
+		if let, ok := s.(*ast.LetStatement); ok {
+			if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+				in.consider(let.Name.Value, i, fn)
+			}
+		}
+	}
+	return in
+}
+
+// 统计各名称的定义次数
+func (in *inliner) countDefinitions(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			in.definitions[n.Name.Value]++
+		case *ast.FunctionLiteral:
+			for _, p := range n.Parameters {
+				in.definitions[p.Value]++
+			}
+		}
+		return true
+	})
+}
+
+// 检查函数是否可以内联
+func (in *inliner) consider(name string, index int, fn *ast.FunctionLiteral) {
+	if _, ok := in.topLevel[name]; !ok || fn.Body == nil || len(fn.Body.Statements) != 1 {
+		return
+	}
+	var body ast.Expression
+	switch s := fn.Body.Statements[0].(type) {
+	case *ast.ExpressionStatement:
+		body = s.Expression
+	case *ast.ReturnStatement:
+		body = s.ReturnValue
+	}
+	if body == nil {
+		return
+	}
+
+	params := make([]string, len(fn.Parameters))
+	isParam := map[string]bool{}
+	for i, p := range fn.Parameters {
+		params[i] = p.Value
+		isParam[p.Value] = true
+	}
+
+	size, ok := 0, true
+	var check func(node ast.Node) bool
+	check = func(node ast.Node) bool {
+		if node == nil || !ok {
+			return false
+		}
+		size++
+		switch node := node.(type) {
+		case *ast.LetStatement, *ast.ReturnStatement, *ast.FunctionLiteral:
+			ok = false
+		case *ast.Identifier:
+			//引用自身即为递归；其他名称必须在调用处解析为同一个值
+			if node.Value == name || (!isParam[node.Value] && !in.stable(node.Value)) {
+				ok = false
+			}
+		case *ast.PropertyExpression:
+			//属性名不是变量，只检查对象
+			ast.Inspect(node.Object, check)
+			return false
+		}
+		return ok
+	}
+	ast.Inspect(body, check)
+	if ok && size <= maxInlineSize {
+		in.candidates[name] = &inlineCandidate{name: name, index: index, params: params, body: body}
+	}
+}
+
+// 名称在任何位置都解析为同一个值：未在程序中定义(内置函数或宿主程序提供的变量)，或只在顶层定义一次
+func (in *inliner) stable(name string) bool {
+	if in.definitions[name] == 0 {
+		return true
+	}
+	_, ok := in.topLevel[name]
+	return ok
+}
+
+func (in *inliner) enterStatement(i int) {
+	in.statement = i
+}
+
+func (in *inliner) enterFunction(fn *ast.FunctionLiteral) {
+	params := map[string]bool{}
+	for _, p := range fn.Parameters {
+		params[p.Value] = true
+	}
+	in.params = append(in.params, params)
+}
+
+func (in *inliner) leaveFunction() {
+	in.params = in.params[:len(in.params)-1]
+}
+
+// 展开调用，返回代入实参后的函数体，不能内联时返回nil
+// 返回值不为nil时，调用者处理完函数体后需要调用leaveCall
+func (in *inliner) expand(call *ast.CallExpression) ast.Expression {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	fn, ok := in.candidates[ident.Value]
+	if !ok || fn.index >= in.statement || len(call.Arguments) != len(fn.params) {
+		return nil
+	}
+	for _, name := range in.expanding {
+		if name == fn.name {
+			return nil
+		}
+	}
+
+	args := map[string]ast.Expression{}
+	for i, arg := range call.Arguments {
+		if !in.pure(arg) {
+			return nil
+		}
+		args[fn.params[i]] = arg
+	}
+	in.expanding = append(in.expanding, fn.name)
+	return substitute(fn.body, args)
+}
+
+func (in *inliner) leaveCall() {
+	in.expanding = in.expanding[:len(in.expanding)-1]
+}
+
+// 实参求值没有副作用且不会出错
+func (in *inliner) pure(arg ast.Expression) bool {
+	switch arg := arg.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	case *ast.Identifier:
+		//外层函数的形参
+		for i := len(in.params) - 1; i >= 0; i-- {
+			if in.params[i][arg.Value] {
+				return true
+			}
+		}
+		//已经执行过的顶层定义
+		index, ok := in.topLevel[arg.Value]
+		return ok && index < in.statement
+	}
+	return false
+}
+
+// 复制函数体，形参替换为实参
+func substitute(e ast.Expression, args map[string]ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		if arg, ok := args[e.Value]; ok {
+			//实参只是字面量或标识符，每处使用各复制一份，哈希表的键不会重复
+			return substitute(arg, nil)
+		}
+		copied := *e
+		return &copied
+	case *ast.PrefixExpression:
+		copied := *e
+		copied.Right = substitute(e.Right, args)
+		return &copied
+	case *ast.InfixExpression:
+		copied := *e
+		copied.Left = substitute(e.Left, args)
+		copied.Right = substitute(e.Right, args)
+		return &copied
+	case *ast.IfExpression:
+		copied := *e
+		copied.Condition = substitute(e.Condition, args)
+		copied.Consequence = substituteBlock(e.Consequence, args)
+		copied.Alternatives = make([]*ast.ElIfExpression, len(e.Alternatives))
+		for i, alt := range e.Alternatives {
+			copied.Alternatives[i] = &ast.ElIfExpression{
+				Token:       alt.Token,
+				Condition:   substitute(alt.Condition, args),
+				Consequence: substituteBlock(alt.Consequence, args),
+			}
+		}
+		copied.LastAlternative = substituteBlock(e.LastAlternative, args)
+		return &copied
+	case *ast.CallExpression:
+		copied := *e
+		copied.Function = substitute(e.Function, args)
+		copied.Arguments = substituteAll(e.Arguments, args)
+		return &copied
+	case *ast.ArrayLiteral:
+		copied := *e
+		copied.Elements = substituteAll(e.Elements, args)
+		return &copied
+	case *ast.IndexExpression:
+		copied := *e
+		copied.Left = substitute(e.Left, args)
+		copied.Index = substitute(e.Index, args)
+		return &copied
+	case *ast.SliceExpression:
+		copied := *e
+		copied.Left = substitute(e.Left, args)
+		if e.Start != nil {
+			copied.Start = substitute(e.Start, args)
+		}
+		if e.End != nil {
+			copied.End = substitute(e.End, args)
+		}
+		if e.Step != nil {
+			copied.Step = substitute(e.Step, args)
+		}
+		return &copied
+	case *ast.PropertyExpression:
+		copied := *e
+		copied.Object = substitute(e.Object, args)
+		return &copied
+	case *ast.HashLiteral:
+		copied := *e
+		copied.Keys = make([]ast.Expression, len(e.Keys))
+		copied.Pairs = make(map[ast.Expression]ast.Expression, len(e.Pairs))
+		for i, key := range e.Keys {
+			copied.Keys[i] = substitute(key, args)
+			copied.Pairs[copied.Keys[i]] = substitute(e.Pairs[key], args)
+		}
+		return &copied
+	case *ast.IntegerLiteral:
+		copied := *e
+		return &copied
+	case *ast.FloatLiteral:
+		copied := *e
+		return &copied
+	case *ast.StringLiteral:
+		copied := *e
+		return &copied
+	case *ast.Boolean:
+		copied := *e
+		return &copied
+	}
+	return e
+}
+
+func substituteAll(list []ast.Expression, args map[string]ast.Expression) []ast.Expression {
+	copied := make([]ast.Expression, len(list))
+	for i, e := range list {
+		copied[i] = substitute(e, args)
+	}
+	return copied
+}
+
+// 函数体中的块只含表达式语句
+func substituteBlock(b *ast.BlockStatement, args map[string]ast.Expression) *ast.BlockStatement {
+	if b == nil {
+		return nil
+	}
+	copied := &ast.BlockStatement{Token: b.Token, Statements: make([]ast.Statement, len(b.Statements))}
+	for i, s := range b.Statements {
+		stmt := *s.(*ast.ExpressionStatement)
+		stmt.Expression = substitute(stmt.Expression, args)
+		copied.Statements[i] = &stmt
+	}
+	return copied
+}
@@ -0,0 +1,372 @@
+package optimizer
+
+//语法树优化 在求值或编译之前改写ast.Program，不改变程序的结果、输出和错误
+//  常量折叠   1 + 2 * 3 => 7
+//  分支裁剪   条件为常量的if/elif只保留会执行的分支
+//  !!化简     !!!x => !x，条件中或x本身为布尔值时 !!x => x
+//  函数内联   调用短小的非递归函数时直接代入函数体
+
+import (
+	"math"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/token"
+	"strconv"
+)
+
+// 优化步骤，可按位组合
+type Pass int
+
+const (
+	FoldConstants   Pass = 1 << iota //常量折叠
+	PruneBranches                    //裁剪条件为常量的分支
+	SimplifyNot                      //化简连续的!
+	InlineFunctions                  //内联短小的函数
+
+	AllPasses = FoldConstants | PruneBranches | SimplifyNot | InlineFunctions
+)
+
+// 折叠产生的字符串最长长度，避免语法树过大
+const maxFoldedString = 1024
+
+type optimizer struct {
+	passes Pass
+	inline *inliner
+}
+
+// 按passes改写程序，返回改写后的程序(与传入的是同一个)
+func Optimize(program *ast.Program, passes Pass) *ast.Program {
+	o := &optimizer{passes: passes}
+	if passes&InlineFunctions != 0 {
+		o.inline = newInliner(program)
+	}
+	for i, s := range program.Statements {
+		if o.inline != nil {
+			o.inline.enterStatement(i)
+		}
+		program.Statements[i] = o.statement(s)
+	}
+	return program
+}
+
+func (o *optimizer) enabled(p Pass) bool {
+	return o.passes&p != 0
+}
+
+func (o *optimizer) statement(s ast.Statement) ast.Statement {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		s.Value = o.expression(s.Value)
+	case *ast.ReturnStatement:
+		s.ReturnValue = o.expression(s.ReturnValue)
+	case *ast.ExpressionStatement:
+		s.Expression = o.expression(s.Expression)
+	case *ast.BlockStatement:
+		o.block(s)
+	}
+	return s
+}
+
+func (o *optimizer) block(b *ast.BlockStatement) {
+	if b == nil {
+		return
+	}
+	for i, s := range b.Statements {
+		b.Statements[i] = o.statement(s)
+	}
+}
+
+// 先优化子表达式，再优化当前表达式
+func (o *optimizer) expression(e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		e.Right = o.expression(e.Right)
+		return o.prefix(e)
+
+	case *ast.InfixExpression:
+		e.Left = o.expression(e.Left)
+		e.Right = o.expression(e.Right)
+		if o.enabled(FoldConstants) {
+			return fold(e, e.Left, e.Right, func(left, right object.Object) object.Object {
+				return evaluator.InfixOperation(e.Operator, left, right)
+			})
+		}
+
+	case *ast.IfExpression:
+		e.Condition = o.condition(e.Condition)
+		o.block(e.Consequence)
+		for _, alt := range e.Alternatives {
+			alt.Condition = o.condition(alt.Condition)
+			o.block(alt.Consequence)
+		}
+		o.block(e.LastAlternative)
+		if o.enabled(PruneBranches) {
+			return prune(e)
+		}
+
+	case *ast.FunctionLiteral:
+		if o.inline != nil {
+			o.inline.enterFunction(e)
+			defer o.inline.leaveFunction()
+		}
+		o.block(e.Body)
+
+	case *ast.CallExpression:
+		e.Function = o.expression(e.Function)
+		for i, arg := range e.Arguments {
+			e.Arguments[i] = o.expression(arg)
+		}
+		if o.inline != nil {
+			if body := o.inline.expand(e); body != nil {
+				defer o.inline.leaveCall()
+				return o.expression(body)
+			}
+		}
+
+	case *ast.ArrayLiteral:
+		for i, el := range e.Elements {
+			e.Elements[i] = o.expression(el)
+		}
+
+	case *ast.IndexExpression:
+		e.Left = o.expression(e.Left)
+		e.Index = o.expression(e.Index)
+
+	case *ast.SliceExpression:
+		e.Left = o.expression(e.Left)
+		if e.Start != nil {
+			e.Start = o.expression(e.Start)
+		}
+		if e.End != nil {
+			e.End = o.expression(e.End)
+		}
+		if e.Step != nil {
+			e.Step = o.expression(e.Step)
+		}
+
+	case *ast.PropertyExpression:
+		e.Object = o.expression(e.Object)
+
+	case *ast.HashLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(e.Pairs))
+		for i, key := range e.Keys {
+			value := e.Pairs[key]
+			e.Keys[i] = o.expression(key)
+			pairs[e.Keys[i]] = o.expression(value)
+		}
+		e.Pairs = pairs
+	}
+	return e
+}
+
+// if/elif的条件只关心真假，条件中的!!x可以化简为x
+func (o *optimizer) condition(e ast.Expression) ast.Expression {
+	e = o.expression(e)
+	if o.enabled(SimplifyNot) {
+		if inner, ok := doubleNot(e); ok {
+			return inner
+		}
+	}
+	return e
+}
+
+func (o *optimizer) prefix(e *ast.PrefixExpression) ast.Expression {
+	if o.enabled(SimplifyNot) {
+		if inner, ok := doubleNot(e); ok {
+			//!!x的结果是x的真假，x本身为布尔值时结果就是x
+			if isBoolean(inner) {
+				return inner
+			}
+			//!!!x => !x
+			if inner, ok := inner.(*ast.PrefixExpression); ok && inner.Operator == "!" {
+				return inner
+			}
+		}
+	}
+	if o.enabled(FoldConstants) {
+		return fold(e, e.Right, nil, func(right, _ object.Object) object.Object {
+			return evaluator.PrefixOperation(e.Operator, right)
+		})
+	}
+	return e
+}
+
+// 形如!!x时返回x
+func doubleNot(e ast.Expression) (ast.Expression, bool) {
+	outer, ok := e.(*ast.PrefixExpression)
+	if !ok || outer.Operator != "!" {
+		return nil, false
+	}
+	inner, ok := outer.Right.(*ast.PrefixExpression)
+	if !ok || inner.Operator != "!" {
+		return nil, false
+	}
+	return inner.Right, true
+}
+
+// 表达式的值是否一定是布尔值(或者出错)
+func isBoolean(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.Boolean:
+		return true
+	case *ast.PrefixExpression:
+		return e.Operator == "!"
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "<", ">", "==", "!=":
+			return true
+		case "&&", "||":
+			return isBoolean(e.Left) && isBoolean(e.Right)
+		}
+	}
+	return false
+}
+
+// 操作数都是字面量时在编译期计算，出错的运算保留到运行时报告
+func fold(e ast.Expression, left, right ast.Expression, op func(left, right object.Object) object.Object) ast.Expression {
+	l, ok := literalValue(left)
+	if !ok {
+		return e
+	}
+	var r object.Object
+	if right != nil {
+		if r, ok = literalValue(right); !ok {
+			return e
+		}
+	}
+	if folded, ok := toLiteral(op(l, r), tokenOf(e)); ok {
+		return folded
+	}
+	return e
+}
+
+// 字面量的值
+func literalValue(e ast.Expression) (object.Object, bool) {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: e.Value}, true
+	case *ast.FloatLiteral:
+		return &object.Float{Value: e.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: e.Value}, true
+	case *ast.Boolean:
+		if e.Value {
+			return evaluator.TRUE, true
+		}
+		return evaluator.FALSE, true
+	}
+	return nil, false
+}
+
+// 把运算结果转回字面量，无法表示为字面量时返回false
+func toLiteral(obj object.Object, at token.Token) (ast.Expression, bool) {
+	tok := token.Token{Line: at.Line}
+	switch obj := obj.(type) {
+	case *object.Integer:
+		tok.Type, tok.Literal = token.INT, strconv.FormatInt(obj.Value, 10)
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}, true
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return nil, false
+		}
+		tok.Type, tok.Literal = token.FLOAT, obj.Inspect()
+		return &ast.FloatLiteral{Token: tok, Value: obj.Value}, true
+	case *object.String:
+		if len(obj.Value) > maxFoldedString {
+			return nil, false
+		}
+		tok.Type, tok.Literal = token.STRING, obj.Value
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}, true
+	case *object.Boolean:
+		tok.Type, tok.Literal = token.FALSE, "false"
+		if obj.Value {
+			tok.Type, tok.Literal = token.TRUE, "true"
+		}
+		return &ast.Boolean{Token: tok, Value: obj.Value}, true
+	}
+	return nil, false
+}
+
+func tokenOf(e ast.Expression) token.Token {
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		return e.Token
+	case *ast.InfixExpression:
+		return e.Token
+	}
+	return token.Token{}
+}
+
+// 去掉条件为常量的分支：条件为假的分支不会执行，条件为真的分支之后的分支也不会执行
+func prune(e *ast.IfExpression) ast.Expression {
+	var alternatives []*ast.ElIfExpression
+	for _, alt := range e.Alternatives {
+		truthy, constant := constantCondition(alt.Condition)
+		if !constant {
+			alternatives = append(alternatives, alt)
+			continue
+		}
+		if truthy {
+			e.LastAlternative = alt.Consequence
+			break
+		}
+	}
+	e.Alternatives = alternatives
+
+	//首个条件为假时由下一个分支代替
+	for {
+		truthy, constant := constantCondition(e.Condition)
+		if !constant {
+			return e
+		}
+		if truthy {
+			e.Alternatives, e.LastAlternative = nil, nil
+			break
+		}
+		if len(e.Alternatives) > 0 {
+			next := e.Alternatives[0]
+			e.Condition, e.Consequence, e.Alternatives = next.Condition, next.Consequence, e.Alternatives[1:]
+			continue
+		}
+		if e.LastAlternative == nil {
+			//没有分支会执行，结果为null
+			e.Consequence = &ast.BlockStatement{Token: e.Consequence.Token}
+			break
+		}
+		e.Condition = &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true", Line: tokenLine(e.Condition)}, Value: true}
+		e.Consequence, e.LastAlternative = e.LastAlternative, nil
+		break
+	}
+
+	//只剩一个分支且只有一个表达式时，直接使用该表达式
+	if truthy, _ := constantCondition(e.Condition); truthy && len(e.Consequence.Statements) == 1 {
+		if stmt, ok := e.Consequence.Statements[0].(*ast.ExpressionStatement); ok {
+			return stmt.Expression
+		}
+	}
+	return e
+}
+
+// 条件是否为字面量，以及它的真假
+func constantCondition(e ast.Expression) (truthy, constant bool) {
+	obj, ok := literalValue(e)
+	if !ok {
+		return false, false
+	}
+	return evaluator.IsTruthy(obj), true
+}
+
+func tokenLine(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return e.Token.Line
+	case *ast.FloatLiteral:
+		return e.Token.Line
+	case *ast.StringLiteral:
+		return e.Token.Line
+	case *ast.Boolean:
+		return e.Token.Line
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package optimizer
+
+import (
+	"bytes"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/interpreter"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/parser"
+	"testing"
+)
+
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"-5 + 2", "-3"},
+		{"(1.5 + 1) * 2", "5.0"},
+		{`"mon" + "key"`, "monkey"},
+		{"1 < 2 == true", "true"},
+		{"!5", "false"},
+		{"true && 3", "3"},
+		{"x + (2 * 3)", "(x + 6)"},
+		{"1 / 0", "(1 / 0)"},
+		{"1 + true", "(1 + true)"},
+		{"1.0 / 0", "(1.0 / 0)"},
+		{"let f = fn(a) { a * (10 - 8) };", "let f=fn(a)(a * 2);"},
+		{"[1 + 1, {2 * 2: 3 - 3}[4]]", "[2, ({4:0}[4])]"},
+	}
+
+	for _, tt := range tests {
+		testOptimize(t, tt.input, FoldConstants, tt.expected)
+	}
+}
+
+func TestPruneBranches(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (true) { 1 } else { 2 }", "1"},
+		{"if (false) { 1 } else { 2 }", "2"},
+		{"if (false) { 1 }", "if false "},
+		{"if (0) { 1 } else { 2 }", "1"},
+		{"if (x) { 1 } elif (false) { 2 } elif (y) { 3 } else { 4 }", "if x 1 elif y 3 else 4"},
+		{"if (x) { 1 } elif (true) { 2 } elif (y) { 3 } else { 4 }", "if x 1 else 2"},
+		{"if (false) { 1 } elif (x) { 2 } else { 3 }", "if x 2 else 3"},
+		{"if (false) { 1 } elif (false) { 2 } else { let a = 3; a }", "if true let a=3;a"},
+		{"if (1 > 2) { 1 } else { 2 }", "if (1 > 2) 1 else 2"},
+	}
+
+	for _, tt := range tests {
+		testOptimize(t, tt.input, PruneBranches, tt.expected)
+	}
+
+	testOptimize(t, "if (1 > 2) { 1 } else { 2 }", PruneBranches|FoldConstants, "2")
+}
+
+func TestSimplifyNot(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"!!x", "(!(!x))"},
+		{"!!!x", "(!x)"},
+		{"!!!!x", "(!(!x))"},
+		{"!!(a < b)", "(a < b)"},
+		{"!!true", "true"},
+		{"if (!!x) { 1 }", "if x 1"},
+		{"if (x) { 1 } elif (!!y) { 2 }", "if x 1 elif y 2"},
+		{"!!(a == b) && !!c", "((a == b) && (!(!c)))"},
+	}
+
+	for _, tt := range tests {
+		testOptimize(t, tt.input, SimplifyNot, tt.expected)
+	}
+}
+
+func TestInlineFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let sq = fn(x) { x * x }; sq(3)", "let sq=fn(x)(x * x);(3 * 3)"},
+		{"let add = fn(a, b) { return a + b; }; add(1, 2)", "let add=fn(a,b)return (a + b);;(1 + 2)"},
+		{"let k = 2; let f = fn(x) { x * k }; f(k)", "let k=2;let f=fn(x)(x * k);(k * k)"},
+		{"let f = fn(x) { x }; let g = fn(y) { f(y) + 1 }; g(1)", "let f=fn(x)x;let g=fn(y)(y + 1);(1 + 1)"},
+		{"let f = fn(x) { len(x) }; f(\"ab\")", "let f=fn(x)len(x);len(ab)"},
+		//递归
+		{"let f = fn(x) { f(x) }; f(1)", "let f=fn(x)f(x);f(1)"},
+		//相互调用时不会无限展开
+		{"let f = fn(x) { g(x) }; let g = fn(x) { f(x) }; g(1)", "let f=fn(x)g(x);let g=fn(x)g(x);g(1)"},
+		//调用在定义之前
+		{"f(1); let f = fn(x) { x };", "f(1)let f=fn(x)x;"},
+		//实参可能有副作用
+		{"let f = fn(x) { x }; f(put(1))", "let f=fn(x)x;f(put(1))"},
+		//实参可能尚未定义
+		{"let f = fn(x) { x }; f(y)", "let f=fn(x)x;f(y)"},
+		//名称被重新定义
+		{"let f = fn(x) { x }; let f = 1; f(1)", "let f=fn(x)x;let f=1;f(1)"},
+		//f的函数体中的k可能被调用处的形参遮蔽，不内联f
+		{"let k = 1; let f = fn() { k }; let g = fn(k) { f() }; g(2)", "let k=1;let f=fn()k;let g=fn(k)f();f()"},
+		//函数体不止一个表达式
+		{"let f = fn(x) { let y = x; y }; f(1)", "let f=fn(x)let y=x;y;f(1)"},
+		//函数体过长
+		{"let f = fn(x) { [x, x, x, x, x, x, x, x, x, x, x, x, x, x, x, x, x] }; f(1)", "let f=fn(x)[x, x, x, x, x, x, x, x, x, x, x, x, x, x, x, x, x];f(1)"},
+		//实参个数不符
+		{"let f = fn(x) { x }; f(1, 2)", "let f=fn(x)x;f(1, 2)"},
+		//外层函数的形参可以作为实参
+		{"let f = fn(x) { x + 1 }; let g = fn(n) { f(n) }; g(1)", "let f=fn(x)(x + 1);let g=fn(n)(n + 1);(1 + 1)"},
+	}
+
+	for _, tt := range tests {
+		testOptimize(t, tt.input, InlineFunctions, tt.expected)
+	}
+
+	testOptimize(t, "let sq = fn(x) { x * x }; sq(3) + sq(4)", AllPasses, "let sq=fn(x)(x * x);25")
+}
+
+// 开启任意步骤都不改变程序的结果和输出
+func TestSemanticsPreserved(t *testing.T) {
+	inputs := []string{
+		"1 + 2 * 3 - 4 / 2",
+		"(1.5 + 1) * 2 - 0.5",
+		`"a" + "b" + "c"`,
+		"1 < 2 == true",
+		"!0 == !!false",
+		"1 / 0",
+		"1 + true",
+		"1.0 / 0 > 1000000",
+		`[1 + 1, {2 * 2: 3 - 3}[4], "ab"[0:1]]`,
+		`{1 + 1: "x", 2: "y"}`,
+		"if (true) { 1 } else { 2 }",
+		"if (false) { 1 }",
+		"if (false) { 1 } elif (false) { 2 } else { let a = 3; a }; a",
+		"let x = 5; if (x > 1) { put(1) } elif (false) { put(2) } elif (true) { put(3) } else { put(4) }",
+		"if (1 > 2) { put(1) } elif (2 > 1) { put(2) }",
+		"let x = 5; !!x",
+		"let x = null_value; !!x",
+		"let x = first([]); [!!x, !!!x, if (!!x) { 1 } else { 2 }]",
+		"let f = fn(a, b) { a * b }; f(3, 4) + f(5, 6)",
+		"let f = fn(x) { put(x); x }; let g = fn(y) { f(y) + 1 }; g(1) + g(2)",
+		"let f = fn(x) { x / 0 }; f(1)",
+		"let f = fn(x) { y + x }; f(1); let y = 2;",
+		"let f = fn(x) { y + x }; let y = 2; f(1)",
+		"let f = fn(x) { x }; f(put(1)) + f(put(2))",
+		"let f = fn(x) { x }; f(missing)",
+		"let f = fn(x) { f(x - 1) }; let g = fn() { 1 }; g()",
+		"let k = 1; let f = fn() { k }; let g = fn(k) { f() + k }; g(10)",
+		"let f = fn(k) { {k: 1, k: 2} }; f(3)",
+		"let max = fn(a, b) { if (a > b) { a } else { b } }; max(3, 7) + max(10, 2)",
+		"let twice = fn(f, x) { f(f(x)) }; let inc = fn(x) { x + 1 }; twice(inc, 1)",
+		"let double = fn(x) { x * 2 }; map([1, 2, 3], fn(n) { double(n) })",
+		"let pick = fn(x) { if (x) { 1 } elif (!x) { 2 } }; [pick(true), pick(false), pick(0)]",
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10)",
+	}
+
+	passes := []Pass{FoldConstants, PruneBranches, SimplifyNot, InlineFunctions, AllPasses}
+	for _, backend := range []interpreter.Backend{interpreter.TreeWalking, interpreter.Bytecode} {
+		for _, input := range inputs {
+			expected := run(t, backend, parse(t, input))
+			for _, p := range passes {
+				got := run(t, backend, Optimize(parse(t, input), p))
+				if got != expected {
+					t.Errorf("%s: %s with passes %b changed the result.\nwant=%s\ngot=%s", backend, input, p, expected, got)
+				}
+			}
+		}
+	}
+}
+
+// 重复优化结果不变
+func TestOptimizeIdempotent(t *testing.T) {
+	input := "let sq = fn(x) { x * x }; let f = fn(n) { if (!!n) { sq(n) } else { 1 + 2 } }; f(sq(2))"
+	once := Optimize(parse(t, input), AllPasses).String()
+	twice := Optimize(Optimize(parse(t, input), AllPasses), AllPasses).String()
+	if once != twice {
+		t.Errorf("optimizing twice differs.\nonce=%s\ntwice=%s", once, twice)
+	}
+}
+
+func testOptimize(t *testing.T, input string, passes Pass, expected string) {
+	t.Helper()
+	if got := Optimize(parse(t, input), passes).String(); got != expected {
+		t.Errorf("Optimize(%q, %b) wrong.\ngot=%q\nwant=%q", input, passes, got, expected)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+// 执行程序，返回输出和结果
+func run(t *testing.T, backend interpreter.Backend, program *ast.Program) string {
+	var out bytes.Buffer
+	in := interpreter.New(backend)
+	evaluator.SetOutput(in.Env(), &out)
+	result := in.Run(program)
+	if result == nil {
+		return out.String() + "<nil>"
+	}
+	return out.String() + string(result.Type()) + " " + result.Inspect()
+}
@@ -29,7 +29,8 @@ type Parser struct {
 	curToken  token.Token //当前词法单元
 	peekToken token.Token //当前词法单元的下一位
 
-	errors []string //错误集合
+	errors      []string      //错误集合
+	errorTokens []token.Token //每个错误对应的词法单元，用于定位
 
 	//添加两个解析函数的映射
 	prefixParseFns map[token.TokenType]prefixParseFn
@@ -64,6 +65,7 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,     //*
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 // 实例化语法分析器
@@ -81,6 +83,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn) //初始化映射
 	p.registerPrefix(token.IDENT, p.parseIdentifier)           //注册ident标识符相关的解析函数（parseIdentifier）
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)         //注册integer整形相关的解析函数（parseIntegerLiteral）
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)         //注册浮点数的解析函数
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)      //注册！非的解析函数
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)     //注册-负号的解析函数
 
@@ -107,6 +110,7 @@ func New(l *lexer.Lexer) *Parser {
 
 	//解析函数字面量 前缀表达式
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 
 	//解析调用函数表达式
 	p.registerInfix(token.LPAREN, p.parseCallExpression) //以左括号为中心，注册一个中缀表达式
@@ -126,6 +130,9 @@ func New(l *lexer.Lexer) *Parser {
 
 	//解析哈希表
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+
+	//解析属性访问
+	p.registerInfix(token.DOT, p.parsePropertyExpression)
 	return p
 }
 
@@ -157,7 +164,11 @@ func (p *Parser) ParseProgram() *ast.Program {
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		//解析失败时返回nil接口，不返回值为nil的*ast.LetStatement，以免出现在语句列表中
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
 	default:
@@ -197,10 +208,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	//6.检测分号（;）     处理语句末尾的分号（;）
-	//检测当前token（curtoken）是否是分号（;）
-	if !p.curTokenIs(token.SEMICOLON) { //是，则不需要移动
-		p.nextToken() //不是，则peektoken、curtoken后移一位，直接解析下一句
+	//6.检测分号（;）     处理语句末尾的分号（;），分号可以省略
+	//检测下一个token（peektoken）是否是分号（;），是则后移一位，不是则不移动，避免跳过下一句的开头
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
 	}
 	//运行后：stmt.token=let curtoken=";" peektoken = ""  stmt.Name=&{Token: IDENT("x"), Value: "x"} stmt.
 
@@ -218,7 +229,8 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	//TODO:跳过对表达式的处理，直接遇到分号
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	//分号可以省略，如{ return x }，遇到块或输入的结尾时停止
+	for !p.curTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
 		p.nextToken()
 	}
 
@@ -252,9 +264,19 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// 与Errors()一一对应，每个错误所在的词法单元
+func (p *Parser) ErrorTokens() []token.Token {
+	return p.errorTokens
+}
+
+func (p *Parser) addError(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.errorTokens = append(p.errorTokens, tok)
+}
+
 func (p *Parser) peekErrors(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be \"%s\",got=%s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 // 辅助函数
@@ -354,7 +376,21 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+// 解析函数：解析浮点数
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 	lit.Value = value
@@ -364,7 +400,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 // 将格式化错误信息添加到语法分析器的errors字段
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
 
 // 解析前缀表达式
@@ -443,8 +479,9 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	//中间选项
 	for p.peekTokenIs(token.ELIF) {
 		p.nextToken()
-		elif := p.parseElIfExpression()
-		expression.Alternatives = append(expression.Alternatives, elif)
+		if elif := p.parseElIfExpression(); elif != nil {
+			expression.Alternatives = append(expression.Alternatives, elif)
+		}
 	}
 
 	//else部分 最后选项
@@ -526,6 +563,22 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+// 解析宏字面量 macro(参数) { 宏体 }
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
 // 解析函数字面量里的参数
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
@@ -591,6 +644,9 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		if p.peekTokenIs(end) { //允许末尾多一个逗号，如[1, 2,]
+			break
+		}
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -602,19 +658,67 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
-// 解析索引
+// 解析索引 a[i]，遇到:则转为切片 a[start:end:step]
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
+
+	if p.peekTokenIs(token.COLON) { //省略起始位置 a[:end]
+		return p.parseSliceExpression(tok, left, nil)
+	}
 
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	index := p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, index)
+	}
 
+	exp := &ast.IndexExpression{Token: tok, Left: left, Index: index}
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
 	return exp
 }
 
+// 解析切片 此时peekToken为第一个:
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+	p.nextToken() //curToken=:
+
+	//结束位置
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
+
+	//步长
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			exp.Step = p.parseExpression(LOWEST)
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return exp
+}
+
+// 解析属性访问 obj.name
+func (p *Parser) parsePropertyExpression(left ast.Expression) ast.Expression {
+	exp := &ast.PropertyExpression{Token: p.curToken, Object: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 // 解析哈希表
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
@@ -632,6 +736,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST) //取键值对的值
 
 		hash.Pairs[key] = value //写入键值对
+		hash.Keys = append(hash.Keys, key)
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
 		}
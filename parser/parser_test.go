@@ -178,6 +178,37 @@ func TestIntergerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "3.25;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements.got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement.got=%T", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral.got=%T", stmt.Expression)
+	}
+
+	if literal.Value != 3.25 {
+		t.Fatalf("literal.Value not %f.got=%f", 3.25, literal.Value)
+	}
+
+	if literal.TokenLiteral() != "3.25" {
+		t.Errorf("literal.TokenLiteral not %s.got=%s", "3.25", literal.TokenLiteral())
+	}
+}
+
 // 解析前缀运算符
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
@@ -413,7 +444,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		},
 		{
 			"add(a, b, 1, 2*3, 4+5, add(6,7*8))",
-			"add(a,b,1,(2 * 3),(4 + 5),add(6,(7 * 8)))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
 		},
 		{
 			"add(a+b+c*d/f+g)",
@@ -427,6 +458,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a[1:][0] * b[::-1]",
+			"(((a[1:])[0]) * (b[::(-1)]))",
+		},
+		{
+			"a.b.c + d.e(1)[0]",
+			"(((a.b).c) + ((d.e)(1)[0]))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -606,6 +645,46 @@ func TestFunctionLiteralParseing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := "macro(x, y) { x + y; }"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n", len(macro.Parameters))
+	}
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
 // 调用表达式测试
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1,2*3,4+5);"
@@ -688,6 +767,78 @@ func TestParsingArrayLiterals(t *testing.T) {
 
 }
 
+// let和return之后的分号可以省略，省略时不会跳过下一条语句
+func TestOptionalSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1\nput(a)", "let a=1;put(a)"},
+		{"let f = fn() { return 1 }; f()", "let f=fn()return 1;;f()"},
+		{"let f = fn() { let a = 1 }", "let f=fn()let a=1;;"},
+		{"return x", "return x;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+// 每个错误都记录了所在的词法单元
+func TestErrorTokens(t *testing.T) {
+	input := "let a = 1;\nlet = 2;\nput(1 +);\nlet b = 99999999999999999999;"
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	expected := []struct {
+		line    int
+		literal string
+	}{{2, "="}, {2, "="}, {3, ")"}, {3, ";"}, {4, "99999999999999999999"}}
+	if len(p.ErrorTokens()) != len(p.Errors()) || len(p.Errors()) != len(expected) {
+		t.Fatalf("wrong number of errors. errors=%q tokens=%v", p.Errors(), p.ErrorTokens())
+	}
+	for i, tok := range p.ErrorTokens() {
+		if tok.Line != expected[i].line || tok.Literal != expected[i].literal {
+			t.Errorf("error %d (%s) at wrong token. got=%+v", i, p.Errors()[i], tok)
+		}
+	}
+}
+
+// 列表末尾可以多一个逗号
+func TestTrailingComma(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2,]", "[1, 2]"},
+		{"f(a,\n  b,\n)", "f(a, b)"},
+		{"{1: 2,}", "{1:2}"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	for _, input := range []string{"[,]", "f(1,,)"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 // 测试索引
 func TestParsingIndexExpression(t *testing.T) {
 	input := "myArray[1+1]"
@@ -712,6 +863,66 @@ func TestParsingIndexExpression(t *testing.T) {
 	}
 }
 
+// 测试切片
+func TestParsingSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::2]", "(a[::2])"},
+		{"a[1:2:3]", "(a[1:2:3])"},
+		{"a[1+1:-1]", "(a[(1 + 1):(-1)])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q,got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+// 测试属性访问
+func TestParsingPropertyExpression(t *testing.T) {
+	input := "re.match"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.PropertyExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.PropertyExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Object, "re") {
+		return
+	}
+	if !testIdentifier(t, exp.Property, "match") {
+		return
+	}
+
+	p = New(lexer.New("a.1"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected error for non-identifier property")
+	}
+}
+
 // 测试哈希表结构
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one":1,"two":2,"three":3}`
@@ -731,6 +942,10 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
+	if hash.String() != "{one:1,two:2,three:3}" {
+		t.Errorf("hash.String() not in source order. got=%q", hash.String())
+	}
+
 	expected := map[string]int64{
 		"one":   1,
 		"two":   2,
@@ -0,0 +1,97 @@
+package repl
+
+//终端颜色 输出不是终端或设置了NO_COLOR环境变量时不使用颜色
+
+import (
+	"io"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/token"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ANSI颜色
+const (
+	styleReset    = "\x1b[0m"
+	styleKeyword  = "\x1b[1;35m"
+	styleString   = "\x1b[32m"
+	styleNumber   = "\x1b[33m"
+	styleConstant = "\x1b[35m" //true、false、null
+	styleBuiltin  = "\x1b[36m"
+	styleFunction = "\x1b[34m"
+	styleError    = "\x1b[1;31m"
+	styleIllegal  = "\x1b[4;31m"
+	styleDim      = "\x1b[2m"
+)
+
+// 判断是否向out输出颜色
+func useColor(out io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := out.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// 为文本加上颜色，enabled为false时原样返回
+func paint(enabled bool, style, text string) string {
+	if !enabled || style == "" {
+		return text
+	}
+	return style + text + styleReset
+}
+
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// 去掉颜色后显示的字符数
+func visibleLen(s string) int {
+	return utf8.RuneCountInString(ansiPattern.ReplaceAllString(s, ""))
+}
+
+// 内置函数名与模块名，高亮时使用
+var builtinNames = func() map[string]bool {
+	names := map[string]bool{}
+	for _, name := range evaluator.BuiltinNames() {
+		names[name] = true
+	}
+	return names
+}()
+
+// 按词法单元为输入着色，词法单元之间的空白原样保留
+func highlight(line string) string {
+	var out strings.Builder
+	l := lexer.New(line)
+	last := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		out.WriteString(line[last:tok.Offset])
+		out.WriteString(paint(true, tokenStyle(tok), line[tok.Offset:tok.End]))
+		last = tok.End
+	}
+	out.WriteString(line[last:])
+	return out.String()
+}
+
+func tokenStyle(tok token.Token) string {
+	switch tok.Type {
+	case token.FUNCTION, token.MACRO, token.LET, token.IF, token.ELIF, token.ELSE, token.RETURN:
+		return styleKeyword
+	case token.TRUE, token.FALSE:
+		return styleConstant
+	case token.STRING:
+		return styleString
+	case token.INT, token.FLOAT:
+		return styleNumber
+	case token.IDENT:
+		if builtinNames[tok.Literal] {
+			return styleBuiltin
+		}
+	case token.ILLEGAL:
+		return styleIllegal
+	}
+	return ""
+}
@@ -0,0 +1,192 @@
+package repl
+
+//以:开头的REPL命令，用于查看和管理当前会话
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/interpreter"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/parser"
+	"monkey_Interpreter/token"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// REPL会话
+type session struct {
+	interp  *interpreter.Interpreter
+	out     io.Writer
+	color   bool //out为终端时使用颜色
+	printer *printer
+	inputs  []string //已成功执行的输入，供:save使用
+}
+
+func newSession(out io.Writer, backend interpreter.Backend) *session {
+	color := useColor(out)
+	return &session{
+		interp:  interpreter.New(backend),
+		out:     out,
+		color:   color,
+		printer: &printer{color: color, width: prettyWidth},
+	}
+}
+
+type command struct {
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+var commands map[string]command
+
+// 命令中会调用:help，放在init中赋值以避免初始化循环
+func init() {
+	commands = map[string]command{
+		"help":   {":help", "list the commands", (*session).help},
+		"env":    {":env", "list the bindings in the environment", (*session).listEnv},
+		"type":   {":type <expr>", "evaluate expr and print its type", (*session).printType},
+		"ast":    {":ast <expr>", "print the syntax tree of expr", (*session).printAST},
+		"tokens": {":tokens <expr>", "print the tokens of expr", (*session).printTokens},
+		"load":   {":load <file>", "run a file in the current session", (*session).load},
+		"save":   {":save <file>", "write the inputs of this session that ran without errors to file", (*session).save},
+		"reset":  {":reset", "clear the environment and the saved inputs", (*session).reset},
+		"time":   {":time <expr>", "evaluate expr and print how long it took", (*session).time},
+	}
+}
+
+// 执行一条命令，line为完整的输入行
+func (s *session) runCommand(line string) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command: :%s (type :help for a list)\n", name)
+		return
+	}
+	cmd.run(s, strings.TrimSpace(arg))
+}
+
+// 解析并执行一段输入，输出结果
+func (s *session) eval(input string) {
+	if program, ok := s.parse(input); ok {
+		s.print(s.run(input, program))
+	}
+}
+
+// 在会话中执行，没有出错时记录输入
+// 出错的输入不保存，否则:save写出的脚本无法重新执行
+func (s *session) run(input string, program *ast.Program) object.Object {
+	result := s.interp.Run(program)
+	if _, isErr := result.(*object.Error); !isErr {
+		s.inputs = append(s.inputs, input)
+	}
+	return result
+}
+
+// 解析输入，出错时输出错误信息
+func (s *session) parse(input string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors(), s.color)
+		return nil, false
+	}
+	return program, true
+}
+
+// 输出求值结果，let语句等没有结果时不输出
+func (s *session) print(obj object.Object) {
+	if obj != nil {
+		io.WriteString(s.out, s.printer.format(obj))
+		io.WriteString(s.out, "\n")
+	}
+}
+
+func (s *session) help(arg string) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(s.out, "%-16s %s\n", commands[name].usage, commands[name].help)
+	}
+}
+
+// :env 每行一个绑定
+func (s *session) listEnv(arg string) {
+	line := &printer{color: s.color, width: math.MaxInt}
+	env := s.interp.Env()
+	for _, name := range env.Names() {
+		obj, _ := env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, line.value(obj, 0, 0))
+	}
+}
+
+func (s *session) printType(arg string) {
+	if program, ok := s.parse(arg); ok {
+		if obj := s.run(arg, program); obj != nil {
+			fmt.Fprintln(s.out, obj.Type())
+		}
+	}
+}
+
+func (s *session) printAST(arg string) {
+	if program, ok := s.parse(arg); ok {
+		dumpNode(s.out, program)
+	}
+}
+
+func (s *session) printTokens(arg string) {
+	l := lexer.New(arg)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%-10s %q\n", tok.Type, tok.Literal)
+	}
+}
+
+func (s *session) load(arg string) {
+	data, err := os.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintf(s.out, "cannot load: %s\n", err)
+		return
+	}
+	s.eval(string(data))
+}
+
+func (s *session) save(arg string) {
+	if arg == "" {
+		fmt.Fprintln(s.out, "usage: :save <file>")
+		return
+	}
+	var content string
+	if len(s.inputs) > 0 {
+		content = strings.Join(s.inputs, "\n") + "\n"
+	}
+	if err := os.WriteFile(arg, []byte(content), 0644); err != nil {
+		fmt.Fprintf(s.out, "cannot save: %s\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d inputs to %s\n", len(s.inputs), arg)
+}
+
+func (s *session) reset(arg string) {
+	s.interp = interpreter.New(s.interp.Backend())
+	s.inputs = nil
+}
+
+func (s *session) time(arg string) {
+	program, ok := s.parse(arg)
+	if !ok {
+		return
+	}
+	start := time.Now()
+	result := s.run(arg, program)
+	elapsed := time.Since(start)
+	s.print(result)
+	fmt.Fprintf(s.out, "time: %s\n", elapsed)
+}
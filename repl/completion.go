@@ -0,0 +1,44 @@
+package repl
+
+import (
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/token"
+	"sort"
+	"strings"
+)
+
+// 补全候选：关键字、内置函数及模块成员、环境中已绑定的标识符
+func completions(env *object.Environment, prefix string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, names := range [][]string{token.Keywords(), evaluator.BuiltinNames(), env.Names()} {
+		for _, name := range names {
+			if strings.HasPrefix(name, prefix) && !seen[name] {
+				seen[name] = true
+				result = append(result, name)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// 可以出现在待补全单词中的字符，包含.以补全模块成员
+func isWordRune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r == '.'
+}
+
+// 候选项的最长公共前缀
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
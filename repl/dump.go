@@ -0,0 +1,61 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey_Interpreter/ast"
+	"reflect"
+	"strings"
+)
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// 以缩进的树形结构打印语法树，每行一个节点，简单字段（如Operator、Value）写在节点名之后
+func dumpNode(out io.Writer, node ast.Node) {
+	dumpValue(out, reflect.ValueOf(node), 0, "")
+}
+
+func dumpValue(out io.Writer, v reflect.Value, depth int, label string) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return
+	}
+
+	elem := v.Elem()
+	var attrs []string
+	var children []func()
+	for i := 0; i < elem.NumField(); i++ {
+		field, value := elem.Type().Field(i), elem.Field(i)
+		switch {
+		case field.Name == "Token":
+		case field.Type.Implements(nodeType):
+			children = append(children, func() { dumpValue(out, value, depth+1, field.Name+": ") })
+		case field.Type.Kind() == reflect.Slice:
+			for j := 0; j < value.Len(); j++ {
+				item, itemLabel := value.Index(j), fmt.Sprintf("%s[%d]: ", field.Name, j)
+				children = append(children, func() { dumpValue(out, item, depth+1, itemLabel) })
+			}
+		case field.Type.Kind() == reflect.Map:
+			//哈希表的键值对按Keys中的源码顺序输出
+		default:
+			attrs = append(attrs, fmt.Sprintf(" %s=%#v", field.Name, value.Interface()))
+		}
+	}
+	if hash, ok := v.Interface().(*ast.HashLiteral); ok {
+		children = nil
+		for j, key := range hash.Keys {
+			key, value, n := key, hash.Pairs[key], j
+			children = append(children, func() {
+				dumpValue(out, reflect.ValueOf(key), depth+1, fmt.Sprintf("Key[%d]: ", n))
+				dumpValue(out, reflect.ValueOf(value), depth+1, fmt.Sprintf("Value[%d]: ", n))
+			})
+		}
+	}
+
+	fmt.Fprintf(out, "%s%s%s%s\n", strings.Repeat("  ", depth), label, elem.Type().Name(), strings.Join(attrs, ""))
+	for _, child := range children {
+		child()
+	}
+}
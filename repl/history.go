@@ -0,0 +1,91 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxHistory = 1000 //最多保留的历史记录条数
+
+// 输入历史 每行一条记录，追加写入文件以便下次启动时恢复
+type history struct {
+	entries []string
+	file    *os.File //为nil时不保存到文件
+}
+
+// 历史文件路径 优先使用环境变量MONKEY_HISTORY，否则为用户目录下的.monkey_history
+func historyPath() string {
+	if path := os.Getenv("MONKEY_HISTORY"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".monkey_history")
+}
+
+// 从文件加载历史记录，文件无法读写时只在内存中保留
+func loadHistory(path string) *history {
+	h := &history{}
+	if path == "" {
+		return h
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" {
+				h.entries = append(h.entries, line)
+			}
+		}
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if len(h.entries) > maxHistory { //记录过多时重写文件，只保留最近的部分
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flag, 0600)
+	if err != nil {
+		return h
+	}
+	h.file = file
+	if flag&os.O_TRUNC != 0 {
+		h.save(h.entries...)
+	}
+	return h
+}
+
+// 添加一条记录，忽略空行和与上一条相同的记录
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[1:]
+	}
+	h.save(line)
+}
+
+func (h *history) save(lines ...string) {
+	if h.file == nil {
+		return
+	}
+	w := bufio.NewWriter(h.file)
+	for _, line := range lines {
+		w.WriteString(line + "\n")
+	}
+	w.Flush()
+}
+
+func (h *history) close() {
+	if h.file != nil {
+		h.file.Close()
+	}
+}
@@ -0,0 +1,72 @@
+package repl
+
+import (
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/token"
+)
+
+// 出现在行尾时说明表达式还没有写完的词法单元
+var continuationTokens = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.COMMA:    true,
+	token.LT:       true,
+	token.GT:       true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.AND:      true,
+	token.OR:       true,
+	token.COLON:    true,
+	token.DOT:      true,
+	token.FUNCTION: true,
+	token.MACRO:    true,
+	token.LET:      true,
+	token.IF:       true,
+	token.ELIF:     true,
+	token.ELSE:     true,
+}
+
+// 判断输入是否还没有结束：括号或字符串未闭合，或以运算符结尾
+// 多余的右括号视为已结束，交给语法分析器报错
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+
+	if depth > 0 || unterminatedString(input) {
+		return true
+	}
+	return continuationTokens[last.Type]
+}
+
+// 是否有未闭合的字符串，规则与lexer.readString一致
+// 字符串外的//开始行注释，注释中的引号不算
+func unterminatedString(input string) bool {
+	inString := false
+	for i := 0; i < len(input); i++ {
+		switch {
+		case input[i] == '"':
+			inString = !inString
+		case inString && input[i] == '\\' && i+1 < len(input):
+			i++ //跳过被转义的字符
+		case !inString && input[i] == '/' && i+1 < len(input) && input[i+1] == '/':
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		}
+	}
+	return inString
+}
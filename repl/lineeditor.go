@@ -0,0 +1,327 @@
+package repl
+
+//行编辑 输入输出均为终端时进入raw模式，支持光标移动、历史记录、Tab补全与语法高亮
+//否则退化为按行读取，便于管道输入和测试
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// 按下Ctrl-C时返回，REPL丢弃尚未结束的输入
+var errInterrupt = errors.New("interrupt")
+
+// 读取一行输入，输入结束时返回io.EOF
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	Close()
+}
+
+// 根据输入输出是否为终端选择读取方式
+// highlight为nil时不高亮输入
+func newLineReader(in io.Reader, out io.Writer, complete func(prefix string) []string, highlight func(line string) string) lineReader {
+	inFile, ok := in.(*os.File)
+	if !ok || !term.IsTerminal(int(inFile.Fd())) {
+		return newPlainReader(in, out)
+	}
+	if outFile, ok := out.(*os.File); !ok || !term.IsTerminal(int(outFile.Fd())) {
+		return newPlainReader(in, out)
+	}
+	editor := newLineEditor(inFile, out, loadHistory(historyPath()), complete)
+	editor.highlight = highlight
+	return &terminalReader{fd: int(inFile.Fd()), editor: editor}
+}
+
+// 非终端输入 直接按行读取
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func newPlainReader(in io.Reader, out io.Writer) *plainReader {
+	return &plainReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+func (r *plainReader) Close() {}
+
+// 终端输入 每读一行切换到raw模式，读完后恢复，以免影响求值时的输出
+type terminalReader struct {
+	fd       int
+	editor   *lineEditor
+	fallback *plainReader //无法进入raw模式时使用
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	if r.fallback != nil {
+		return r.fallback.ReadLine(prompt)
+	}
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		r.fallback = newPlainReader(r.editor.in, r.editor.out)
+		return r.fallback.ReadLine(prompt)
+	}
+	defer term.Restore(r.fd, state)
+	return r.editor.readLine(prompt)
+}
+
+func (r *terminalReader) Close() {
+	r.editor.history.close()
+}
+
+// 控制键
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+	keyDelete    = 0xE000 //Delete键没有对应的控制字符，使用私有区码点表示
+)
+
+// 行编辑器 从in读取按键，在out上重绘当前行
+type lineEditor struct {
+	in        *bufio.Reader
+	out       io.Writer
+	history   *history
+	complete  func(prefix string) []string
+	highlight func(line string) string //为nil时原样显示
+
+	prompt    string
+	buf       []rune
+	pos       int    //光标位置
+	histIndex int    //正在浏览的历史记录，等于len(entries)时为当前输入
+	saved     string //浏览历史前的当前输入
+}
+
+func newLineEditor(in io.Reader, out io.Writer, h *history, complete func(prefix string) []string) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out, history: h, complete: complete}
+}
+
+// 读取一行，回车时返回
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	e.prompt, e.buf, e.pos = prompt, nil, 0
+	e.histIndex, e.saved = len(e.history.entries), ""
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if r == keyEscape {
+			r = e.readEscape()
+		}
+
+		switch r {
+		case keyEnter, '\n':
+			e.pos = len(e.buf)
+			e.refresh()
+			fmt.Fprint(e.out, "\r\n")
+			line := string(e.buf)
+			e.history.add(line)
+			return line, nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupt
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case keyDelete:
+			e.deleteAt(e.pos)
+		case keyBackspace, keyCtrlH:
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.buf)
+		case keyCtrlB:
+			if e.pos > 0 {
+				e.pos--
+			}
+		case keyCtrlF:
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case keyCtrlK:
+			e.buf = e.buf[:e.pos]
+		case keyCtrlU:
+			e.buf = append([]rune{}, e.buf[e.pos:]...)
+			e.pos = 0
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+			e.pos = start
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP:
+			e.browseHistory(-1)
+		case keyCtrlN:
+			e.browseHistory(1)
+		case keyTab:
+			e.completeWord()
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+		e.refresh()
+	}
+}
+
+// 解析方向键等转义序列，转为等价的控制键，无法识别时返回0
+func (e *lineEditor) readEscape() rune {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+	var params []rune
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if r >= 0x40 && r <= 0x7e { //序列的结束字符
+			break
+		}
+		params = append(params, r)
+	}
+
+	switch r {
+	case 'A':
+		return keyCtrlP
+	case 'B':
+		return keyCtrlN
+	case 'C':
+		return keyCtrlF
+	case 'D':
+		return keyCtrlB
+	case 'H':
+		return keyCtrlA
+	case 'F':
+		return keyCtrlE
+	case '~':
+		switch string(params) {
+		case "1", "7":
+			return keyCtrlA
+		case "4", "8":
+			return keyCtrlE
+		case "3":
+			return keyDelete
+		}
+	}
+	return 0
+}
+
+// 重绘当前行，并把光标移回编辑位置
+func (e *lineEditor) refresh() {
+	line := string(e.buf)
+	if e.highlight != nil {
+		line = e.highlight(line)
+	}
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, line)
+	if n := len(e.buf) - e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+func (e *lineEditor) insert(runes []rune) {
+	tail := append(runes, e.buf[e.pos:]...)
+	e.buf = append(e.buf[:e.pos], tail...)
+	e.pos += len(runes)
+}
+
+func (e *lineEditor) deleteAt(i int) {
+	if i < len(e.buf) {
+		e.buf = append(e.buf[:i], e.buf[i+1:]...)
+	}
+}
+
+// 上下翻阅历史记录，step为-1向前，1向后
+func (e *lineEditor) browseHistory(step int) {
+	entries := e.history.entries
+	next := e.histIndex + step
+	if next < 0 || next > len(entries) {
+		return
+	}
+	if e.histIndex == len(entries) {
+		e.saved = string(e.buf)
+	}
+	e.histIndex = next
+	if next == len(entries) {
+		e.buf = []rune(e.saved)
+	} else {
+		e.buf = []rune(entries[next])
+	}
+	e.pos = len(e.buf)
+}
+
+// 补全光标前的单词 唯一候选时直接补全，多个候选时补全公共前缀，无法继续补全时列出候选项
+func (e *lineEditor) completeWord() {
+	start := e.pos
+	for start > 0 && isWordRune(e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+	if prefix == "" || e.complete == nil {
+		return
+	}
+
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+	if common := commonPrefix(candidates); len(common) > len(prefix) {
+		e.insert([]rune(common[len(prefix):]))
+		return
+	}
+	fmt.Fprint(e.out, "\r\n")
+	for i, c := range candidates {
+		if i > 0 {
+			fmt.Fprint(e.out, "  ")
+		}
+		fmt.Fprint(e.out, c)
+	}
+	fmt.Fprint(e.out, "\r\n")
+}
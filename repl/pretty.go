@@ -0,0 +1,98 @@
+package repl
+
+//按类型着色并格式化求值结果 过长的数组、哈希表分行缩进显示，元素过多时截断
+
+import (
+	"fmt"
+	"monkey_Interpreter/object"
+	"strconv"
+	"strings"
+)
+
+const (
+	prettyWidth    = 80  //超过该宽度的数组、哈希表分行显示
+	prettyMaxItems = 100 //数组、哈希表最多显示的元素个数
+	prettyMaxDepth = 32  //最大嵌套层数
+)
+
+type printer struct {
+	color bool
+	width int
+}
+
+// 格式化顶层的值，字符串不加引号
+func (p *printer) format(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return paint(p.color, styleString, str.Value)
+	}
+	return p.value(obj, 0, 0)
+}
+
+// 格式化错误信息
+func (p *printer) error(message string) string {
+	return paint(p.color, styleError, "ERROR:") + " " + message
+}
+
+// indent为值所在行的缩进，分行显示时元素比它多缩进两格
+func (p *printer) value(obj object.Object, indent, depth int) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return paint(p.color, styleString, strconv.Quote(obj.Value))
+	case *object.Integer, *object.Float:
+		return paint(p.color, styleNumber, obj.Inspect())
+	case *object.Boolean, *object.Null:
+		return paint(p.color, styleConstant, obj.Inspect())
+	case *object.Function, *object.Closure:
+		return paint(p.color, styleFunction, strings.Join(strings.Fields(obj.Inspect()), " "))
+	case *object.Builtin:
+		return paint(p.color, styleBuiltin, obj.Inspect())
+	case *object.Error:
+		return p.error(obj.Message)
+	case *object.Array:
+		if depth >= prettyMaxDepth {
+			return "[...]"
+		}
+		items := make([]string, 0, len(obj.Elements))
+		for i, e := range obj.Elements {
+			if i == prettyMaxItems {
+				break
+			}
+			items = append(items, p.value(e, indent+2, depth+1))
+		}
+		return p.collection("[", "]", items, len(obj.Elements), indent)
+	case *object.Hash:
+		if depth >= prettyMaxDepth {
+			return "{...}"
+		}
+		items := make([]string, 0, obj.Len())
+		for i, pair := range obj.OrderedPairs() {
+			if i == prettyMaxItems {
+				break
+			}
+			items = append(items, p.value(pair.Key, indent+2, depth+1)+": "+p.value(pair.Value, indent+2, depth+1))
+		}
+		return p.collection("{", "}", items, obj.Len(), indent)
+	default:
+		return obj.Inspect()
+	}
+}
+
+// 能放在一行时单行显示，否则每行一个元素；total为元素总数，多于items时显示省略的个数
+func (p *printer) collection(open, close string, items []string, total, indent int) string {
+	if total > len(items) {
+		items = append(items, paint(p.color, styleDim, fmt.Sprintf("... %d more", total-len(items))))
+	}
+
+	inline := open + strings.Join(items, ", ") + close
+	if !strings.Contains(inline, "\n") && indent+visibleLen(inline) <= p.width {
+		return inline
+	}
+
+	var out strings.Builder
+	out.WriteString(open + "\n")
+	for _, item := range items {
+		out.WriteString(strings.Repeat(" ", indent+2) + item + ",\n")
+	}
+	out.WriteString(strings.Repeat(" ", indent) + close)
+	return out.String()
+}
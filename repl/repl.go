@@ -2,16 +2,13 @@
 package repl
 
 import (
-	"bufio"
-	"fmt"
 	"io"
-	"monkey_Interpreter/evaluator"
-	"monkey_Interpreter/lexer"
-	"monkey_Interpreter/object"
-	"monkey_Interpreter/parser"
+	"monkey_Interpreter/interpreter"
+	"strings"
 )
 
-const PROMPT = ">>" //表示 REPL 提示符
+const PROMPT = ">>"              //表示 REPL 提示符
+const CONTINUATION_PROMPT = ".." //输入未结束时的续行提示符
 const MONKEY_FACE = `            __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
@@ -27,50 +24,66 @@ const MONKEY_FACE = `            __,__
 
 // 函数接受输入流 in 和输出流 out 作为参数
 func Start(in io.Reader, out io.Writer) {
+	StartBackend(in, out, interpreter.TreeWalking)
+}
 
-	//在函数内部，创建了一个 bufio.Scanner 对象 scanner，用于从输入流中读取用户输入
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
-
-	for {
-		//使用 fmt.Fprintf 函数将提示符输出到输出流 out
-		fmt.Fprintf(out, PROMPT)
+// 使用指定的后端执行输入
+func StartBackend(in io.Reader, out io.Writer, backend interpreter.Backend) {
 
-		//调用 scanner.Scan() 方法来等待用户输入，并返回一个布尔值表示是否成功读取到输入
-		scanned := scanner.Scan()
+	s := newSession(out, backend)
 
-		if !scanned {
-			return
-		}
+	//输入为终端时支持行编辑、历史记录、Tab补全与语法高亮，否则按行读取
+	var highlighter func(string) string
+	if s.color {
+		highlighter = highlight
+	}
+	reader := newLineReader(in, out, func(prefix string) []string {
+		return completions(s.interp.Env(), prefix)
+	}, highlighter)
+	defer reader.Close()
 
-		//如果成功读取到输入，将用户输入的文本保存在变量 line 中
-		line := scanner.Text()
+	var lines []string //尚未结束的多行输入
 
-		//创建了一个 lexer.Lexer 对象 l，并使用用户输入的文本作为输入来初始化该对象
-		l := lexer.New(line)
+	for {
+		prompt := PROMPT
+		if len(lines) > 0 {
+			prompt = CONTINUATION_PROMPT
+		}
 
-		//语法解析
-		p := parser.New(l)
-		program := p.ParseProgram()
+		//输出提示符并等待用户输入，Ctrl-C丢弃当前输入，输入结束时退出
+		text, err := reader.ReadLine(prompt)
+		if err == errInterrupt {
+			lines = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
 
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		//以:开头的是REPL命令
+		if len(lines) == 0 && strings.HasPrefix(text, ":") {
+			s.runCommand(text)
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		//将本行与之前未结束的输入拼接，括号未闭合或以运算符结尾时继续读取下一行
+		lines = append(lines, text)
+		line := strings.Join(lines, "\n")
+		if isIncomplete(line) {
+			continue
 		}
+		lines = nil
+
+		//语法解析并求值
+		s.eval(line)
 	}
 }
 
 // 写入错误
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, errors []string, color bool) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
-	io.WriteString(out, " parser errors:\n")
+	io.WriteString(out, " "+paint(color, styleError, "parser errors:")+"\n")
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
 	}
@@ -0,0 +1,309 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/interpreter"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n  a + b\n};", false},
+		{"[1, 2,", true},
+		{"[1, 2,\n3]", false},
+		{"puts(1", true},
+		{"1 +", true},
+		{"1 + 2", false},
+		{"let x =", true},
+		{"a &&", true},
+		{`"unterminated`, true},
+		{`"say \"hi\"`, true},
+		{`"say \"hi\""`, false},
+		{`"a(" + "b"`, false},
+		{"if (x) { 1 } else", true},
+		{"1 }", false},
+		{`1 + 1 // it"s`, false},
+		{`"a // b`, true},
+		{"// \"\n\"open", true},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. got=%t, want=%t", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestStartMultiLine(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a + b\n};\nadd(1,\n2)\n"
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">>....>>..3\n>>"
+	if out.String() != expected {
+		t.Errorf("wrong output. got=%q, want=%q", out.String(), expected)
+	}
+
+	//注释中的引号不会让REPL一直等待输入
+	out.Reset()
+	Start(strings.NewReader("1 + 1 // it\"s\n2 + 2\n"), &out)
+	if expected := ">>2\n>>4\n>>"; out.String() != expected {
+		t.Errorf("wrong output after a comment. got=%q, want=%q", out.String(), expected)
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected []string
+	}{
+		{"abc\r", []string{"abc"}},
+		{"abc\x1b[D\x1b[DX\r", []string{"aXbc"}},
+		{"abc\x01X\x05Y\r", []string{"XabcY"}},
+		{"abcd\x7f\x1b[H\x1b[3~\r", []string{"bc"}},
+		{"let x = 1\x17\x172\r", []string{"let x 2"}},
+		{"hello world\x1b[D\x1b[D\x0b\r", []string{"hello wor"}},
+		{"hello world\x1b[D\x1b[D\x15\r", []string{"ld"}},
+		{"one\rtwo\r\x1b[A\x1b[A\r", []string{"one", "two", "one"}},
+		{"one\rtwo\rdraft\x10\x0e\r", []string{"one", "two", "draft"}},
+		{"fil\t([1], fn(x) { x })\r", []string{"filter([1], fn(x) { x })"}},
+		{"math.sq\t(4)\r", []string{"math.sqrt(4)"}},
+		{"counter + cou\t\r", []string{"counter + counter"}},
+		{"中文\x7f\r", []string{"中"}},
+	}
+
+	env := object.NewEnvironment()
+	env.Set("counter", &object.Integer{Value: 1})
+	complete := func(prefix string) []string { return completions(env, prefix) }
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		e := newLineEditor(strings.NewReader(tt.keys), &out, &history{}, complete)
+		var got []string
+		for {
+			line, err := e.readLine(PROMPT)
+			if err != nil {
+				break
+			}
+			got = append(got, line)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("wrong lines for %q. got=%q, want=%q", tt.keys, got, tt.expected)
+		}
+	}
+}
+
+func TestLineEditorControl(t *testing.T) {
+	e := newLineEditor(strings.NewReader("abc\x03\x04"), io.Discard, &history{}, nil)
+	if _, err := e.readLine(PROMPT); err != errInterrupt {
+		t.Errorf("Ctrl-C should interrupt. got=%v", err)
+	}
+	if _, err := e.readLine(PROMPT); err != io.EOF {
+		t.Errorf("Ctrl-D on empty line should end input. got=%v", err)
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("letter", &object.Integer{Value: 1})
+
+	got := strings.Join(completions(env, "le"), ",")
+	if got != "len,let,letter" {
+		t.Errorf("wrong completions. got=%s", got)
+	}
+	if got := commonPrefix([]string{"filter", "first"}); got != "fi" {
+		t.Errorf("wrong common prefix. got=%s", got)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h := loadHistory(path)
+	h.add("let a = 1")
+	h.add("let a = 1")
+	h.add("  ")
+	h.add("a + 1")
+	h.close()
+
+	h = loadHistory(path)
+	defer h.close()
+	if got := strings.Join(h.entries, "|"); got != "let a = 1|a + 1" {
+		t.Errorf("history not restored. got=%q", got)
+	}
+
+	var lines []string
+	for i := 0; i < maxHistory+10; i++ {
+		lines = append(lines, "x")
+		lines = append(lines, "y")
+	}
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600)
+	h = loadHistory(path)
+	if len(h.entries) != maxHistory {
+		t.Errorf("history not truncated. got=%d entries", len(h.entries))
+	}
+	h.close()
+	data, _ := os.ReadFile(path)
+	if n := strings.Count(string(data), "\n"); n != maxHistory {
+		t.Errorf("history file not compacted. got=%d lines", n)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.mk")
+	input := strings.Join([]string{
+		"let add = fn(a, b) { a + b };",
+		"let x = [1, 2];",
+		"add(x, 1)",
+		"let y = 1 / 0;",
+		":time let z = 3;",
+		":type z",
+		":type 1 + true",
+		":env",
+		":type add(1, 2)",
+		":ast -a + b",
+		":tokens let a = 1;",
+		":save " + file,
+		":reset",
+		":env",
+		":load " + file,
+		"add(x[0], x[1])",
+		":time 1 + 1",
+		":bogus",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	got := strings.ReplaceAll(out.String(), PROMPT, "")
+
+	expected := []string{
+		"add = fn(a, b) { (a + b) }\nx = [1, 2]\nz = 3\n",
+		"INTEGER\n",
+		"Program\n" +
+			"  Statements[0]: ExpressionStatement\n" +
+			"    Expression: InfixExpression Operator=\"+\"\n" +
+			"      Left: PrefixExpression Operator=\"-\"\n" +
+			"        Right: Identifier Value=\"a\"\n" +
+			"      Right: Identifier Value=\"b\"\n",
+		"LET        \"let\"\nIDENT      \"a\"\n=          \"=\"\nINT        \"1\"\n;          \";\"\n",
+		"saved 5 inputs to " + file + "\n",
+		"3\n2\ntime: ",
+		"unknown command: :bogus (type :help for a list)\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(got, e) {
+			t.Errorf("output missing %q. got=%q", e, got)
+		}
+	}
+	if strings.Count(got, "add = fn") != 1 {
+		t.Errorf(":reset did not clear the environment. got=%q", got)
+	}
+
+	data, _ := os.ReadFile(file)
+	if string(data) != "let add = fn(a, b) { a + b };\nlet x = [1, 2];\nlet z = 3;\nz\nadd(1, 2)\n" {
+		t.Errorf("wrong saved session. got=%q", data)
+	}
+}
+
+// 两种后端的REPL输出相同
+func TestStartBackends(t *testing.T) {
+	input := strings.Join([]string{
+		"let counter = fn() { let n = 0; fn() { n } };",
+		"let c = counter();",
+		"c",
+		"map([1, 2], fn(x) { x * 10 })",
+		"if (false) { 1 } elif (true) { }",
+		"missing + 1",
+		":env",
+		":type c()",
+		":reset",
+		":env",
+	}, "\n")
+
+	var eval, vm bytes.Buffer
+	StartBackend(strings.NewReader(input), &eval, interpreter.TreeWalking)
+	StartBackend(strings.NewReader(input), &vm, interpreter.Bytecode)
+	if eval.String() != vm.String() {
+		t.Errorf("outputs differ.\neval=%q\nvm=%q", eval.String(), vm.String())
+	}
+	if !strings.Contains(vm.String(), "ERROR: identifier not found: missing") {
+		t.Errorf("missing error output. got=%q", vm.String())
+	}
+}
+
+func TestPrettyPrint(t *testing.T) {
+	long := `range(30)`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"top level"`, "top level"},
+		{`[1, "a", true, 1.5, first([])]`, `[1, "a", true, 1.5, null]`},
+		{`{"name": "monkey", "tags": ["a", "b"]}`, `{"name": "monkey", "tags": ["a", "b"]}`},
+		{`1 + true`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`[fn(x) { x }]`, "[fn(x) { x }]"},
+		{long, "[\n  0,\n  1,\n  2,\n  3,\n  4,\n  5,\n  6,\n  7,\n  8,\n  9,\n  10,\n  11,\n  12,\n  13,\n  14,\n  15,\n  16,\n  17,\n  18,\n  19,\n  20,\n  21,\n  22,\n  23,\n  24,\n  25,\n  26,\n  27,\n  28,\n  29,\n]"},
+		{`{"a": range(5), "b": {"c": repeat("x", 70)}}`, "{\n  \"a\": [0, 1, 2, 3, 4],\n  \"b\": {\n    \"c\": \"" + strings.Repeat("x", 70) + "\",\n  },\n}"},
+		{`range(150)[100:] == range(100, 150)`, "true"},
+	}
+
+	p := &printer{color: false, width: prettyWidth}
+	for _, tt := range tests {
+		got := p.format(testEval(tt.input))
+		if got != tt.expected {
+			t.Errorf("wrong output for %s. got=%q, want=%q", tt.input, got, tt.expected)
+		}
+	}
+
+	got := p.format(testEval("range(250)"))
+	if !strings.HasSuffix(got, "  99,\n  ... 150 more,\n]") {
+		t.Errorf("large array not truncated. got suffix=%q", got[len(got)-40:])
+	}
+
+	colored := &printer{color: true, width: prettyWidth}
+	if got := colored.format(testEval(`[1, "a"]`)); got != "[\x1b[33m1\x1b[0m, \x1b[32m\"a\"\x1b[0m]" {
+		t.Errorf("wrong colored output. got=%q", got)
+	}
+	if got := colored.format(testEval(`1 + true`)); got != "\x1b[1;31mERROR:\x1b[0m type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong colored error. got=%q", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	input := `let s = len("x") + 1; "open`
+	expected := "\x1b[1;35mlet\x1b[0m s = \x1b[36mlen\x1b[0m(\x1b[32m\"x\"\x1b[0m) + \x1b[33m1\x1b[0m; \x1b[32m\"open\x1b[0m"
+	if got := highlight(input); got != expected {
+		t.Errorf("wrong highlight. got=%q, want=%q", got, expected)
+	}
+	if got := visibleLen(highlight(input)); got != len(input) {
+		t.Errorf("highlight changed the visible text. got=%d, want=%d", got, len(input))
+	}
+}
+
+func TestNoColorWhenNotTerminal(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("[1, \"a\"]\n1 + true\nlet = 1\n"), &out)
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("colors written to a non-terminal. got=%q", out.String())
+	}
+}
+
+func testEval(input string) object.Object {
+	p := parser.New(lexer.New(input))
+	return evaluator.Eval(p.ParseProgram(), object.NewEnvironment())
+}
@@ -0,0 +1,229 @@
+package main
+
+//命令行入口
+//  monkey                       启动REPL
+//  monkey script.mk arg1 arg2   执行脚本文件，参数通过args数组传给脚本
+//  monkey -e 'code' arg1 arg2   执行命令行中给出的代码
+//  monkey -backend vm ...       使用字节码虚拟机执行
+//  monkey -o script.mkc script.mk  编译为字节码文件，之后可直接执行script.mkc
+//  monkey -O script.mk          执行或编译前先优化语法树
+//  monkey fmt [-w] [-d] files    格式化源码文件，-w写回文件，-d输出差异
+//  monkey vet [-json] files      静态检查源码文件，-json以JSON格式输出
+//  monkey lsp                    在标准输入输出上运行语言服务器，供编辑器使用
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/compiler"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/interpreter"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/optimizer"
+	"monkey_Interpreter/parser"
+	"os"
+	"strings"
+)
+
+// 退出状态码
+const (
+	exitOK    = 0
+	exitError = 1 //语法错误或运行时错误
+	exitUsage = 2 //命令行参数错误
+)
+
+const usage = `usage: monkey [flags] [script.mk | script.mkc | -e code] [args...]
+       monkey fmt [flags] [files...]
+       monkey vet [flags] [files...]
+       monkey lsp
+
+flags:
+`
+
+// 解析命令行参数并执行，返回退出状态码
+func run(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	//子命令
+	if len(arguments) > 0 {
+		switch arguments[0] {
+		case "fmt":
+			return runFormat(arguments[1:], stdin, stdout, stderr)
+		case "vet":
+			return runVet(arguments[1:], stdin, stdout, stderr)
+		case "lsp":
+			return runLSP(arguments[1:], stdin, stdout, stderr)
+		}
+	}
+
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	code := flags.String("e", "", "execute `code` instead of a script file")
+	root := flags.String("fs", "", "allow the script to read and write files under `dir`")
+	backendName := flags.String("backend", "eval", "execute with `backend`: eval (tree-walking) or vm (bytecode)")
+	output := flags.String("o", "", "compile the script to `file` (.mkc) instead of running it")
+	disasm := flags.Bool("disasm", false, "print the compiled bytecode instead of running the script")
+	optimize := flags.Bool("O", false, "optimize the program (constant folding, branch pruning, inlining) before running or compiling")
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+	backend, err := interpreter.ParseBackend(*backendName)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey: %s\n", err)
+		return exitUsage
+	}
+
+	name, source, args := "-e", *code, flags.Args()
+	var compiled *compiler.Bytecode //已编译的.mkc文件
+	if !isFlagSet(flags, "e") {
+		if len(args) == 0 {
+			if *output != "" || *disasm {
+				fmt.Fprintln(stderr, "monkey: -o and -disasm need a script or -e code")
+				return exitUsage
+			}
+			return startRepl(stdin, stdout, backend)
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			return exitError
+		}
+		if compiler.IsBytecode(data) {
+			if compiled, err = compiler.ReadBytecode(bytes.NewReader(data)); err != nil {
+				fmt.Fprintf(stderr, "%s: %s\n", args[0], err)
+				return exitError
+			}
+			backend = interpreter.Bytecode
+		}
+		name, source, args = args[0], stripShebang(string(data)), args[1:]
+	}
+
+	passes := optimizer.Pass(0)
+	if *optimize {
+		passes = optimizer.AllPasses
+	}
+
+	if *output != "" || *disasm {
+		return compile(name, source, compiled, passes, *output, *disasm, stdout, stderr)
+	}
+
+	interp := interpreter.New(backend)
+	interp.Env().Set("args", stringArray(args))
+	evaluator.SetOutput(interp.Env(), stdout)
+	if *root != "" {
+		if err := evaluator.GrantFileSystem(interp.Env(), *root); err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			return exitUsage
+		}
+	}
+
+	return execute(name, source, compiled, passes, interp, stderr)
+}
+
+// 解析并执行源代码，compiled不为nil时直接执行字节码，错误写入stderr
+func execute(name, source string, compiled *compiler.Bytecode, passes optimizer.Pass, interp *interpreter.Interpreter, stderr io.Writer) int {
+	var result object.Object
+	if compiled != nil {
+		result = interp.RunBytecode(compiled)
+	} else {
+		program, ok := parse(name, source, passes, stderr)
+		if !ok {
+			return exitError
+		}
+		result = interp.Run(program)
+	}
+
+	if result, ok := result.(*object.Error); ok {
+		fmt.Fprintf(stderr, "%s: %s\n", name, result.Inspect())
+		return exitError
+	}
+	return exitOK
+}
+
+// 编译源代码，写入output文件，disasm为true时输出反汇编
+func compile(name, source string, compiled *compiler.Bytecode, passes optimizer.Pass, output string, disasm bool, stdout, stderr io.Writer) int {
+	if compiled == nil {
+		program, ok := parse(name, source, passes, stderr)
+		if !ok {
+			return exitError
+		}
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", name, err)
+			return exitError
+		}
+		compiled = c.Bytecode()
+	}
+
+	if disasm {
+		io.WriteString(stdout, compiled.Disassemble())
+	}
+	if output != "" {
+		var buf bytes.Buffer
+		if _, err := compiled.WriteTo(&buf); err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", name, err)
+			return exitError
+		}
+		if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			return exitError
+		}
+	}
+	return exitOK
+}
+
+// 语法解析、展开宏并按passes优化，出错时把错误写入stderr
+func parse(name, source string, passes optimizer.Pass, stderr io.Writer) (*ast.Program, bool) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "%s: %s\n", name, msg)
+		}
+		return nil, false
+	}
+
+	macros := object.NewEnvironment()
+	evaluator.DefineMacros(program, macros)
+	program, err := evaluator.ExpandMacros(program, macros)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", name, err)
+		return nil, false
+	}
+	return optimizer.Optimize(program, passes), true
+}
+
+// 去掉首行的#!，保留换行使行号不变
+func stripShebang(source string) string {
+	if !strings.HasPrefix(source, "#!") {
+		return source
+	}
+	if i := strings.IndexByte(source, '\n'); i >= 0 {
+		return source[i:]
+	}
+	return ""
+}
+
+// 命令行中是否给出了该参数，用于区分-e ''与未指定-e
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func stringArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, v := range values {
+		elements[i] = &object.String{Value: v}
+	}
+	return &object.Array{Elements: elements}
+}
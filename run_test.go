@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.mk")
+	os.WriteFile(script, []byte("#!/usr/bin/env monkey\nput(len(args)); put(args[0]);\n"), 0644)
+	broken := filepath.Join(dir, "broken.mk")
+	os.WriteFile(broken, []byte("let = 1;"), 0644)
+	data := filepath.Join(dir, "data")
+	os.Mkdir(data, 0755)
+	os.WriteFile(filepath.Join(data, "in.txt"), []byte("from file"), 0644)
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{script, "a", "b"}, 0, "2\na\n", ""},
+		{[]string{"-e", `put(join(args, "+"))`, "x", "y"}, 0, "x+y\n", ""},
+		{[]string{"-e", `put(1 + 2)`}, 0, "3\n", ""},
+		{[]string{"-e", `1 + true`}, 1, "", "-e: ERROR: type mismatch: INTEGER + BOOLEAN\n"},
+		{[]string{"-e", `put(1); let x = y; put(2)`}, 1, "1\n", "-e: ERROR: identifier not found: y\n"},
+		{[]string{broken}, 1, "", broken + `: expected next token to be "IDENT",got== instead`},
+		{[]string{filepath.Join(dir, "missing.mk")}, 1, "", "no such file or directory"},
+		{[]string{"-e", `read_file("in.txt")`}, 1, "", "identifier not found: read_file"},
+		{[]string{"-fs", data, "-e", `put(read_file("in.txt"))`}, 0, "from file\n", ""},
+		{[]string{"-nope"}, 2, "", "flag provided but not defined: -nope"},
+		{[]string{"-backend", "vm", script, "a"}, 0, "1\na\n", ""},
+		{[]string{"-backend", "vm", "-e", `put(map(args, fn(a) { a + "!" }))`, "x"}, 0, "[x!]\n", ""},
+		{[]string{"-backend", "vm", "-e", `put(1); let x = y; put(2)`}, 1, "1\n", "-e: ERROR: identifier not found: y\n"},
+		{[]string{"-backend", "vm", "-fs", data, "-e", `put(read_file("in.txt"))`}, 0, "from file\n", ""},
+		{[]string{"-backend", "jit", "-e", "1"}, 2, "", "unknown backend \"jit\""},
+		{[]string{"-O", "-e", `let sq = fn(x) { x * x }; put(sq(3) + 1); 1 / 0`}, 1, "10\n", "-e: ERROR: division by zero\n"},
+		{[]string{"-O", "-backend", "vm", script, "a"}, 0, "1\na\n", ""},
+		{[]string{"-backend", "vm", "-e", `let unless = macro(c, a, b) { quote(if (unquote(c)) { unquote(b) } else { unquote(a) }) }; unless(false, put("yes"), put("no"))`}, 0, "yes\n", ""},
+		{[]string{"-e", `let m = macro() { 1 }; m()`}, 1, "", "-e: macro m must return a quote, got INTEGER\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("run(%q) exit code wrong. got=%d, want=%d (stderr=%q)", tt.args, code, tt.code, stderr.String())
+		}
+		if stdout.String() != tt.stdout {
+			t.Errorf("run(%q) stdout wrong. got=%q, want=%q", tt.args, stdout.String(), tt.stdout)
+		}
+		if !strings.Contains(stderr.String(), tt.stderr) {
+			t.Errorf("run(%q) stderr wrong. got=%q, want to contain %q", tt.args, stderr.String(), tt.stderr)
+		}
+	}
+}
+
+// 编译为.mkc文件后直接执行，不再需要源代码
+func TestRunCompiled(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.mk")
+	os.WriteFile(script, []byte("#!/usr/bin/env monkey\nlet f = fn(a) { a + \"!\" };\nput(map(args, f));\nput(x);\n"), 0644)
+	compiled := filepath.Join(dir, "script.mkc")
+	corrupted := filepath.Join(dir, "corrupted.mkc")
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"-o", compiled, script}, 0, "", ""},
+		{[]string{compiled, "a", "b"}, 1, "[a!,b!]\n", compiled + ": ERROR: identifier not found: x\n"},
+		{[]string{"-backend", "eval", compiled, "a"}, 1, "[a!]\n", ""},
+		{[]string{"-disasm", "-e", "put(1)"}, 0, "main:\n   1  0000 OpGetName 0\n      0003 OpConstant 1\n      0006 OpCall 1\n      0008 OpPop\n", ""},
+		{[]string{"-O", "-disasm", "-e", "if (1 > 2) { put(1) } else { 3 }"}, 0, "main:\n   1  0000 OpConstant 0\n      0003 OpPop\n", ""},
+		{[]string{"-disasm", compiled}, 0, "main:\n   2  0000 OpClosure 1\n", ""},
+		{[]string{"-o", compiled, "-e", "let = 1"}, 1, "", "-e: expected next token"},
+		{[]string{"-o", compiled}, 2, "", "-o and -disasm need a script or -e code"},
+		{[]string{corrupted}, 1, "", corrupted + ": mkc: checksum mismatch, file is corrupted\n"},
+	}
+
+	for i, tt := range tests {
+		if i == 2 {
+			data, _ := os.ReadFile(compiled)
+			data[len(data)-1] ^= 0xff
+			os.WriteFile(corrupted, data, 0644)
+		}
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("run(%q) exit code wrong. got=%d, want=%d (stderr=%q)", tt.args, code, tt.code, stderr.String())
+		}
+		if !strings.HasPrefix(stdout.String(), tt.stdout) {
+			t.Errorf("run(%q) stdout wrong. got=%q, want prefix %q", tt.args, stdout.String(), tt.stdout)
+		}
+		if !strings.Contains(stderr.String(), tt.stderr) {
+			t.Errorf("run(%q) stderr wrong. got=%q, want to contain %q", tt.args, stderr.String(), tt.stderr)
+		}
+	}
+}
+
+// monkey fmt子命令
+func TestFormat(t *testing.T) {
+	dir := t.TempDir()
+	messy := filepath.Join(dir, "messy.mk")
+	tidy := filepath.Join(dir, "tidy.mk")
+	broken := filepath.Join(dir, "broken.mk")
+	os.WriteFile(messy, []byte("let x=1+2\nput( x )\n"), 0644)
+	os.WriteFile(tidy, []byte("put(1);\n"), 0644)
+	os.WriteFile(broken, []byte("let = 1;"), 0644)
+
+	tests := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"fmt"}, "let f=fn(x){x*2}", 0, "let f = fn(x) { x * 2 };\n", ""},
+		{[]string{"fmt", "-indent", "2"}, "if (x) {\ny }", 0, "if (x) {\n  y\n}\n", ""},
+		{[]string{"fmt", "-width", "10"}, "[100, 200]", 0, "[\n    100,\n    200,\n];\n", ""},
+		{[]string{"fmt", "-tabs"}, "if (x) {\ny }", 0, "if (x) {\n\ty\n}\n", ""},
+		{[]string{"fmt", messy, tidy}, "", 0, "let x = 1 + 2;\nput(x);\nput(1);\n", ""},
+		{[]string{"fmt", "-d", tidy}, "", 0, "", ""},
+		{[]string{"fmt", "-d", messy}, "", 0, "--- " + messy + ".orig\n+++ " + messy + "\n@@ -1,2 +1,2 @@\n-let x=1+2\n-put( x )\n+let x = 1 + 2;\n+put(x);\n", ""},
+		{[]string{"fmt", broken, tidy}, "", 1, "put(1);\n", broken + `: expected next token to be "IDENT",got== instead`},
+		{[]string{"fmt"}, "a & b", 1, "", "<stdin>: line 1: unexpected character '&'"},
+		{[]string{"fmt", filepath.Join(dir, "missing.mk")}, "", 1, "", "no such file or directory"},
+		{[]string{"fmt", "-w"}, "1", 2, "", "monkey fmt: -w needs a file"},
+		{[]string{"fmt", "-width", "0"}, "1", 2, "", "must be positive"},
+		{[]string{"fmt", "-nope"}, "", 2, "", "flag provided but not defined: -nope"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("run(%q) exit code wrong. got=%d, want=%d (stderr=%q)", tt.args, code, tt.code, stderr.String())
+		}
+		if stdout.String() != tt.stdout {
+			t.Errorf("run(%q) stdout wrong. got=%q, want=%q", tt.args, stdout.String(), tt.stdout)
+		}
+		if !strings.Contains(stderr.String(), tt.stderr) {
+			t.Errorf("run(%q) stderr wrong. got=%q, want to contain %q", tt.args, stderr.String(), tt.stderr)
+		}
+	}
+
+	//-w写回文件，格式化过的文件不再改变
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fmt", "-w", messy, tidy}, strings.NewReader(""), &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+		t.Fatalf("fmt -w failed. code=%d stdout=%q stderr=%q", code, stdout.String(), stderr.String())
+	}
+	if data, _ := os.ReadFile(messy); string(data) != "let x = 1 + 2;\nput(x);\n" {
+		t.Errorf("fmt -w did not rewrite the file. got=%q", data)
+	}
+	stdout.Reset()
+	run([]string{"fmt", "-d", messy}, strings.NewReader(""), &stdout, &stderr)
+	if stdout.Len() != 0 {
+		t.Errorf("formatted file still differs. got=%q", stdout.String())
+	}
+}
+
+func TestVet(t *testing.T) {
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean.mk")
+	dirty := filepath.Join(dir, "dirty.mk")
+	os.WriteFile(clean, []byte("let x = 1;\nput(x);\n"), 0644)
+	os.WriteFile(dirty, []byte("let x = 1;\nput(y);\n"), 0644)
+
+	tests := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"vet", clean}, "", 0, "", ""},
+		{[]string{"vet", clean, dirty}, "", 1, dirty + ":1:5: warning: x declared and not used (unused)\n" + dirty + ":2:5: error: undefined: y (undefined)\n", ""},
+		{[]string{"vet"}, "put(len());", 1, "<stdin>:1:5: error: wrong number of arguments to len(x): got 0, want 1 (arity)\n", ""},
+		{[]string{"vet", "-json"}, "put(1);", 0, "[]\n", ""},
+		{[]string{"vet", "-json"}, "put(z)", 1, `[
+  {
+    "file": "<stdin>",
+    "line": 1,
+    "column": 5,
+    "endLine": 1,
+    "endColumn": 6,
+    "offset": 4,
+    "end": 5,
+    "severity": "error",
+    "check": "undefined",
+    "message": "undefined: z"
+  }
+]
+`, ""},
+		{[]string{"vet", filepath.Join(dir, "missing.mk")}, "", 1, "", "no such file or directory"},
+		{[]string{"vet", "-nope"}, "", 2, "", "flag provided but not defined: -nope"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("run(%q) exit code wrong. got=%d, want=%d (stderr=%q)", tt.args, code, tt.code, stderr.String())
+		}
+		if stdout.String() != tt.stdout {
+			t.Errorf("run(%q) stdout wrong. got=%q, want=%q", tt.args, stdout.String(), tt.stdout)
+		}
+		if !strings.Contains(stderr.String(), tt.stderr) {
+			t.Errorf("run(%q) stderr wrong. got=%q, want to contain %q", tt.args, stderr.String(), tt.stderr)
+		}
+	}
+}
+
+func TestLSP(t *testing.T) {
+	frame := func(body string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	session := frame(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`) +
+		frame(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.mk","version":1,"text":"put(x)"}}}`) +
+		frame(`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"lsp", "--stdio"}, strings.NewReader(session+frame(`{"jsonrpc":"2.0","method":"exit"}`)), &stdout, &stderr); code != 0 {
+		t.Fatalf("lsp exit code wrong. got=%d (stderr=%q)", code, stderr.String())
+	}
+	for _, want := range []string{`"hoverProvider":true`, `"message":"undefined: x"`, `"id":2,"result":null`} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("lsp output does not contain %q: %q", want, stdout.String())
+		}
+	}
+
+	//没有shutdown就exit
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"lsp"}, strings.NewReader(frame(`{"jsonrpc":"2.0","method":"exit"}`)), &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "exit before shutdown") {
+		t.Errorf("exit without shutdown: code=%d stderr=%q", code, stderr.String())
+	}
+	if code := run([]string{"lsp", "file.mk"}, strings.NewReader(""), &stdout, &stderr); code != 2 {
+		t.Errorf("lsp with arguments: code=%d, want 2", code)
+	}
+}
+
+func TestStripShebang(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#!/usr/bin/env monkey\nput(1)", "\nput(1)"},
+		{"#!only", ""},
+		{"put(1)\n#!x", "put(1)\n#!x"},
+	}
+
+	for _, tt := range tests {
+		if got := stripShebang(tt.input); got != tt.expected {
+			t.Errorf("stripShebang(%q) wrong. got=%q, want=%q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package token
 
+import "sort"
+
 // 词法单元类型
 type TokenType string
 
@@ -8,6 +10,11 @@ type Token struct {
 	Type TokenType
 	// 字面量
 	Literal string
+	// 在输入中的起止字节位置，End指向词法单元之后
+	Offset int
+	End    int
+	// 所在行，从1开始
+	Line int
 }
 
 // 声明一些词法常量
@@ -15,10 +22,12 @@ const (
 	// 特殊类型
 	ILLEGAL = "ILLEGAL" // 未知字符
 	EOF     = "EOF"     // 文件结尾
+	COMMENT = "COMMENT" // 行注释，词法分析器跳过并单独记录
 
 	// 标识符+字面量
 	IDENT = "IDENT" // add, foobar, x, y
 	INT   = "INT"   // 1343456
+	FLOAT = "FLOAT" // 3.14
 
 	// 运算符
 	ASSIGN   = "="
@@ -49,6 +58,7 @@ const (
 	ELIF   = "ELIF"
 	ELSE   = "ELSE"
 	RETURN = "RETURN"
+	MACRO  = "MACRO"
 	TRUE   = "TRUE"
 	FALSE  = "FALSE"
 
@@ -66,6 +76,7 @@ const (
 
 	//
 	COLON = ":"
+	DOT   = "." //属性访问 obj.name
 )
 
 //！-/*5；
@@ -80,6 +91,7 @@ var keywords = map[string]TokenType{
 	"else":   ELSE,
 	"elif":   ELIF,
 	"return": RETURN,
+	"macro":  MACRO,
 
 	"true":  TRUE,
 	"false": FALSE,
@@ -92,3 +104,13 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// 所有关键字，按字母排序
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
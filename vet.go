@@ -0,0 +1,82 @@
+package main
+
+//monkey vet 静态检查源码文件
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"monkey_Interpreter/vet"
+	"os"
+)
+
+const vetUsage = `usage: monkey vet [flags] [files...]
+
+Reports suspicious code in Monkey source files without running them.
+Without files, checks standard input. Exits with status 1 if anything is reported.
+
+checks: undefined, unused, shadow, unreachable, arity, duplicate-key, constant-condition
+
+flags:
+`
+
+// -json输出的一条结果
+type vetResult struct {
+	File string `json:"file"`
+	vet.Diagnostic
+}
+
+// monkey vet子命令，返回退出状态码
+func runVet(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey vet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, vetUsage)
+		flags.PrintDefaults()
+	}
+	asJSON := flags.Bool("json", false, "print the diagnostics as a JSON array")
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+
+	code := exitOK
+	results := []vetResult{}
+	check := func(name string, src []byte) {
+		for _, d := range vet.Source(src) {
+			results = append(results, vetResult{name, d})
+		}
+	}
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey vet: %s\n", err)
+			return exitError
+		}
+		check("<stdin>", src)
+	}
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			code = exitError
+			continue
+		}
+		check(name, src)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	} else {
+		for _, r := range results {
+			fmt.Fprintf(stdout, "%s:%s\n", r.File, r.Diagnostic)
+		}
+	}
+	if len(results) > 0 {
+		code = exitError
+	}
+	return code
+}
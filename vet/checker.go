@@ -0,0 +1,460 @@
+package vet
+
+import (
+	"fmt"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/object"
+	"strings"
+)
+
+// 一次let或一个形参引入的绑定
+type binding struct {
+	ident   *ast.Identifier
+	param   bool
+	defined bool //按源码顺序检查时已经执行过这次绑定
+	used    bool
+}
+
+// 程序和每个函数对应一层环境，块不引入新的环境
+type frame struct {
+	outer    *frame
+	bindings map[string][]*binding //按源码顺序
+	all      []*binding
+}
+
+func (f *frame) add(b *binding) {
+	f.bindings[b.ident.Value] = append(f.bindings[b.ident.Value], b)
+	f.all = append(f.all, b)
+}
+
+// 最近一次已经执行的绑定
+func (f *frame) defined(name string) *binding {
+	bs := f.bindings[name]
+	for i := len(bs) - 1; i >= 0; i-- {
+		if bs[i].defined {
+			return bs[i]
+		}
+	}
+	return nil
+}
+
+type checker struct {
+	src   string
+	loc   *Locator
+	frame *frame
+	lets  map[*ast.LetStatement]*binding
+	refs  map[*ast.Identifier]*ast.Identifier //标识符 -> 声明
+	diags []Diagnostic
+}
+
+func newChecker(src string) *checker {
+	return &checker{
+		src:  src,
+		loc:  NewLocator([]byte(src)),
+		lets: map[*ast.LetStatement]*binding{},
+		refs: map[*ast.Identifier]*ast.Identifier{},
+	}
+}
+
+func (c *checker) report(node ast.Node, severity, check, format string, args ...interface{}) {
+	c.diags = append(c.diags, newDiagnostic(c.src, c.loc.Start(node), c.loc.End(node), severity, check, fmt.Sprintf(format, args...)))
+}
+
+func (c *checker) program(program *ast.Program) {
+	c.enter(nil, program)
+	c.statements(program.Statements)
+	c.leave()
+}
+
+// 进入一层新的环境，声明形参和body中所有的let
+func (c *checker) enter(params []*ast.Identifier, body ast.Node) {
+	c.frame = &frame{outer: c.frame, bindings: map[string][]*binding{}}
+	for _, p := range params {
+		if len(c.frame.bindings[p.Value]) == 0 {
+			c.shadow(p)
+		}
+		c.frame.add(&binding{ident: p, param: true, defined: true})
+		c.refs[p] = p
+	}
+	inspectCode(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.LetStatement:
+			if len(c.frame.bindings[node.Name.Value]) == 0 {
+				c.shadow(node.Name)
+			}
+			b := &binding{ident: node.Name}
+			c.frame.add(b)
+			c.lets[node] = b
+			c.refs[node.Name] = node.Name
+		}
+		return true
+	})
+}
+
+// 离开环境，报告没有使用的绑定
+func (c *checker) leave() {
+	for _, b := range c.frame.all {
+		if b.used || strings.HasPrefix(b.ident.Value, "_") {
+			continue
+		}
+		if b.param {
+			c.report(b.ident, Warning, "unused", "parameter %s is not used", b.ident.Value)
+		} else {
+			c.report(b.ident, Warning, "unused", "%s declared and not used", b.ident.Value)
+		}
+	}
+	c.frame = c.frame.outer
+}
+
+// 新的名称遮蔽了外层环境中的变量或内置函数
+func (c *checker) shadow(ident *ast.Identifier) {
+	name := ident.Value
+	for f := c.frame.outer; f != nil; f = f.outer {
+		if bs := f.bindings[name]; len(bs) > 0 {
+			line, _ := Position(c.src, bs[0].ident.Token.Offset)
+			c.report(ident, Warning, "shadow", "%s shadows the declaration on line %d", name, line)
+			return
+		}
+	}
+	if obj, ok := evaluator.LookupBuiltin(name); ok {
+		kind := "function"
+		if _, isModule := obj.(*object.Hash); isModule {
+			kind = "module"
+		}
+		c.report(ident, Warning, "shadow", "%s shadows the builtin %s", name, kind)
+	}
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	terminated := false
+	for i, stmt := range stmts {
+		if terminated {
+			c.diags = append(c.diags, newDiagnostic(c.src, c.loc.Start(stmt), c.loc.End(stmts[len(stmts)-1]), Warning, "unreachable", "unreachable code"))
+			terminated = false
+			for _, s := range stmts[i:] {
+				c.statement(s)
+			}
+			return
+		}
+		c.statement(stmt)
+		terminated = terminates(stmt)
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.expression(stmt.Value)
+		if b := c.lets[stmt]; b != nil {
+			b.defined = true
+		}
+	case *ast.ReturnStatement:
+		c.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		c.statements(stmt.Statements)
+	}
+}
+
+func (c *checker) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		c.resolve(exp)
+
+	case *ast.PrefixExpression:
+		c.expression(exp.Right)
+
+	case *ast.InfixExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Right)
+
+	case *ast.IfExpression:
+		c.condition(exp.Condition)
+		c.statement(exp.Consequence)
+		for _, alt := range exp.Alternatives {
+			c.condition(alt.Condition)
+			c.statement(alt.Consequence)
+		}
+		if exp.LastAlternative != nil {
+			c.statement(exp.LastAlternative)
+		}
+
+	case *ast.FunctionLiteral:
+		c.enter(exp.Parameters, exp.Body)
+		c.statement(exp.Body)
+		c.leave()
+
+	case *ast.MacroLiteral:
+		c.enter(exp.Parameters, exp.Body)
+		c.statement(exp.Body)
+		c.leave()
+
+	case *ast.CallExpression:
+		if c.isBuiltin(exp.Function, "quote") {
+			//quote中的代码不求值，只检查其中unquote的实参
+			//有语法错误时实参可能为nil
+			for _, arg := range exp.Arguments {
+				if arg != nil {
+					unquoted(arg, c.expression)
+				}
+			}
+			return
+		}
+		c.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			c.expression(arg)
+		}
+		c.arity(exp)
+
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.expression(el)
+		}
+
+	case *ast.IndexExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+
+	case *ast.SliceExpression:
+		c.expression(exp.Left)
+		for _, e := range []ast.Expression{exp.Start, exp.End, exp.Step} {
+			if e != nil {
+				c.expression(e)
+			}
+		}
+
+	case *ast.PropertyExpression:
+		c.expression(exp.Object)
+		if c.isBuiltin(exp.Object, "") {
+			name := exp.Object.(*ast.Identifier).Value
+			if module, ok := evaluator.LookupBuiltin(name); ok {
+				if _, ok := module.(*object.Hash).Get(&object.String{Value: exp.Property.Value}); !ok {
+					c.report(exp, Error, "undefined", "undefined: %s.%s", name, exp.Property.Value)
+				}
+			}
+		}
+
+	case *ast.HashLiteral:
+		seen := map[string]bool{}
+		for _, key := range exp.Keys {
+			c.expression(key)
+			c.expression(exp.Pairs[key])
+			if k, ok := hashKey(key); ok {
+				if seen[k] {
+					c.report(key, Warning, "duplicate-key", "duplicate key %s in hash literal", c.loc.Text(key))
+				}
+				seen[k] = true
+			}
+		}
+	}
+}
+
+// 查找标识符引用的绑定：先找当前环境中已经执行过的let，再找外层环境
+// 外层环境中的函数可能在之后才被调用，所以之后的let也算
+func (c *checker) resolve(ident *ast.Identifier) {
+	name := ident.Value
+	if b := c.frame.defined(name); b != nil {
+		c.use(ident, b)
+		return
+	}
+	for f := c.frame.outer; f != nil; f = f.outer {
+		if bs := f.bindings[name]; len(bs) > 0 {
+			b := f.defined(name)
+			if b == nil {
+				b = bs[0]
+			}
+			c.use(ident, b)
+			return
+		}
+	}
+	if known(name) {
+		return
+	}
+	if bs := c.frame.bindings[name]; len(bs) > 0 {
+		c.use(ident, bs[0])
+		c.report(ident, Error, "undefined", "%s is used before it is defined", name)
+		return
+	}
+	c.report(ident, Error, "undefined", "undefined: %s", name)
+}
+
+func (c *checker) use(ident *ast.Identifier, b *binding) {
+	b.used = true
+	c.refs[ident] = b.ident
+}
+
+// 没有被let或形参遮蔽的内置名称
+func known(name string) bool {
+	if _, ok := evaluator.LookupBuiltin(name); ok {
+		return true
+	}
+	switch name {
+	case "args", "quote", "unquote":
+		return true
+	}
+	return evaluator.IsFileSystemBuiltin(name)
+}
+
+// exp是没有被遮蔽的内置名称name，name为空时匹配任意内置名称
+func (c *checker) isBuiltin(exp ast.Expression, name string) bool {
+	ident, ok := exp.(*ast.Identifier)
+	if !ok || name != "" && ident.Value != name || !known(ident.Value) {
+		return false
+	}
+	for f := c.frame; f != nil; f = f.outer {
+		if len(f.bindings[ident.Value]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// 检查内置函数和模块函数的实参个数
+func (c *checker) arity(call *ast.CallExpression) {
+	name := ""
+	switch fn := call.Function.(type) {
+	case *ast.Identifier:
+		if c.isBuiltin(fn, "") {
+			name = fn.Value
+		}
+	case *ast.PropertyExpression:
+		if c.isBuiltin(fn.Object, "") {
+			name = fn.Object.(*ast.Identifier).Value + "." + fn.Property.Value
+		}
+	}
+	sig, ok := evaluator.LookupSignature(name)
+	if !ok || sig.Accepts(len(call.Arguments)) {
+		return
+	}
+	want := fmt.Sprint(sig.MinArgs)
+	switch {
+	case sig.MaxArgs < 0:
+		want = fmt.Sprintf("at least %d", sig.MinArgs)
+	case sig.MaxArgs != sig.MinArgs:
+		want = fmt.Sprintf("%d to %d", sig.MinArgs, sig.MaxArgs)
+	}
+	c.report(call, Error, "arity", "wrong number of arguments to %s: got %d, want %s", sig, len(call.Arguments), want)
+}
+
+// 检查if和elif的条件是否恒为真或恒为假
+func (c *checker) condition(cond ast.Expression) {
+	c.expression(cond)
+	var truthy bool
+	switch cond.(type) {
+	case *ast.ArrayLiteral, *ast.HashLiteral, *ast.FunctionLiteral:
+		truthy = true
+	default:
+		value, ok := constant(cond)
+		if !ok {
+			return
+		}
+		truthy = evaluator.IsTruthy(value)
+	}
+	c.report(cond, Warning, "constant-condition", "condition is always %t", truthy)
+}
+
+// 只由字面量和运算符组成的表达式的值
+func constant(exp ast.Expression) (object.Object, bool) {
+	if !isConstant(exp) {
+		return nil, false
+	}
+	value := evaluator.Eval(exp, object.NewEnvironment())
+	if value == nil || value.Type() == object.ERROR_OBJ {
+		return nil, false
+	}
+	return value, true
+}
+
+func isConstant(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(exp.Right)
+	case *ast.InfixExpression:
+		return isConstant(exp.Left) && isConstant(exp.Right)
+	}
+	return false
+}
+
+// 哈希表的键，值相同的常量和同名的标识符是相同的键
+func hashKey(exp ast.Expression) (string, bool) {
+	if ident, ok := exp.(*ast.Identifier); ok {
+		return "identifier " + ident.Value, true
+	}
+	value, ok := constant(exp)
+	if !ok {
+		return "", false
+	}
+	hashable, ok := value.(object.Hashable)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(hashable.HashKey()), true
+}
+
+// 执行stmt之后，同一个块中之后的语句都不会执行
+func terminates(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.BlockStatement:
+		for _, s := range stmt.Statements {
+			if terminates(s) {
+				return true
+			}
+		}
+	case *ast.ExpressionStatement:
+		//每个分支都以return结束的if
+		ie, ok := stmt.Expression.(*ast.IfExpression)
+		if !ok || ie.LastAlternative == nil || !terminates(ie.Consequence) || !terminates(ie.LastAlternative) {
+			return false
+		}
+		for _, alt := range ie.Alternatives {
+			if !terminates(alt.Consequence) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// 遍历运行时会求值的代码：跳过quote中的代码，但进入其中unquote的实参
+func inspectCode(node ast.Node, f func(ast.Node) bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpression); ok && isCallTo(call, "quote") {
+			for _, arg := range call.Arguments {
+				if arg != nil {
+					unquoted(arg, func(e ast.Expression) { inspectCode(e, f) })
+				}
+			}
+			return false
+		}
+		return n == nil || f(n)
+	})
+}
+
+// 对quote的代码中每个unquote的实参调用f
+func unquoted(node ast.Node, f func(ast.Expression)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpression); ok && isCallTo(call, "unquote") {
+			for _, arg := range call.Arguments {
+				if arg != nil {
+					f(arg)
+				}
+			}
+			return false
+		}
+		return true
+	})
+}
+
+func isCallTo(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
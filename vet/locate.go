@@ -0,0 +1,166 @@
+package vet
+
+import (
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/token"
+)
+
+// 计算语法树节点在源码中的范围
+// 语法树只记录了每个节点的一个词法单元，右括号的位置要对照源码的词法单元得到
+type Locator struct {
+	src     string
+	closing map[int]token.Token //左括号的偏移 -> 对应的右括号
+}
+
+// src为解析语法树的源码
+func NewLocator(src []byte) *Locator {
+	l := &Locator{src: blankShebang(string(src)), closing: map[int]token.Token{}}
+	var open []token.Token
+	lex := lexer.New(l.src)
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open = append(open, tok)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(open) > 0 {
+				l.closing[open[len(open)-1].Offset] = tok
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return l
+}
+
+// 节点的第一个词法单元的起始偏移
+// 有语法错误时子节点可能为nil，此时使用节点自己的词法单元
+func (l *Locator) Start(node ast.Node) int {
+	if first := firstChild(node); first != nil {
+		return l.Start(first)
+	}
+	return tokenOf(node).Offset
+}
+
+func firstChild(node ast.Node) ast.Node {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return node.Expression
+	case *ast.InfixExpression:
+		return node.Left
+	case *ast.CallExpression:
+		return node.Function
+	case *ast.IndexExpression:
+		return node.Left
+	case *ast.SliceExpression:
+		return node.Left
+	case *ast.PropertyExpression:
+		return node.Object
+	}
+	return nil
+}
+
+// 节点的最后一个词法单元的结束偏移
+func (l *Locator) End(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if node.Value != nil {
+			return l.End(node.Value)
+		}
+		if node.Name != nil {
+			return node.Name.Token.End
+		}
+	case *ast.ReturnStatement:
+		if node.ReturnValue != nil {
+			return l.End(node.ReturnValue)
+		}
+	case *ast.ExpressionStatement:
+		if node.Expression != nil {
+			return l.End(node.Expression)
+		}
+	case *ast.PrefixExpression:
+		if node.Right != nil {
+			return l.End(node.Right)
+		}
+	case *ast.InfixExpression:
+		if node.Right != nil {
+			return l.End(node.Right)
+		}
+	case *ast.IfExpression:
+		last := node.Consequence
+		if n := len(node.Alternatives); n > 0 {
+			last = node.Alternatives[n-1].Consequence
+		}
+		if node.LastAlternative != nil {
+			last = node.LastAlternative
+		}
+		return l.End(last)
+	case *ast.FunctionLiteral:
+		return l.End(node.Body)
+	case *ast.MacroLiteral:
+		return l.End(node.Body)
+	case *ast.PropertyExpression:
+		return node.Property.Token.End
+	}
+	tok := tokenOf(node)
+	if closer, ok := l.closing[tok.Offset]; ok {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			return closer.End
+		}
+	}
+	return tok.End
+}
+
+// 节点记录的词法单元
+func tokenOf(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.BlockStatement:
+		return node.Token
+	case *ast.Identifier:
+		return node.Token
+	case *ast.IntegerLiteral:
+		return node.Token
+	case *ast.FloatLiteral:
+		return node.Token
+	case *ast.StringLiteral:
+		return node.Token
+	case *ast.Boolean:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.InfixExpression:
+		return node.Token
+	case *ast.IfExpression:
+		return node.Token
+	case *ast.ElIfExpression:
+		return node.Token
+	case *ast.FunctionLiteral:
+		return node.Token
+	case *ast.MacroLiteral:
+		return node.Token
+	case *ast.CallExpression:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.IndexExpression:
+		return node.Token
+	case *ast.SliceExpression:
+		return node.Token
+	case *ast.PropertyExpression:
+		return node.Token
+	case *ast.HashLiteral:
+		return node.Token
+	}
+	return token.Token{}
+}
+
+// 节点在源码中的文本
+func (l *Locator) Text(node ast.Node) string {
+	return l.src[l.Start(node):l.End(node)]
+}
@@ -0,0 +1,136 @@
+package vet
+
+//静态检查 不运行脚本，在语法树上分析作用域，找出可能的错误
+//  undefined          未定义的标识符、在定义之前使用的变量
+//  unused             没有使用的let绑定和形参，以_开头的名称除外
+//  shadow             遮蔽外层变量或内置函数的声明
+//  unreachable        return之后执行不到的语句
+//  arity              调用内置函数时实参个数不对
+//  duplicate-key      哈希表字面量中重复的键
+//  constant-condition 结果总为真或总为假的条件
+//语法错误时只报告syntax，不做其他检查
+
+import (
+	"fmt"
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/parser"
+	"monkey_Interpreter/token"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 严重程度
+const (
+	Error   = "error"   //运行时一定或很可能出错
+	Warning = "warning" //可疑的写法
+)
+
+// 一条检查结果，行号和列号从1开始，列号按字符计
+type Diagnostic struct {
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Offset    int    `json:"offset"` //起止位置在源码中的字节偏移
+	End       int    `json:"end"`
+	Severity  string `json:"severity"`
+	Check     string `json:"check"`
+	Message   string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", d.Line, d.Column, d.Severity, d.Message, d.Check)
+}
+
+// 检查源码，结果按位置排序
+func Source(src []byte) []Diagnostic {
+	program, diags := Parse(src)
+	if len(diags) != 0 {
+		return diags
+	}
+	return Check(program, src)
+}
+
+// 解析源码，有语法错误时返回syntax诊断，此时的语法树不完整
+func Parse(src []byte) (*ast.Program, []Diagnostic) {
+	text := blankShebang(string(src))
+	if diag, ok := illegalCharacter(text); ok {
+		return nil, []Diagnostic{diag}
+	}
+
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	var diags []Diagnostic
+	for i, msg := range p.Errors() {
+		tok := p.ErrorTokens()[i]
+		diags = append(diags, newDiagnostic(text, tok.Offset, tok.End, Error, "syntax", msg))
+	}
+	return program, diags
+}
+
+// 检查已经解析好的程序，src为解析program的源码，用于计算位置
+func Check(program *ast.Program, src []byte) []Diagnostic {
+	c := newChecker(string(src))
+	c.program(program)
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		return c.diags[i].Offset < c.diags[j].Offset
+	})
+	return c.diags
+}
+
+// 查找每个标识符引用的声明(let的名称或形参)，声明对应自己
+// 内置名称、未定义的标识符和属性名不在结果中
+// program可以是有语法错误时不完整的语法树，只解析出来的部分有结果
+func Resolve(program *ast.Program, src []byte) map[*ast.Identifier]*ast.Identifier {
+	c := newChecker(string(src))
+	c.program(program)
+	return c.refs
+}
+
+// 脚本开头的#!行换成空格，保持行号和偏移不变
+func blankShebang(src string) string {
+	if !strings.HasPrefix(src, "#!") {
+		return src
+	}
+	i := strings.IndexByte(src, '\n')
+	if i < 0 {
+		i = len(src)
+	}
+	return strings.Repeat(" ", i) + src[i:]
+}
+
+// 词法分析器遇到无法识别的字符时返回EOF，之后的代码不会被解析
+func illegalCharacter(src string) (Diagnostic, bool) {
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		if tok.Type != token.EOF {
+			continue
+		}
+		if tok.Offset >= len(src) {
+			return Diagnostic{}, false
+		}
+		r, size := utf8.DecodeRuneInString(src[tok.Offset:])
+		msg := fmt.Sprintf("unexpected character %q", r)
+		return newDiagnostic(src, tok.Offset, tok.Offset+size, Error, "syntax", msg), true
+	}
+}
+
+func newDiagnostic(src string, offset, end int, severity, check, msg string) Diagnostic {
+	d := Diagnostic{Offset: offset, End: end, Severity: severity, Check: check, Message: msg}
+	d.Line, d.Column = Position(src, offset)
+	d.EndLine, d.EndColumn = Position(src, end)
+	return d
+}
+
+// 字节偏移对应的行号和列号，都从1开始，列号按字符计
+func Position(src string, offset int) (line, column int) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	before := src[:offset]
+	start := strings.LastIndexByte(before, '\n') + 1
+	return strings.Count(before, "\n") + 1, utf8.RuneCountInString(before[start:]) + 1
+}
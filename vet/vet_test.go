@@ -0,0 +1,144 @@
+package vet
+
+import (
+	"fmt"
+	"monkey_Interpreter/ast"
+	"strings"
+	"testing"
+)
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		//undefined
+		{"put(x);", []string{"1:5: undefined: x (undefined)"}},
+		{"put(y);\nlet y = 1;", []string{"1:5: y is used before it is defined (undefined)"}},
+		{"let y = y + 1;", []string{"1:9: y is used before it is defined (undefined)"}},
+		{"put(math.tau);", []string{"1:5: undefined: math.tau (undefined)"}},
+		{"let f = fn() { g() };\nlet g = fn() { 1 };\nf();", nil},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };\nput(fact(5));", nil},
+		{"put(args, read_file, math.pi);", nil},
+
+		//unused
+		{"let a = 1;", []string{"1:5: a declared and not used (unused)"}},
+		{"let f = fn(a, b) { a };\nf(1, 2);", []string{"1:15: parameter b is not used (unused)"}},
+		{"let a = 1;\nlet a = 2;\nput(a);", []string{"1:5: a declared and not used (unused)"}},
+		{"let n = 0;\nlet n = n + 1;\nput(n);", nil},
+		{"let _a = 1;\nlet f = fn(_x) { 1 };\nf(1);", nil},
+		{"if (len(args) > 0) { let a = 1; put(a) }", nil},
+
+		//shadow
+		{"let x = 1;\nlet f = fn(x) { x };\nput(f(x));", []string{"2:12: x shadows the declaration on line 1 (shadow)"}},
+		{"let f = fn() { let f = 1; f };\nf();", []string{"1:20: f shadows the declaration on line 1 (shadow)"}},
+		{"let len = fn(_x) { 0 };\nput(len(1, 2));", []string{"1:5: len shadows the builtin function (shadow)"}},
+		{"let math = 1;\nput(math);", []string{"1:5: math shadows the builtin module (shadow)"}},
+
+		//unreachable
+		{"let f = fn(x) {\n  return x;\n  put(x);\n  put(2)\n};\nf(1);", []string{"3:3: unreachable code (unreachable)"}},
+		{"let f = fn(x) { if (x) { return 1 } else { return 2 }; 3 };\nf(1);", []string{"1:56: unreachable code (unreachable)"}},
+		{"let f = fn(x) { if (x) { return 1 } elif (x > 1) { 2 } else { return 3 }; 4 };\nf(1);", nil},
+		{"let f = fn(x) { if (x) { return 1 }; 2 };\nf(1);", nil},
+
+		//arity
+		{"put(len(1, 2));", []string{"1:5: wrong number of arguments to len(x): got 2, want 1 (arity)"}},
+		{"put(reduce([1]));", []string{"1:5: wrong number of arguments to reduce(arr, fn[, initial]): got 1, want 2 to 3 (arity)"}},
+		{"put(zip());", []string{"1:5: wrong number of arguments to zip(arrs...): got 0, want at least 1 (arity)"}},
+		{"put(math.pow(2));", []string{"1:5: wrong number of arguments to math.pow(x, y): got 1, want 2 (arity)"}},
+		{"put(range(10), range(1, 10, 2), put(), format(\"%d\", 1, 2));", nil},
+
+		//duplicate-key
+		{`put({"a": 1, "b": 2, "a": 3});`, []string{`1:22: duplicate key "a" in hash literal (duplicate-key)`}},
+		{"let k = 1;\nput({k: 1, 1: 2, k: 3, 2 - 1: 4});", []string{
+			"2:18: duplicate key k in hash literal (duplicate-key)",
+			"2:24: duplicate key 2 - 1 in hash literal (duplicate-key)",
+		}},
+		{`put({1: 1, "1": 2, true: 3});`, nil},
+
+		//constant-condition
+		{"if (true) { put(1) }", []string{"1:5: condition is always true (constant-condition)"}},
+		{"if (args) { 1 } elif (1 > 2) { 2 }", []string{"1:23: condition is always false (constant-condition)"}},
+		{"if ([]) { 1 }", []string{"1:5: condition is always true (constant-condition)"}},
+		{"if (!(1 == 1)) { 1 }", []string{"1:5: condition is always false (constant-condition)"}},
+		{"if (1 + \"a\") { 1 }", nil},
+
+		//quote和宏
+		{"let a = 1;\nput(quote(b + unquote(a)));", nil},
+		{"let unless = macro(c, x) { quote(if (!(unquote(c))) { unquote(x) }) };\nunless(false, put(1));", nil},
+
+		//syntax
+		{"let x = ;", []string{"1:9: no prefix parse function for ; found (syntax)"}},
+		{"put(1 & 2);", []string{"1:7: unexpected character '&' (syntax)"}},
+		{"#!/usr/bin/env monkey\nput(z);", []string{"2:5: undefined: z (undefined)"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, d := range Source([]byte(tt.input)) {
+			got = append(got, fmt.Sprintf("%d:%d: %s (%s)", d.Line, d.Column, d.Message, d.Check))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("input %q:\ngot\n\t%s\nwant\n\t%s", tt.input, strings.Join(got, "\n\t"), strings.Join(tt.expected, "\n\t"))
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	src := "let s = \"日本\"; put(s, zz);"
+	diags := Source([]byte(src))
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diags)
+	}
+	d := diags[0]
+	if d.Line != 1 || d.Column != 22 || d.EndLine != 1 || d.EndColumn != 24 {
+		t.Errorf("wrong position: %d:%d-%d:%d", d.Line, d.Column, d.EndLine, d.EndColumn)
+	}
+	if src[d.Offset:d.End] != "zz" {
+		t.Errorf("wrong offsets: %q", src[d.Offset:d.End])
+	}
+	if d.Severity != Error {
+		t.Errorf("wrong severity: %s", d.Severity)
+	}
+
+	//范围覆盖整个表达式
+	src = "let f = fn(x) {\n  return 1;\n  put(x, [1,\n    2]);\n};\nf(1);"
+	diags = Source([]byte(src))
+	if len(diags) != 1 || src[diags[0].Offset:diags[0].End] != "put(x, [1,\n    2])" {
+		t.Fatalf("wrong range: %v", diags)
+	}
+	if d := diags[0]; d.Line != 3 || d.Column != 3 || d.EndLine != 4 || d.EndColumn != 8 {
+		t.Errorf("wrong position: %d:%d-%d:%d", d.Line, d.Column, d.EndLine, d.EndColumn)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	src := "let x = 1;\nlet f = fn(x) { x + y };\nlet x = x + 1;\nput(f(x), math.pi);"
+	program, diags := Parse([]byte(src))
+	if len(diags) != 0 {
+		t.Fatalf("parse failed: %v", diags)
+	}
+	loc := NewLocator([]byte(src))
+	refs := Resolve(program, []byte(src))
+
+	//每个标识符 -> 声明所在的行:列，不在结果中的为空
+	var got []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			decl := ""
+			if d, ok := refs[ident]; ok {
+				line, column := Position(src, loc.Start(d))
+				decl = fmt.Sprintf("%d:%d", line, column)
+			}
+			got = append(got, ident.Value+"->"+decl)
+		}
+		return true
+	})
+	expected := []string{
+		"x->1:5", "f->2:5", "x->2:12", "x->2:12", "y->", "x->3:5", "x->1:5",
+		"put->", "f->2:5", "x->3:5", "math->", "pi->",
+	}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("wrong resolution:\ngot  %s\nwant %s", strings.Join(got, " "), strings.Join(expected, " "))
+	}
+}
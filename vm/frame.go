@@ -0,0 +1,18 @@
+package vm
+
+import (
+	"monkey_Interpreter/code"
+	"monkey_Interpreter/object"
+)
+
+// 一次函数调用
+type Frame struct {
+	cl     *object.Closure
+	ip     int
+	locals *object.Locals //顶层代码没有局部变量，为nil
+	base   int            //调用前的栈顶，出错时恢复到这里
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
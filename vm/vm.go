@@ -0,0 +1,517 @@
+package vm
+
+//基于栈的虚拟机 执行编译器生成的字节码
+//运算复用求值器导出的函数，结果与错误信息都与求值器一致；出现错误时立即结束程序
+
+import (
+	"fmt"
+	"monkey_Interpreter/code"
+	"monkey_Interpreter/compiler"
+	"monkey_Interpreter/evaluator"
+	"monkey_Interpreter/object"
+)
+
+const MaxFrames = 1 << 20 //最大调用深度
+
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+// 常用的小整数预先分配，运算结果落在该范围时不再分配对象
+const (
+	smallIntMin = -128
+	smallIntMax = 1023
+)
+
+var smallInts = func() []*object.Integer {
+	ints := make([]*object.Integer, smallIntMax-smallIntMin+1)
+	for i := range ints {
+		ints[i] = &object.Integer{Value: int64(i + smallIntMin)}
+	}
+	return ints
+}()
+
+func newInteger(v int64) *object.Integer {
+	if v >= smallIntMin && v <= smallIntMax {
+		return smallInts[v-smallIntMin]
+	}
+	return &object.Integer{Value: v}
+}
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string       //按下标排列的全局变量名
+	globalIndex map[string]int //全局变量名到下标，按名称查找时使用
+
+	main   *object.Closure //顶层代码
+	stack  []object.Object
+	frames []Frame
+
+	result object.Object //最后一条顶层语句的值
+
+	strictIndex bool //索引越界时返回错误而不是NULL
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsState(bytecode, nil)
+}
+
+// 使用已有的全局变量，长度不足时扩展
+func NewWithGlobalsState(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	vm := &VM{globals: globals, stack: make([]object.Object, 0, 256)}
+	vm.Load(bytecode)
+	return vm
+}
+
+// 加载新编译的代码，保留全局变量及之前创建的闭包，REPL中逐段执行时使用
+// 新的常量池须包含之前的常量
+func (vm *VM) Load(bytecode *compiler.Bytecode) {
+	vm.constants = bytecode.Constants
+	vm.globalNames = bytecode.Globals
+	if n := len(bytecode.Globals); n > len(vm.globals) {
+		vm.globals = append(vm.globals, make([]object.Object, n-len(vm.globals))...)
+	}
+	vm.globalIndex = make(map[string]int, len(bytecode.Globals))
+	for i, name := range bytecode.Globals {
+		vm.globalIndex[name] = i
+	}
+	vm.main = &object.Closure{
+		Fn:    &object.CompiledFunction{Instructions: bytecode.Instructions},
+		Owner: vm,
+	}
+}
+
+// 全局变量，下标与编译时的符号表一致，加载新代码后可能重新分配
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// 设置索引越界时返回错误还是NULL，与求值器的object.Environment.SetStrictIndex对应
+func (vm *VM) SetStrictIndex(strict bool) {
+	vm.strictIndex = strict
+}
+
+// 执行加载的代码，运行时错误作为结果返回
+func (vm *VM) Run() {
+	vm.result = nil
+	vm.stack, vm.frames = vm.stack[:0], vm.frames[:0]
+	vm.pushFrame(vm.main, nil)
+	if result, halted := vm.run(0); halted {
+		vm.result = result
+	}
+}
+
+// 程序的结果 最后一条语句为let时为nil，与求值器一致
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+// 执行闭包并返回结果，内置函数回调用户函数时使用
+func (vm *VM) RunClosure(cl *object.Closure, args []object.Object) object.Object {
+	if len(args) != cl.Fn.NumParameters {
+		return wrongArguments(cl, len(args))
+	}
+	if len(vm.frames) >= MaxFrames {
+		return stackOverflow()
+	}
+	depth := len(vm.frames)
+	vm.pushFrame(cl, args)
+	result, _ := vm.run(depth)
+	return result
+}
+
+func (vm *VM) pushFrame(cl *object.Closure, args []object.Object) {
+	frame := Frame{cl: cl, base: len(vm.stack)}
+	if cl != vm.main {
+		frame.locals = newLocals(cl, args)
+	}
+	vm.frames = append(vm.frames, frame)
+}
+
+// 局部变量较少时与Locals一起分配，减少每次调用的内存分配
+type smallLocals struct {
+	locals object.Locals
+	values [4]object.Object
+}
+
+func newLocals(cl *object.Closure, args []object.Object) *object.Locals {
+	var locals *object.Locals
+	if n := cl.Fn.NumLocals; n <= len(smallLocals{}.values) {
+		block := &smallLocals{}
+		block.locals.Values = block.values[:n]
+		locals = &block.locals
+	} else {
+		locals = &object.Locals{Values: make([]object.Object, n)}
+	}
+	copy(locals.Values, args)
+	locals.Fn, locals.Outer = cl.Fn, cl.Outer
+	return locals
+}
+
+// 执行到调用深度回到depth为止
+// 顶层代码执行完毕时halted为false，结果由OpPop和OpSetGlobal记录在vm.result中
+func (vm *VM) run(depth int) (result object.Object, halted bool) {
+	frame := &vm.frames[len(vm.frames)-1]
+	ins := frame.Instructions()
+
+	for {
+		if frame.ip >= len(ins) { //只有顶层代码会执行到末尾，函数都以OpReturnValue结束
+			vm.frames = vm.frames[:depth]
+			return nil, false
+		}
+
+		op := code.Opcode(ins[frame.ip])
+		frame.ip++
+
+		var err object.Object //运算出错时结束执行
+
+		switch op {
+		case code.OpConstant:
+			index := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			vm.push(vm.constants[index])
+
+		case code.OpPop:
+			vm.result = vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpAnd, code.OpOr:
+			right := vm.pop()
+			left := vm.pop()
+			value := vm.binaryOperation(op, left, right)
+			if isError(value) {
+				err = value
+				break
+			}
+			vm.push(value)
+
+		case code.OpMinus:
+			operand := vm.pop()
+			if integer, ok := operand.(*object.Integer); ok {
+				vm.push(newInteger(-integer.Value))
+				break
+			}
+			value := evaluator.PrefixOperation("-", operand)
+			if isError(value) {
+				err = value
+				break
+			}
+			vm.push(value)
+
+		case code.OpBang:
+			vm.push(evaluator.PrefixOperation("!", vm.pop()))
+
+		case code.OpTrue:
+			vm.push(True)
+		case code.OpFalse:
+			vm.push(False)
+		case code.OpNull:
+			vm.push(Null)
+
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[frame.ip:]))
+
+		case code.OpJumpNotTruthy:
+			target := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = target
+			}
+
+		case code.OpGetGlobal:
+			index := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			value := vm.globals[index]
+			if value == nil {
+				value = vm.lookup(vm.globalNames[index], frame.locals)
+				if isError(value) {
+					err = value
+					break
+				}
+			}
+			vm.push(value)
+
+		case code.OpSetGlobal:
+			index := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			vm.globals[index] = vm.pop()
+			vm.result = nil //let语句没有值
+
+		case code.OpGetLocal:
+			index := int(ins[frame.ip])
+			frame.ip++
+			value := frame.locals.Values[index]
+			if value == nil {
+				value = vm.lookup(frame.locals.Fn.LocalNames[index], frame.locals)
+				if isError(value) {
+					err = value
+					break
+				}
+			}
+			vm.push(value)
+
+		case code.OpSetLocal:
+			index := int(ins[frame.ip])
+			frame.ip++
+			frame.locals.Values[index] = vm.pop()
+
+		case code.OpGetOuter:
+			locals := frame.locals
+			for d := int(ins[frame.ip]); d > 0; d-- {
+				locals = locals.Outer
+			}
+			index := int(ins[frame.ip+1])
+			frame.ip += 2
+			value := locals.Values[index]
+			if value == nil {
+				value = vm.lookup(locals.Fn.LocalNames[index], frame.locals)
+				if isError(value) {
+					err = value
+					break
+				}
+			}
+			vm.push(value)
+
+		case code.OpGetName:
+			index := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			value := vm.lookup(vm.constants[index].(*object.String).Value, frame.locals)
+			if isError(value) {
+				err = value
+				break
+			}
+			vm.push(value)
+
+		case code.OpArray:
+			n := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			n := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			value := buildHash(vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			if isError(value) {
+				err = value
+				break
+			}
+			vm.push(value)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			value := evaluator.IndexOperation(left, index, vm.strictIndex)
+			if isError(value) {
+				err = value
+				break
+			}
+			vm.push(value)
+
+		case code.OpSlice:
+			flags := int(ins[frame.ip])
+			frame.ip++
+			bounds := make([]object.Object, 3)
+			for i := 2; i >= 0; i-- {
+				if flags&(1<<i) != 0 {
+					bounds[i] = vm.pop()
+				}
+			}
+			value := evaluator.SliceOperation(vm.pop(), bounds[0], bounds[1], bounds[2])
+			if isError(value) {
+				err = value
+				break
+			}
+			vm.push(value)
+
+		case code.OpProperty:
+			index := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			value := evaluator.PropertyOperation(vm.pop(), vm.constants[index].(*object.String).Value)
+			if isError(value) {
+				err = value
+				break
+			}
+			vm.push(value)
+
+		case code.OpClosure:
+			index := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			fn := vm.constants[index].(*object.CompiledFunction)
+			vm.push(&object.Closure{Fn: fn, Outer: frame.locals, Owner: vm})
+
+		case code.OpCall:
+			numArgs := int(ins[frame.ip])
+			frame.ip++
+			args := vm.stack[len(vm.stack)-numArgs:]
+			fn := vm.stack[len(vm.stack)-numArgs-1]
+
+			if cl, ok := fn.(*object.Closure); ok && cl.Owner == vm {
+				if numArgs != cl.Fn.NumParameters {
+					err = wrongArguments(cl, numArgs)
+					break
+				}
+				if len(vm.frames) >= MaxFrames {
+					err = stackOverflow()
+					break
+				}
+				vm.pushFrame(cl, args)
+				vm.stack = vm.stack[:len(vm.stack)-numArgs-1]
+				frame = &vm.frames[len(vm.frames)-1]
+				frame.base = len(vm.stack)
+				ins = frame.Instructions()
+				break
+			}
+
+			//内置函数、求值器的函数或其他虚拟机的闭包
+			callArgs := make([]object.Object, numArgs)
+			copy(callArgs, args)
+			value := evaluator.ApplyFunction(fn, callArgs)
+			frame = &vm.frames[len(vm.frames)-1] //回调用户函数时frames可能重新分配
+			vm.stack = vm.stack[:len(vm.stack)-numArgs-1]
+			if value == nil {
+				value = Null
+			}
+			if isError(value) {
+				err = value
+				break
+			}
+			vm.push(value)
+
+		case code.OpReturnValue, code.OpReturn:
+			value := object.Object(Null)
+			if op == code.OpReturnValue {
+				value = vm.pop()
+			}
+			vm.stack = vm.stack[:frame.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == depth {
+				return value, true
+			}
+			frame = &vm.frames[len(vm.frames)-1]
+			ins = frame.Instructions()
+			vm.push(value)
+
+		default:
+			panic(fmt.Sprintf("unknown opcode %d", op))
+		}
+
+		if err != nil {
+			vm.stack = vm.stack[:vm.frames[depth].base]
+			vm.frames = vm.frames[:depth]
+			return err, true
+		}
+	}
+}
+
+func (vm *VM) push(obj object.Object) {
+	vm.stack = append(vm.stack, obj)
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return obj
+}
+
+// 二元运算 整数运算直接计算，其余交给求值器
+func (vm *VM) binaryOperation(op code.Opcode, left, right object.Object) object.Object {
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if lok && rok {
+		switch op {
+		case code.OpAdd:
+			return newInteger(l.Value + r.Value)
+		case code.OpSub:
+			return newInteger(l.Value - r.Value)
+		case code.OpMul:
+			return newInteger(l.Value * r.Value)
+		case code.OpDiv:
+			if r.Value != 0 {
+				return newInteger(l.Value / r.Value)
+			}
+		case code.OpEqual:
+			return nativeBool(l.Value == r.Value)
+		case code.OpNotEqual:
+			return nativeBool(l.Value != r.Value)
+		case code.OpGreaterThan:
+			return nativeBool(l.Value > r.Value)
+		case code.OpLessThan:
+			return nativeBool(l.Value < r.Value)
+		}
+	}
+	return evaluator.InfixOperation(infixOperators[op], left, right)
+}
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+	code.OpAnd:         "&&",
+	code.OpOr:          "||",
+}
+
+// 变量尚未赋值或编译时无法确定时按名称查找：
+// 先由内向外查找各层函数的局部变量，再查找全局变量、内置函数与内置模块
+func (vm *VM) lookup(name string, locals *object.Locals) object.Object {
+	for l := locals; l != nil; l = l.Outer {
+		names := l.Fn.LocalNames
+		for i := len(names) - 1; i >= 0; i-- { //同名形参以后面的为准
+			if names[i] == name && l.Values[i] != nil {
+				return l.Values[i]
+			}
+		}
+	}
+	if index, ok := vm.globalIndex[name]; ok && vm.globals[index] != nil {
+		return vm.globals[index]
+	}
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		return builtin
+	}
+	return &object.Error{Message: "identifier not found: " + name}
+}
+
+// 由栈上依次排列的键和值构造哈希表
+func buildHash(items []object.Object) object.Object {
+	hash := object.NewHash()
+	for i := 0; i < len(items); i += 2 {
+		key, value := items[i], items[i+1]
+		if !object.IsHashable(key) {
+			return &object.Error{Message: fmt.Sprintf("unusable as hash key: %s", key.Type())}
+		}
+		hash.Set(key, value)
+	}
+	return hash
+}
+
+func nativeBool(b bool) *object.Boolean {
+	if b {
+		return True
+	}
+	return False
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+func wrongArguments(cl *object.Closure, got int) *object.Error {
+	return &object.Error{Message: fmt.Sprintf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, got)}
+}
+
+func stackOverflow() *object.Error {
+	return &object.Error{Message: "stack overflow"}
+}
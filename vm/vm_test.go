@@ -0,0 +1,187 @@
+package vm
+
+import (
+	"monkey_Interpreter/ast"
+	"monkey_Interpreter/compiler"
+	"monkey_Interpreter/lexer"
+	"monkey_Interpreter/object"
+	"monkey_Interpreter/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"-5 * (2 + 3)", -25},
+		{"5000 * 5000", 25000000},
+		{"7 / 0", "division by zero"},
+		{"1 < 2", true},
+		{"1 == 2", false},
+		{"!(1 > 2)", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1 > 2) { 10 } elif (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } elif (2 < 1) { 20 } else { 30 }", 30},
+		{"if (false) { 10 } elif (true) { } else { 30 }", nil},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let a = 1; let a = a + 1; a", 2},
+		{"let x = 1;", nil},
+		{"if (true) { let y = 5; }; y", 5},
+		{"if (false) { let y = 5; }; y", "identifier not found: y"},
+		{"let len = 5; len", 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionsAndClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn(a, b) { a + b }; f(1, 2)", 3},
+		{"let f = fn() { return 1; 2 }; f()", 1},
+		{"let f = fn() { }; f()", nil},
+		{"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(15)", 610},
+		{"let adder = fn(a) { fn(b) { a + b } }; adder(2)(3)", 5},
+		{"let f = fn() { let g = fn() { h() }; let h = fn() { 7 }; g() }; f()", 7},
+		{"let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()", 2},
+		{"let x = 10; let f = fn() { let y = x; let x = 1; x + y }; f()", 11},
+		{"let f = fn(a, a) { a }; f(1, 2)", 2},
+		{"let f = fn(a) { a }; f()", "wrong number of arguments: want=1, got=0"},
+		{"5()", "not a function: INTEGER"},
+		{"return 3; 4", 3},
+		{"let f = fn() { g() }; f()", "identifier not found: g"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinsCallingClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{"len([1, 2, 3])", 3},
+		{"let k = 3; len(map([1, 2], fn(x) { x * k }))", 2},
+		{"reduce(map([1, 2, 3], fn(x) { x * 2 }), fn(a, b) { a + b }, 0)", 12},
+		{"let f = fn(x) { map([x], fn(y) { y + x })[0] }; f(4) + 1", 9},
+		{"map([1], fn(x) { x + true })", "type mismatch: INTEGER + BOOLEAN"},
+		{"math.max(1, 2)", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCollections(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][1:][0]", 2},
+		{`len("hello"[::2])`, 3},
+		{`{"a": 1, "b": 2}["b"]`, 2},
+		{`{"a": 1}.a`, 1},
+		{`{fn() {}: 2}`, "unusable as hash key: FUNCTION"},
+		{`[1, 2][0:1:0]`, "slice step cannot be zero"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStackOverflow(t *testing.T) {
+	runVmTests(t, []vmTestCase{
+		{"let f = fn(n) { f(n + 1) }; f(0)", "stack overflow"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(10000)", 10000},
+	})
+}
+
+// 加载新代码后保留全局变量和已创建的闭包
+func TestLoad(t *testing.T) {
+	symbols := compiler.NewSymbolTable()
+	var constants []object.Object
+	var machine *VM
+
+	for _, tt := range []vmTestCase{
+		{"let counter = fn(n) { fn() { n + step } };", nil},
+		{"let c = counter(10);", nil},
+		{"let step = 5; c()", 15},
+	} {
+		c := compiler.NewWithState(symbols, constants)
+		if err := c.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := c.Bytecode()
+		constants = bytecode.Constants
+		if machine == nil {
+			machine = New(bytecode)
+		} else {
+			machine.Load(bytecode)
+		}
+		machine.Run()
+		testExpectedObject(t, tt.input, tt.expected, machine.Result())
+	}
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		vm.Run()
+		testExpectedObject(t, tt.input, tt.expected, vm.Result())
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+// expected为int、bool、string(错误信息)或nil(null或没有值)
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		integer, ok := actual.(*object.Integer)
+		if !ok || integer.Value != int64(expected) {
+			t.Errorf("%s: want %d, got=%T(%+v)", input, expected, actual, actual)
+		}
+	case bool:
+		boolean, ok := actual.(*object.Boolean)
+		if !ok || boolean.Value != expected {
+			t.Errorf("%s: want %t, got=%T(%+v)", input, expected, actual, actual)
+		}
+	case string:
+		err, ok := actual.(*object.Error)
+		if !ok || err.Message != expected {
+			t.Errorf("%s: want error %q, got=%T(%+v)", input, expected, actual, actual)
+		}
+	case nil:
+		if actual != nil && actual != Null {
+			t.Errorf("%s: want null, got=%T(%+v)", input, actual, actual)
+		}
+	}
+}